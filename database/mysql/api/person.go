@@ -0,0 +1,326 @@
+// Package api 将 store.PersonRepository 暴露为gin HTTP资源
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/secret"
+	"Gocommunity/database/mysql/store"
+	"Gocommunity/database/mysql/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================= 1. 请求结构体定义 ====================
+// 与 gin/file/main.go 中的 User 一样使用binding标签做参数校验
+// 字段长度限制与 migrations 中user表的列定义一致
+
+// PersonURI 路径参数 /persons/:id
+type PersonURI struct {
+	ID string `uri:"id" binding:"required,max=64"`
+}
+
+// CreatePersonRequest POST /persons 的请求体
+type CreatePersonRequest struct {
+	ID      string `json:"id" binding:"required,max=64"`
+	Name    string `json:"name" binding:"required,max=64"`
+	Age     int    `json:"age" binding:"gte=0,lte=150"`
+	Address string `json:"address" binding:"max=255"`
+}
+
+// UpdatePersonRequest PUT /persons/:id 的请求体，整体替换业务字段
+type UpdatePersonRequest struct {
+	Name    string `json:"name" binding:"required,max=64"`
+	Age     int    `json:"age" binding:"gte=0,lte=150"`
+	Address string `json:"address" binding:"max=255"`
+}
+
+// PatchPersonRequest PATCH /persons/:id 的请求体，只修改出现的字段
+type PatchPersonRequest struct {
+	Name    *string `json:"name" binding:"omitempty,min=1,max=64"`
+	Age     *int    `json:"age" binding:"omitempty,gte=0,lte=150"`
+	Address *string `json:"address" binding:"omitempty,max=255"`
+}
+
+// ============================= 2. 资源处理器 ====================
+// PersonHandler Person资源的增删改查
+//
+//	GET    /persons       分页查询，参数见 store.ParseListOptions
+//	GET    /persons/search?q=关键字  按用户名和地址全文检索，参数见 store.ParseSearchOptions
+//	GET    /persons/:id   查询单个用户，响应头带ETag
+//	POST   /persons       创建用户，返回201和Location
+//	PUT    /persons/:id   整体更新，需要If-Match
+//	PATCH  /persons/:id   部分更新，需要If-Match
+//	DELETE /persons/:id   软删除，返回204
+type PersonHandler struct {
+	repo    store.PersonRepository
+	tenants tenant.Resolver
+}
+
+// HandlerOption 创建PersonHandler时的可选配置
+type HandlerOption func(*PersonHandler)
+
+// WithTenantResolver 每个请求先用resolve确定租户，仓储启用了租户隔离时必须配置
+// 应使用 tenant.PrincipalResolver 按登录身份确定租户；tenant.HeaderResolver 信任客户端的请求头，只适合演示
+func WithTenantResolver(resolve tenant.Resolver) HandlerOption {
+	return func(h *PersonHandler) { h.tenants = resolve }
+}
+
+// NewPersonHandler 创建处理器，repo可以是MySQL实现也可以是内存实现
+func NewPersonHandler(repo store.PersonRepository, opts ...HandlerOption) *PersonHandler {
+	h := &PersonHandler{repo: repo}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Register 在r下注册 /persons 路由组
+func (h *PersonHandler) Register(r gin.IRouter) {
+	persons := r.Group("/persons", ReadYourWrites())
+	if h.tenants != nil {
+		persons.Use(Tenant(h.tenants))
+	}
+	{
+		persons.GET("", h.List)
+		persons.GET("/search", h.Search)
+		persons.GET("/:id", h.Get)
+		persons.POST("", h.Create)
+		persons.PUT("/:id", h.Update)
+		persons.PATCH("/:id", h.Patch)
+		persons.DELETE("/:id", h.Delete)
+	}
+}
+
+// ReadYourWrites 为每个请求开启"读己之写"，请求内写入后的读取走主库
+// 仓储底层不是 dbx.Cluster 时没有任何影响
+func ReadYourWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(dbx.WithReadYourWrites(c.Request.Context()))
+		c.Next()
+	}
+}
+
+// Tenant 将resolve确定的租户放入请求的context，租户ID不合法时返回400，无权访问时返回403
+// 没有租户时不做处理: 仓储启用了租户隔离时会拒绝请求，未启用时与单租户一样
+func Tenant(resolve tenant.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := resolve(c.Request)
+		switch {
+		case err == nil:
+			c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), id))
+		case !errors.Is(err, tenant.ErrMissing):
+			abortWithError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// List 分页查询用户，c.Request.Context() 在客户端断开时取消查询
+func (h *PersonHandler) List(c *gin.Context) {
+	opts, err := store.ParseListOptions(c.Request.URL.Query())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	page, err := h.repo.List(c.Request.Context(), opts)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// Search 全文检索，repo需要实现 store.Searcher(见 store.NewSearchablePersonRepository)，否则返回501
+func (h *PersonHandler) Search(c *gin.Context) {
+	searcher, ok := h.repo.(store.Searcher)
+	if !ok {
+		abortWithError(c, store.ErrSearchUnavailable)
+		return
+	}
+	opts, err := store.ParseSearchOptions(c.Request.URL.Query())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	result, err := searcher.Search(c.Request.Context(), opts)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Get 查询单个用户
+func (h *PersonHandler) Get(c *gin.Context) {
+	var uri PersonURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindError(c, err)
+		return
+	}
+	person, err := h.repo.Get(c.Request.Context(), uri.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Header("ETag", store.ETag(person.Version))
+	c.JSON(http.StatusOK, person)
+}
+
+// Create 创建用户，ID已存在时返回409
+func (h *PersonHandler) Create(c *gin.Context) {
+	var req CreatePersonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+	person := &store.Person{UserId: req.ID, Username: req.Name, Age: req.Age, Address: secret.String(req.Address)}
+	if err := h.repo.Create(c.Request.Context(), person); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	// id可以包含/、?、#等字符，按路径段转义后才是合法的URL
+	c.Header("Location", c.FullPath()+"/"+url.PathEscape(person.UserId))
+	c.Header("ETag", store.ETag(person.Version))
+	c.JSON(http.StatusCreated, person)
+}
+
+// Update 整体更新用户的业务字段
+func (h *PersonHandler) Update(c *gin.Context) {
+	var uri PersonURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindError(c, err)
+		return
+	}
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+	var req UpdatePersonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	person := &store.Person{
+		UserId:   uri.ID,
+		Username: req.Name,
+		Age:      req.Age,
+		Address:  secret.String(req.Address),
+		Version:  version,
+	}
+	h.save(c, person)
+}
+
+// Patch 只修改请求体中出现的字段，未出现的字段保持原值
+func (h *PersonHandler) Patch(c *gin.Context) {
+	var uri PersonURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindError(c, err)
+		return
+	}
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+	var req PatchPersonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	person, err := h.repo.Get(c.Request.Context(), uri.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if req.Name != nil {
+		person.Username = *req.Name
+	}
+	if req.Age != nil {
+		person.Age = *req.Age
+	}
+	if req.Address != nil {
+		person.Address = secret.String(*req.Address)
+	}
+	// 使用客户端提供的版本号而不是刚读到的版本号，客户端读取之后的修改同样会被检测到
+	person.Version = version
+	h.save(c, person)
+}
+
+// Delete 软删除用户
+func (h *PersonHandler) Delete(c *gin.Context) {
+	var uri PersonURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		bindError(c, err)
+		return
+	}
+	if err := h.repo.Delete(c.Request.Context(), uri.ID); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// save 执行带版本校验的更新，并返回最新的记录
+func (h *PersonHandler) save(c *gin.Context, person *store.Person) {
+	ctx := c.Request.Context()
+	if err := h.repo.Update(ctx, person); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	// 重新读取完整记录(包含创建时间等由数据库维护的字段)作为响应
+	if fresh, err := h.repo.Get(ctx, person.UserId); err == nil {
+		person = fresh
+	}
+	c.Header("ETag", store.ETag(person.Version))
+	c.JSON(http.StatusOK, person)
+}
+
+// ============================= 3. 错误处理 ====================
+// ifMatchVersion 解析If-Match请求头，缺失时返回428，格式错误时返回400
+func ifMatchVersion(c *gin.Context) (int64, bool) {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.AbortWithStatusJSON(http.StatusPreconditionRequired, gin.H{"error": "缺少If-Match请求头"})
+		return 0, false
+	}
+	version, err := store.ParseETag(ifMatch)
+	if err != nil {
+		abortWithError(c, err)
+		return 0, false
+	}
+	return version, true
+}
+
+// bindError 参数绑定或校验失败
+func bindError(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		"error":   "数据绑定失败",
+		"details": err.Error(),
+	})
+}
+
+// abortWithError 使用 store.HTTPStatus 将数据层错误转换为HTTP状态码
+func abortWithError(c *gin.Context, err error) {
+	if errors.Is(err, context.Canceled) {
+		// 客户端已断开，无需再写响应
+		c.Abort()
+		return
+	}
+
+	status := store.HTTPStatus(err)
+	if status >= http.StatusInternalServerError {
+		// 5xx 不把内部错误细节返回给客户端
+		log.Printf("用户接口失败: %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		c.AbortWithStatusJSON(status, gin.H{"error": http.StatusText(status)})
+		return
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ============================= 合并并发加载 ====================
+// Group 同一个键同时只执行一次加载，其它调用方等待并共享结果(singleflight)
+// 用于缓存未命中时避免大量请求同时查询数据库
+type Group[V any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[V]
+}
+
+type call[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// Do 执行或等待键为key的加载，shared表示结果是否来自其它调用方发起的加载
+// fn收到的context不会随某个调用方取消，避免第一个调用方超时导致所有等待者失败；
+// 调用方的ctx取消时Do立即返回ctx.Err()，加载仍会在后台完成
+func (g *Group[V]) Do(ctx context.Context, key string, fn func(ctx context.Context) (V, error)) (v V, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call[V])
+	}
+	c, shared := g.calls[key]
+	if !shared {
+		c = &call[V]{done: make(chan struct{})}
+		g.calls[key] = c
+		go g.run(context.WithoutCancel(ctx), key, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err, shared
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err(), shared
+	}
+}
+
+func (g *Group[V]) run(ctx context.Context, key string, c *call[V], fn func(ctx context.Context) (V, error)) {
+	defer func() {
+		if p := recover(); p != nil {
+			c.err = fmt.Errorf("cache: 加载 %s 时panic: %v", key, p)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn(ctx)
+}
@@ -0,0 +1,129 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"Gocommunity/database/mysql/cache"
+)
+
+// TestGroupConcurrentMisses 同一个键的并发加载只执行一次，所有调用方得到相同的结果
+func TestGroupConcurrentMisses(t *testing.T) {
+	var (
+		g       cache.Group[int]
+		loads   atomic.Int32
+		shared  atomic.Int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	load := func(ctx context.Context) (int, error) {
+		if loads.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return 42, nil
+	}
+
+	const callers = 50
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if v, err, _ := g.Do(context.Background(), "k", load); v != 42 || err != nil {
+			t.Errorf("Do = %v, %v", v, err)
+		}
+	}()
+	<-started
+	for range callers - 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, s := g.Do(context.Background(), "k", load)
+			if v != 42 || err != nil {
+				t.Errorf("Do = %v, %v", v, err)
+			}
+			if s {
+				shared.Add(1)
+			}
+		}()
+	}
+	// 等待其它调用方进入Do后再完成加载
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("加载了 %d 次, want 1", n)
+	}
+	if n := shared.Load(); n != callers-1 {
+		t.Errorf("共享结果的调用方 %d 个, want %d", n, callers-1)
+	}
+
+	// 加载完成后不再合并，下一次重新加载
+	if _, _, s := g.Do(context.Background(), "k", func(context.Context) (int, error) { return 1, nil }); s {
+		t.Error("加载完成后的调用不应共享结果")
+	}
+}
+
+// TestGroupKeys 不同的键互不影响
+func TestGroupKeys(t *testing.T) {
+	var g cache.Group[string]
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Do(context.Background(), "slow", func(context.Context) (string, error) {
+			<-release
+			return "slow", nil
+		})
+	}()
+
+	v, err, s := g.Do(context.Background(), "fast", func(context.Context) (string, error) { return "fast", nil })
+	if v != "fast" || err != nil || s {
+		t.Errorf("Do(fast) = %v, %v, %v", v, err, s)
+	}
+	close(release)
+	<-done
+}
+
+// TestGroupCancel 调用方取消时立即返回，加载在后台完成，它收到的context不会被取消
+func TestGroupCancel(t *testing.T) {
+	var g cache.Group[int]
+	release := make(chan struct{})
+	loaded := make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err, _ := g.Do(ctx, "k", func(ctx context.Context) (int, error) {
+		<-release
+		loaded <- ctx.Err()
+		return 1, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do err = %v, want Canceled", err)
+	}
+	close(release)
+	if err := <-loaded; err != nil {
+		t.Errorf("加载收到的context已取消: %v", err)
+	}
+}
+
+// TestGroupPanic 加载panic时所有调用方收到错误，键可以再次加载
+func TestGroupPanic(t *testing.T) {
+	var g cache.Group[int]
+	_, err, _ := g.Do(context.Background(), "k", func(context.Context) (int, error) { panic("boom") })
+	if err == nil {
+		t.Fatal("panic应转为错误")
+	}
+	v, err, _ := g.Do(context.Background(), "k", func(context.Context) (int, error) { return 2, nil })
+	if v != 2 || err != nil {
+		t.Errorf("panic之后 Do = %v, %v", v, err)
+	}
+}
@@ -0,0 +1,117 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"Gocommunity/database/mysql/cache"
+)
+
+func TestLRUEviction(t *testing.T) {
+	c := cache.NewLRU[string, int](3, 0)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+
+	// 访问a后，最久未使用的是b
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %v, %v", v, ok)
+	}
+	c.Set("d", 4)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b应被淘汰")
+	}
+
+	// 更新已有的键不增加条目，同时标记为最近使用
+	c.Set("c", 30)
+	c.Set("e", 5)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a应被淘汰")
+	}
+	for key, want := range map[string]int{"c": 30, "d": 4, "e": 5} {
+		if v, ok := c.Get(key); !ok || v != want {
+			t.Errorf("Get(%s) = %v, %v, want %d", key, v, ok, want)
+		}
+	}
+	if n := c.Len(); n != 3 {
+		t.Errorf("Len = %d, want 3", n)
+	}
+
+	c.Delete("c")
+	c.Delete("missing")
+	if _, ok := c.Get("c"); ok || c.Len() != 2 {
+		t.Errorf("Delete后 Len = %d", c.Len())
+	}
+	c.Clear()
+	if _, ok := c.Get("d"); ok || c.Len() != 0 {
+		t.Errorf("Clear后 Len = %d", c.Len())
+	}
+}
+
+func TestLRUUnbounded(t *testing.T) {
+	c := cache.NewLRU[int, int](0, 0)
+	for i := range 1000 {
+		c.Set(i, i)
+	}
+	if v, ok := c.Get(0); !ok || v != 0 || c.Len() != 1000 {
+		t.Errorf("容量不限时不淘汰: Get(0) = %v, %v, Len = %d", v, ok, c.Len())
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+	c := cache.NewLRU[string, int](0, ttl)
+	c.Set("default", 1)
+	c.SetWithTTL("long", 2, time.Hour)
+	c.SetWithTTL("forever", 3, 0)
+	c.SetWithTTL("refreshed", 4, ttl)
+
+	time.Sleep(ttl / 2)
+	// 重新写入时按新的TTL计算过期时间
+	c.Set("refreshed", 40)
+	time.Sleep(ttl/2 + 5*time.Millisecond)
+
+	if _, ok := c.Get("default"); ok {
+		t.Error("default应已过期")
+	}
+	for key, want := range map[string]int{"long": 2, "forever": 3, "refreshed": 40} {
+		if v, ok := c.Get(key); !ok || v != want {
+			t.Errorf("Get(%s) = %v, %v, want %d", key, v, ok, want)
+		}
+	}
+	// 过期的条目在访问时删除
+	if n := c.Len(); n != 3 {
+		t.Errorf("Len = %d, want 3", n)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	l := cache.NewLocal(0)
+	if _, err := l.Get(ctx, "k"); !errors.Is(err, cache.ErrMiss) {
+		t.Fatalf("Get(missing) err = %v, want ErrMiss", err)
+	}
+
+	value := []byte("v1")
+	if err := l.Set(ctx, "k", value, 0); err != nil {
+		t.Fatal(err)
+	}
+	value[0] = 'x'
+	got, err := l.Get(ctx, "k")
+	if err != nil || string(got) != "v1" {
+		t.Fatalf("Get = %q, %v, want v1", got, err)
+	}
+	got[0] = 'y'
+	if got, _ := l.Get(ctx, "k"); string(got) != "v1" {
+		t.Errorf("修改返回的切片影响了缓存: %q", got)
+	}
+
+	if err := l.Delete(ctx, "k", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Get(ctx, "k"); !errors.Is(err, cache.ErrMiss) {
+		t.Errorf("Delete后 err = %v, want ErrMiss", err)
+	}
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss 外部缓存中没有该键
+var ErrMiss = errors.New("缓存未命中")
+
+// ============================= 外部缓存 ====================
+// Store 外部缓存(Redis、Memcached等)需要实现的最小接口，值是序列化后的字节
+// 多个进程共享同一个Store时，一个进程的失效操作对其它进程立即可见
+type Store interface {
+	// Get 返回键对应的值，不存在或已过期时返回 ErrMiss
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set 写入值，ttl<=0表示不过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete 删除键，不存在的键忽略
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Local 进程内的Store实现，在本地开发和测试中代替外部缓存
+// 与外部缓存一样按字节复制保存，调用方修改传入或返回的切片不会影响缓存内容
+type Local struct {
+	lru *LRU[string, []byte]
+}
+
+var _ Store = (*Local)(nil)
+
+// NewLocal 创建最多保存capacity个键的本地缓存，capacity<=0表示不限制
+func NewLocal(capacity int) *Local {
+	return &Local{lru: NewLRU[string, []byte](capacity, 0)}
+}
+
+func (l *Local) Get(ctx context.Context, key string) ([]byte, error) {
+	v, ok := l.lru.Get(key)
+	if !ok {
+		return nil, ErrMiss
+	}
+	return append([]byte(nil), v...), nil
+}
+
+func (l *Local) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	l.lru.SetWithTTL(key, append([]byte(nil), value...), ttl)
+	return nil
+}
+
+func (l *Local) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		l.lru.Delete(key)
+	}
+	return nil
+}
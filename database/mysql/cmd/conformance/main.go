@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"Gocommunity/database/mysql/cache"
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/migrate"
+	"Gocommunity/database/mysql/migrations"
+	"Gocommunity/database/mysql/store"
+	"Gocommunity/database/mysql/store/storetest"
+	"Gocommunity/database/mysql/tenant"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ============================= 仓储一致性检查 ====================
+// 用法:
+//
+//	go run ./database/mysql/cmd/conformance                                   # 内存实现 + DB_*配置的数据库
+//	DB_DRIVER=sqlite DB_NAME=:memory: go run ./database/mysql/cmd/conformance -migrate
+//	go run ./database/mysql/cmd/conformance -memory                          # 只检查内存实现
+//
+// 对内存实现和配置的数据库(以及它们加上缓存层后)执行同一套 storetest 检查，任一实现不通过时以状态码1退出
+// 多租户的两种方式(按租户路由、共享表按tenant_id隔离)还会检查租户之间的隔离
+// 检查写入的记录带随机前缀并在结束时删除，可以在已有数据的库上运行
+// go test 在 store/storetest 中对内存实现和 dbtest 创建的库执行同样的检查，这个命令用于检查任意已有的库
+func main() {
+	configPath := flag.String("config", "", "数据库配置文件(JSON)，默认读取 DB_CONFIG_FILE")
+	memoryOnly := flag.Bool("memory", false, "只检查内存实现")
+	runMigrations := flag.Bool("migrate", false, "检查前执行迁移(SQLite内存库必须指定)")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	ok := check(ctx, "memory", store.NewMemoryPersonRepository())
+	// 缓存层必须对调用方透明，外部缓存使用本地替身
+	ok = check(ctx, "cached(memory)", store.NewCachedPersonRepository(store.NewMemoryPersonRepository(),
+		store.WithExternalCache(cache.NewLocal(0), store.DefaultStoreTTL))) && ok
+	ok = checkTenant(ctx, "tenant(memory)", store.NewTenantRouter(store.MemoryPerTenant())) && ok
+	ok = checkSearch(ctx, "searchable(memory)", store.NewSearchablePersonRepository(store.NewMemoryPersonRepository())) && ok
+	if !*memoryOnly {
+		repo, closeDB, err := openRepository(ctx, *configPath, *runMigrations)
+		if err != nil {
+			log.Fatalf("连接数据库失败: %v", err)
+		}
+		defer closeDB()
+		ok = check(ctx, repo.name, repo.repo) && ok
+		ok = check(ctx, "cached("+repo.name+")", store.NewCachedPersonRepository(repo.repo)) && ok
+		scoped := store.NewSQLPersonRepository(repo.db, store.WithTenantScope())
+		ok = checkTenant(ctx, "tenant("+repo.name+")", scoped) && ok
+		ok = checkTenant(ctx, "cached(tenant("+repo.name+"))", store.NewCachedPersonRepository(scoped)) && ok
+		ok = checkSearch(ctx, "searchable("+repo.name+")", store.NewSearchablePersonRepository(repo.repo)) && ok
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// namedRepository 带数据库名称的仓储，用于输出结果
+type namedRepository struct {
+	name string
+	db   *sqlx.DB
+	repo store.PersonRepository
+}
+
+// openRepository 按配置连接数据库，需要时先执行迁移
+func openRepository(ctx context.Context, configPath string, runMigrations bool) (namedRepository, func() error, error) {
+	cfg, err := dbx.LoadConfig(configPath)
+	if err != nil {
+		return namedRepository{}, nil, err
+	}
+	db, err := dbx.Open(ctx, cfg)
+	if err != nil {
+		return namedRepository{}, nil, err
+	}
+
+	if runMigrations {
+		source, err := migrations.For(cfg.DriverName())
+		if err != nil {
+			db.Close()
+			return namedRepository{}, nil, err
+		}
+		runner, err := migrate.New(db, source)
+		if err != nil {
+			db.Close()
+			return namedRepository{}, nil, err
+		}
+		if _, err := runner.Up(ctx); err != nil {
+			db.Close()
+			return namedRepository{}, nil, err
+		}
+	}
+	return namedRepository{name: cfg.String(), db: db, repo: store.NewSQLPersonRepository(db)}, db.Close, nil
+}
+
+// check 执行检查并输出结果
+func check(ctx context.Context, name string, repo store.PersonRepository) bool {
+	if err := storetest.TestPersonRepository(ctx, repo); err != nil {
+		fmt.Printf("FAIL %s\n%v\n", name, err)
+		return false
+	}
+	fmt.Printf("ok   %s\n", name)
+	return true
+}
+
+// checkTenant 在租户st_a中执行一致性检查，再检查st_a和st_b之间的隔离
+func checkTenant(ctx context.Context, name string, repo store.PersonRepository) bool {
+	ok := check(tenant.NewContext(ctx, "st_a"), name, repo)
+	if err := storetest.TestTenantIsolation(ctx, repo, "st_a", "st_b"); err != nil {
+		fmt.Printf("FAIL %s 租户隔离\n%v\n", name, err)
+		return false
+	}
+	fmt.Printf("ok   %s 租户隔离\n", name)
+	return ok
+}
+
+// checkSearch 执行一致性检查，再检查全文检索
+func checkSearch(ctx context.Context, name string, repo store.PersonRepository) bool {
+	ok := check(ctx, name, repo)
+	if err := storetest.TestSearch(ctx, repo); err != nil {
+		fmt.Printf("FAIL %s 全文检索\n%v\n", name, err)
+		return false
+	}
+	fmt.Printf("ok   %s 全文检索\n", name)
+	return ok
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/migrate"
+	"Gocommunity/database/mysql/migrations"
+)
+
+// ============================= 迁移命令 ====================
+// 用法:
+//
+//	go run ./database/mysql/cmd/migrate [-config 配置文件] [-dir 迁移目录] up
+//	go run ./database/mysql/cmd/migrate down [回滚个数，默认1]
+//	go run ./database/mysql/cmd/migrate status
+//
+// 数据库连接配置与 dbx.LoadConfig 一致，也可以使用 DB_* 环境变量
+// 不指定 -dir 时使用编译进二进制的 migrations 包，按配置中的driver选择MySQL、PostgreSQL或SQLite的迁移
+func main() {
+	configPath := flag.String("config", "", "数据库配置文件(JSON)，默认读取 DB_CONFIG_FILE")
+	dir := flag.String("dir", "", "迁移文件目录，默认使用内嵌的迁移文件")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "用法: %s [参数] up|down [n]|status\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	cfg, err := dbx.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("加载数据库配置失败: %v", err)
+	}
+	db, err := dbx.Open(ctx, cfg)
+	if err != nil {
+		log.Fatalf("连接数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	// 内嵌的迁移文件按驱动区分目录
+	source, err := migrations.For(cfg.DriverName())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *dir != "" {
+		source = os.DirFS(*dir)
+	}
+	runner, err := migrate.New(db, source)
+	if err != nil {
+		log.Fatalf("加载迁移文件失败: %v", err)
+	}
+	runner.Logf = log.Printf
+
+	switch cmd := flag.Arg(0); cmd {
+	case "up":
+		done, err := runner.Up(ctx)
+		if err != nil {
+			log.Fatalf("升级失败: %v", err)
+		}
+		fmt.Printf("升级完成，本次执行 %d 个迁移\n", len(done))
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			if steps, err = strconv.Atoi(flag.Arg(1)); err != nil || steps < 1 {
+				log.Fatalf("回滚个数必须是正整数: %q", flag.Arg(1))
+			}
+		}
+		done, err := runner.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("回滚失败: %v", err)
+		}
+		fmt.Printf("回滚完成，本次回滚 %d 个迁移\n", len(done))
+
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			log.Fatalf("查询迁移状态失败: %v", err)
+		}
+		for _, s := range statuses {
+			state := "未执行"
+			if s.Applied {
+				state = "已执行 " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		log.Printf("未知命令: %s", cmd)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
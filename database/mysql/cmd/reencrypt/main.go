@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/store"
+)
+
+// ============================= 重新加密命令 ====================
+// 用法:
+//
+//	DB_ENCRYPTION_KEYS="k2:新密钥,k1:旧密钥" go run ./database/mysql/cmd/reencrypt [-config 配置文件] [-batch 500] [-dry-run]
+//
+// 启用加密或轮换密钥的步骤:
+//  1. 执行 cmd/migrate up，地址列加宽到可以容纳密文
+//  2. 把新密钥放在 DB_ENCRYPTION_KEYS 的最前面并重启服务，此后新写入的数据使用新密钥
+//  3. 执行本命令，把明文和旧密钥加密的数据改写为新密钥加密
+//  4. 输出中"重新加密"为0后，从密钥列表中删除旧密钥
+//
+// 可以在服务运行时执行，中断后重新执行会跳过已处理的行
+func main() {
+	configPath := flag.String("config", "", "数据库配置文件(JSON)，默认读取 DB_CONFIG_FILE")
+	batch := flag.Int("batch", store.DefaultBatchSize, "每次读取的行数")
+	dryRun := flag.Bool("dry-run", false, "只统计需要重新加密的行数，不写入")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	cfg, err := dbx.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("加载数据库配置失败: %v", err)
+	}
+	if cfg.EncryptionKeys == "" {
+		log.Fatal("未配置加密密钥，请设置 DB_ENCRYPTION_KEYS 或配置文件中的 encryption_keys")
+	}
+	db, err := dbx.Open(ctx, cfg)
+	if err != nil {
+		log.Fatalf("连接数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	repo := store.NewSQLPersonRepository(db, store.WithQueryTimeout(time.Duration(cfg.QueryTimeout)))
+	result, err := repo.ReencryptAddresses(ctx, store.ReencryptOptions{
+		BatchSize: *batch,
+		DryRun:    *dryRun,
+		OnBatch: func(r store.ReencryptResult) {
+			log.Printf("进度: %s", r)
+		},
+	})
+	if err != nil {
+		log.Fatalf("重新加密失败(%s): %v", result, err)
+	}
+	if *dryRun {
+		fmt.Printf("[dry-run] %s\n", result)
+		return
+	}
+	fmt.Println(result)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/outbox"
+)
+
+// ============================= 事件投递命令 ====================
+// 用法:
+//
+//	go run ./database/mysql/cmd/relay [-config 配置文件] [-sink log]
+//	go run ./database/mysql/cmd/relay -sink webhook -url https://example.com/hooks/person [-secret 签名密钥]
+//
+// 持续读取outbox表并投递事件，Ctrl+C 退出；可以运行多个实例
+// 写入事件需要在配置中开启 outbox(DB_OUTBOX=true)，并先执行 cmd/migrate 创建outbox表
+func main() {
+	configPath := flag.String("config", "", "数据库配置文件(JSON)，默认读取 DB_CONFIG_FILE")
+	sinkName := flag.String("sink", "log", "投递目标: log|webhook")
+	url := flag.String("url", "", "webhook: 接收事件的地址")
+	secret := flag.String("secret", os.Getenv("OUTBOX_WEBHOOK_SECRET"), "webhook: HMAC签名密钥，默认读取 OUTBOX_WEBHOOK_SECRET")
+	batch := flag.Int("batch", 100, "每次读取的事件数")
+	interval := flag.Duration("interval", time.Second, "没有事件时的轮询间隔")
+	timeout := flag.Duration("timeout", 10*time.Second, "单个事件的投递超时")
+	retention := flag.Duration("retention", 7*24*time.Hour, "已投递事件的保留时长，0表示不删除")
+	flag.Parse()
+
+	var sink outbox.Sink
+	switch *sinkName {
+	case "log":
+		sink = outbox.LogSink(log.Printf)
+	case "webhook":
+		if *url == "" {
+			log.Fatal("-sink webhook 需要指定 -url")
+		}
+		sink = &outbox.Webhook{URL: *url, Secret: []byte(*secret)}
+	default:
+		log.Fatalf("不支持的投递目标 %q", *sinkName)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	cfg, err := dbx.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("加载数据库配置失败: %v", err)
+	}
+	// 事件内容按配置的密钥解密，Open会加载 EncryptionKeys
+	db, err := dbx.Open(ctx, cfg)
+	if err != nil {
+		log.Fatalf("连接数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	relay := outbox.NewRelay(db, sink,
+		outbox.WithBatchSize(*batch),
+		outbox.WithPollInterval(*interval),
+		outbox.WithPublishTimeout(*timeout),
+		outbox.WithRetention(*retention),
+	)
+	log.Printf("开始投递outbox事件(%s -> %s)", cfg, *sinkName)
+	if err := relay.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+	log.Print("已停止")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/fixtures"
+	"Gocommunity/database/mysql/seed"
+	"Gocommunity/database/mysql/store"
+	"Gocommunity/database/mysql/tenant"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// errDryRun 用于在 -dry-run 时回滚事务
+var errDryRun = errors.New("dry run")
+
+// ============================= 种子数据命令 ====================
+// 用法:
+//
+//	go run ./database/mysql/cmd/seed [-config 配置文件] [-env dev] [-dir 数据目录] [-dry-run] [-tenant 租户]
+//
+// 加载 common 和 -env 指定的数据集(默认读取 SEED_ENV，仍为空则为dev)，在一个事务中按id写入，
+// 重复执行不会产生重复数据，已存在且内容相同的记录不做修改
+// 不指定 -dir 时使用编译进二进制的 fixtures 包；执行前需要先运行 cmd/migrate 建表
+func main() {
+	configPath := flag.String("config", "", "数据库配置文件(JSON)，默认读取 DB_CONFIG_FILE")
+	env := flag.String("env", envOr("SEED_ENV", "dev"), "数据集名称，对应数据目录下的子目录")
+	dir := flag.String("dir", "", "数据目录，默认使用内嵌的种子数据")
+	dryRun := flag.Bool("dry-run", false, "只输出将要执行的修改，不提交事务")
+	tenantID := flag.String("tenant", "", "写入该租户，配置了multi_tenant时必须指定")
+	flag.Parse()
+
+	var source fs.FS = fixtures.FS
+	if *dir != "" {
+		source = os.DirFS(*dir)
+	}
+	persons, err := seed.Load(source, *env)
+	if err != nil {
+		log.Fatalf("加载种子数据失败: %v", err)
+	}
+
+	ctx := context.Background()
+	if *tenantID != "" {
+		if err := tenant.Validate(*tenantID); err != nil {
+			log.Fatal(err)
+		}
+		ctx = tenant.NewContext(ctx, *tenantID)
+	}
+	cfg, err := dbx.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("加载数据库配置失败: %v", err)
+	}
+	db, err := dbx.Open(ctx, cfg)
+	if err != nil {
+		log.Fatalf("连接数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	// 全部写入在一个事务中，中途失败不会留下一半的数据
+	var result seed.Result
+	err = dbx.WithTx(ctx, db, dbx.DefaultTxOptions(), func(tx *sqlx.Tx) error {
+		result, err = seed.Apply(ctx, store.NewSQLPersonRepository(tx, store.ConfigOptions(cfg)...), persons)
+		if err == nil && *dryRun {
+			return errDryRun
+		}
+		return err
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		log.Fatalf("写入种子数据失败: %v", err)
+	}
+
+	if *dryRun {
+		fmt.Printf("[dry-run] 数据集 %s/%s 共%d条，将会: %s\n", seed.CommonSet, *env, len(persons), result)
+		return
+	}
+	fmt.Printf("数据集 %s/%s 共%d条，%s\n", seed.CommonSet, *env, len(persons), result)
+}
+
+// envOr 读取环境变量，未设置时返回默认值
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
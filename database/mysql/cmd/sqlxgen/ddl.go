@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================= 建表语句 ====================
+// dialect 各数据库在建表语句上的差异，列类型与 migrations 中手写的表保持同样的写法
+type dialect struct {
+	driver string // dbx中的驱动常量名
+	types  map[string]string
+	// inlineIndex 为true时普通索引写在CREATE TABLE中(MySQL)，否则单独CREATE INDEX
+	inlineIndex bool
+	suffix      string
+}
+
+var dialects = []dialect{
+	{
+		driver: "DriverMySQL",
+		types: map[string]string{
+			"bool": "BOOLEAN", "[]byte": "BLOB", "time.Time": "DATETIME(3)",
+			"int": "INT", "int8": "SMALLINT", "int16": "SMALLINT", "int32": "INT", "int64": "BIGINT",
+			"float32": "FLOAT", "float64": "DOUBLE",
+		},
+		inlineIndex: true,
+		suffix:      " ENGINE = InnoDB DEFAULT CHARSET = utf8mb4",
+	},
+	{
+		driver: "DriverPostgres",
+		types: map[string]string{
+			"bool": "BOOLEAN", "[]byte": "BYTEA", "time.Time": "TIMESTAMP(3)",
+			"int": "INT", "int8": "SMALLINT", "int16": "SMALLINT", "int32": "INT", "int64": "BIGINT",
+			"float32": "REAL", "float64": "DOUBLE PRECISION",
+		},
+	},
+	{
+		driver: "DriverSQLite",
+		types: map[string]string{
+			"bool": "BOOLEAN", "[]byte": "BLOB", "time.Time": "DATETIME",
+			"int": "INT", "int8": "SMALLINT", "int16": "SMALLINT", "int32": "INT", "int64": "BIGINT",
+			"float32": "REAL", "float64": "REAL",
+		},
+	},
+}
+
+// sqlType 列在该数据库中的类型
+func (d dialect) sqlType(c column) string {
+	if c.kind != "string" {
+		return d.types[c.kind]
+	}
+	if c.text {
+		return "TEXT"
+	}
+	return fmt.Sprintf("VARCHAR(%d)", c.size)
+}
+
+// schema 返回建表语句，语句末尾不带分号
+// 所有语句都可以重复执行: CREATE TABLE/INDEX 带 IF NOT EXISTS，MySQL的索引写在建表语句中
+func (d dialect) schema(e *entity) []string {
+	nameWidth, typeWidth := 0, 0
+	for _, c := range e.Columns {
+		nameWidth = max(nameWidth, len(c.Name))
+		typeWidth = max(typeWidth, len(d.sqlType(c)))
+	}
+
+	var lines, indexes []string
+	for _, c := range e.Columns {
+		null := "NOT NULL"
+		if c.null {
+			null = "NULL DEFAULT NULL"
+		}
+		lines = append(lines, fmt.Sprintf("    %-*s %-*s %s", nameWidth, c.Name, typeWidth, d.sqlType(c), null))
+	}
+	lines = append(lines, fmt.Sprintf("    PRIMARY KEY (%s)", e.PK.Name))
+	for _, c := range e.Columns {
+		switch {
+		case c.unique:
+			lines = append(lines, fmt.Sprintf("    CONSTRAINT uk_%s_%s UNIQUE (%s)", e.Table, c.Name, c.Name))
+		case c.index && d.inlineIndex:
+			lines = append(lines, fmt.Sprintf("    INDEX idx_%s_%s (%s)", e.Table, c.Name, c.Name))
+		case c.index:
+			indexes = append(indexes, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s ON %s (%s)", e.Table, c.Name, e.Table, c.Name))
+		}
+	}
+
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n)%s", e.Table, strings.Join(lines, ",\n"), d.suffix)
+	return append([]string{create}, indexes...)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// genSuffix 生成的文件名后缀，解析结构体时跳过这些文件
+const genSuffix = "_gen.go"
+
+// ============================= 仓储代码生成 ====================
+// 按结构体的db标签生成sqlx仓储(Get/List/Insert/Update/Delete)和MySQL、PostgreSQL、SQLite的建表语句
+// 在结构体所在的文件中添加:
+//
+//	//go:generate go run Gocommunity/database/mysql/cmd/sqlxgen -type Book -table books
+//
+// 然后执行 go generate ./database/mysql/...，生成的代码写入同目录的 book_gen.go
+//
+// 列名与sqlx的映射一致: db标签，没有标签时为小写的字段名，db:"-" 跳过
+// 建表所需的额外信息写在ddl标签中，选项用逗号分隔:
+//
+//	pk       主键，必须有且只有一个
+//	unique   唯一约束
+//	index    普通索引
+//	size=N   字符串列的长度，默认255
+//	text     字符串列使用TEXT类型
+//
+// 指针类型的字段允许NULL，其它列都是NOT NULL；表名和列名不加引号，不要使用数据库的保留字
+// 建表语句只用于新建表，表结构变更仍需要在 migrations 中新增迁移
+func main() {
+	typeName := flag.String("type", "", "结构体名，必填")
+	table := flag.String("table", "", "表名，默认为结构体名的蛇形复数形式，例如 Book -> books")
+	dir := flag.String("dir", ".", "结构体所在的目录，go generate 执行时为当前文件所在的目录")
+	output := flag.String("output", "", "输出文件，默认为 <结构体名的蛇形形式>_gen.go")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "用法: %s -type 结构体名 [-table 表名] [-output 文件名]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	log.SetPrefix("sqlxgen: ")
+	log.SetFlags(0)
+	if *table == "" {
+		*table = snakeCase(*typeName) + "s"
+	}
+	if *output == "" {
+		*output = snakeCase(*typeName) + genSuffix
+	}
+	if !strings.HasSuffix(*output, genSuffix) {
+		log.Fatalf("输出文件名必须以 %s 结尾，解析结构体时依靠后缀跳过生成的文件", genSuffix)
+	}
+
+	e, err := loadEntity(*dir, *typeName, *table)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(e)
+	if err != nil {
+		log.Fatal(err)
+	}
+	path := filepath.Join(*dir, *output)
+	if err := os.WriteFile(path, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("sqlxgen: %s -> %s (表 %s，%d列)\n", e.Type, path, e.Table, len(e.Columns))
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "重新生成entity目录中的 *_gen.go")
+
+// entityDir 提交了生成代码的目录
+const entityDir = "../../entity"
+
+// generateDirective entity目录中一条 go:generate 指令的参数
+type generateDirective struct {
+	source               string
+	typeName, table, out string
+}
+
+// directives 读取目录中调用sqlxgen的 go:generate 指令，新增的实体不需要修改测试
+func directives(t *testing.T, dir string) []generateDirective {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []generateDirective
+	for _, path := range files {
+		if strings.HasSuffix(path, genSuffix) {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line, ok := strings.CutPrefix(scanner.Text(), "//go:generate ")
+			if !ok || !strings.Contains(line, "cmd/sqlxgen") {
+				continue
+			}
+			fields := strings.Fields(line)
+			fs := flag.NewFlagSet("sqlxgen", flag.ContinueOnError)
+			d := generateDirective{source: filepath.Base(path)}
+			fs.StringVar(&d.typeName, "type", "", "")
+			fs.StringVar(&d.table, "table", "", "")
+			fs.StringVar(&d.out, "output", "", "")
+			// go run <包> 之后是sqlxgen的参数
+			if err := fs.Parse(fields[3:]); err != nil {
+				t.Fatalf("%s: 无法解析 %q: %v", path, line, err)
+			}
+			if d.table == "" {
+				d.table = snakeCase(d.typeName) + "s"
+			}
+			if d.out == "" {
+				d.out = snakeCase(d.typeName) + genSuffix
+			}
+			out = append(out, d)
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+// TestGolden 重新生成entity中的代码，与提交的文件逐字节比较
+// 修改模板后执行 go test ./database/mysql/cmd/sqlxgen -update 更新生成的文件
+func TestGolden(t *testing.T) {
+	ds := directives(t, entityDir)
+	if len(ds) == 0 {
+		t.Fatalf("%s 中没有 sqlxgen 的 go:generate 指令", entityDir)
+	}
+	for _, d := range ds {
+		t.Run(d.typeName, func(t *testing.T) {
+			e, err := loadEntity(entityDir, d.typeName, d.table)
+			if err != nil {
+				t.Fatalf("%s: loadEntity: %v", d.source, err)
+			}
+			got, err := generate(e)
+			if err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+
+			path := filepath.Join(entityDir, d.out)
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("读取生成的文件: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s 与模板生成的代码不一致(第%d行)，执行 go generate ./database/mysql/entity 或 go test -update 重新生成",
+					path, firstDiffLine(got, want))
+			}
+		})
+	}
+}
+
+// firstDiffLine 返回第一处不同所在的行号，从1开始
+func firstDiffLine(a, b []byte) int {
+	n := min(len(a), len(b))
+	i := 0
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return bytes.Count(a[:i], []byte("\n")) + 1
+}
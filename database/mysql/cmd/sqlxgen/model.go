@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ============================= 1. 结构体解析 ====================
+// column 一个字段对应的列
+type column struct {
+	Field string // Go字段名
+	Name  string // 列名，来自db标签
+	Type  string // Go类型，例如 string、*time.Time
+
+	kind   string // 去掉指针后的类型，决定列的SQL类型
+	null   bool   // 指针类型的列允许NULL
+	pk     bool
+	unique bool
+	index  bool
+	text   bool
+	size   int
+}
+
+// entity 一个结构体和它对应的表
+type entity struct {
+	Package string
+	Source  string // 结构体所在的文件名
+	Type    string
+	Table   string
+	Columns []column
+	PK      column
+}
+
+// defaultSize 没有指定size的字符串列的长度
+const defaultSize = 255
+
+// supportedKinds 支持的字段类型，其它类型需要自己实现数据访问
+var supportedKinds = map[string]bool{
+	"string": true, "bool": true, "[]byte": true, "time.Time": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"float32": true, "float64": true,
+}
+
+// loadEntity 在dir的Go文件中查找名为typeName的结构体，按db和ddl标签生成列定义
+// 跳过测试文件和已生成的文件，生成的文件有语法错误时也能重新生成
+func loadEntity(dir, typeName, table string) (*entity, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || strings.HasSuffix(name, genSuffix) {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		st := findStruct(file, typeName)
+		if st == nil {
+			continue
+		}
+		e := &entity{Package: file.Name.Name, Source: name, Type: typeName, Table: table}
+		if err := e.parseFields(st); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", name, typeName, err)
+		}
+		return e, nil
+	}
+	return nil, fmt.Errorf("在 %s 中没有找到结构体 %s", dir, typeName)
+}
+
+func findStruct(file *ast.File, name string) *ast.StructType {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if st, ok := ts.Type.(*ast.StructType); ok && ts.Name.Name == name {
+				return st
+			}
+		}
+	}
+	return nil
+}
+
+// parseFields 列名与sqlx的映射规则一致: 使用db标签，没有标签时使用小写的字段名，db:"-" 的字段不是列
+func (e *entity) parseFields(st *ast.StructType) error {
+	pks := 0
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 {
+			return fmt.Errorf("不支持嵌入字段 %s", types.ExprString(field.Type))
+		}
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			raw, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return err
+			}
+			tag = reflect.StructTag(raw)
+		}
+		for _, ident := range field.Names {
+			if !ident.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(tag.Get("db"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(ident.Name)
+			}
+			col := column{Field: ident.Name, Name: name, Type: types.ExprString(field.Type), size: defaultSize}
+			col.kind, col.null = strings.CutPrefix(col.Type, "*")
+			if !supportedKinds[col.kind] {
+				return fmt.Errorf("字段 %s 的类型 %s 不受支持", ident.Name, col.Type)
+			}
+			if err := col.parseDDL(tag.Get("ddl")); err != nil {
+				return fmt.Errorf("字段 %s: %w", ident.Name, err)
+			}
+			if col.pk {
+				pks++
+				e.PK = col
+			}
+			e.Columns = append(e.Columns, col)
+		}
+	}
+
+	switch {
+	case pks != 1:
+		return fmt.Errorf("需要恰好一个 ddl:\"pk\" 字段，实际有%d个", pks)
+	case e.PK.null:
+		return fmt.Errorf("主键 %s 不能是指针类型", e.PK.Field)
+	case len(e.Columns) < 2:
+		return fmt.Errorf("除主键外至少需要一列")
+	}
+	return nil
+}
+
+// parseDDL 解析ddl标签，选项用逗号分隔:
+//
+//	pk       主键
+//	unique   唯一约束
+//	index    普通索引
+//	size=N   字符串列的长度，默认255
+//	text     字符串列使用TEXT类型
+func (c *column) parseDDL(tag string) error {
+	if tag == "" {
+		return nil
+	}
+	for _, opt := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch key {
+		case "pk":
+			c.pk = true
+		case "unique":
+			c.unique = true
+		case "index":
+			c.index = true
+		case "text":
+			c.text = true
+		case "size":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("size必须是正整数: %q", value)
+			}
+			c.size = n
+		default:
+			return fmt.Errorf("未知的ddl选项 %q", opt)
+		}
+	}
+	if (c.text || c.size != defaultSize) && c.kind != "string" {
+		return fmt.Errorf("size和text只能用于字符串列")
+	}
+	if c.pk && c.text {
+		return fmt.Errorf("主键不能使用TEXT类型")
+	}
+	return nil
+}
+
+// ============================= 2. 命名 ====================
+// paramName 主键作为方法参数时的名字: ISDN -> isdn，UserID -> userID，与关键字或生成代码中的变量冲突时加下划线
+func paramName(field string) string {
+	runes := []rune(field)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	if upper > 1 && upper < len(runes) {
+		upper-- // 保留下一个单词的首字母: URLPath -> urlPath
+	}
+	name := strings.ToLower(string(runes[:upper])) + string(runes[upper:])
+	if token.IsKeyword(name) || generatedNames[name] {
+		name += "_"
+	}
+	return name
+}
+
+// generatedNames 生成的方法中已经使用的变量名，主键参数不能与它们重名
+var generatedNames = map[string]bool{"ctx": true, "cancel": true, "r": true, "v": true, "err": true, "result": true}
+
+// snakeCase 类型名转为文件名和默认表名使用的形式: BookAuthor -> book_author
+func snakeCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
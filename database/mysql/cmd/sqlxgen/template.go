@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"text/template"
+)
+
+// ============================= 生成代码 ====================
+// repoData 模板使用的数据，SQL在生成时拼好，生成的代码中都是常量
+type repoData struct {
+	*entity
+	Prefix     string // 未导出常量的前缀，例如 book
+	Param      string // 主键参数名
+	ColumnList string
+	InsertSQL  string
+	UpdateSQL  string
+	Schemas    []schemaData
+}
+
+type schemaData struct {
+	Driver     string
+	Statements []string
+}
+
+func newRepoData(e *entity) repoData {
+	data := repoData{entity: e, Prefix: paramName(e.Type), Param: paramName(e.PK.Field)}
+	names := make([]string, len(e.Columns))
+	params := make([]string, len(e.Columns))
+	var sets []string
+	for i, c := range e.Columns {
+		names[i], params[i] = c.Name, ":"+c.Name
+		if !c.pk {
+			sets = append(sets, c.Name+" = :"+c.Name)
+		}
+	}
+	data.ColumnList = strings.Join(names, ", ")
+	data.InsertSQL = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", e.Table, data.ColumnList, strings.Join(params, ", "))
+	data.UpdateSQL = fmt.Sprintf("UPDATE %s SET %s WHERE %s = :%s", e.Table, strings.Join(sets, ", "), e.PK.Name, e.PK.Name)
+	for _, d := range dialects {
+		data.Schemas = append(data.Schemas, schemaData{Driver: d.driver, Statements: d.schema(e)})
+	}
+	return data
+}
+
+// generate 生成仓储代码并用gofmt格式化
+func generate(e *entity) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := repoTemplate.Execute(&buf, newRepoData(e)); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("格式化生成的代码失败: %w\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+var repoTemplate = template.Must(template.New("repo").Parse(`// Code generated by sqlxgen from {{.Source}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/store"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// {{.Table}}表的SQL，列的顺序与{{.Type}}的字段一致
+const (
+	{{.Prefix}}Columns = "{{.ColumnList}}"
+	{{.Prefix}}Insert  = "{{.InsertSQL}}"
+	{{.Prefix}}Update  = "{{.UpdateSQL}}"
+)
+
+// {{.Type}}Schema {{.Table}}表的建表语句，按驱动名索引，由{{.Type}}的db和ddl标签生成
+var {{.Type}}Schema = map[string][]string{
+{{- range .Schemas}}
+	dbx.{{.Driver}}: {
+	{{- range .Statements}}
+		` + "`{{.}}`" + `,
+	{{- end}}
+	},
+{{- end}}
+}
+
+// {{.Type}}Repository {{.Table}}表的增删改查，db可以是*sqlx.DB、*dbx.Cluster，也可以是*sqlx.Tx
+// 写入使用命名参数，由sqlx按驱动转换占位符；错误按 store 包的哨兵错误归类
+type {{.Type}}Repository struct {
+	db      sqlx.ExtContext
+	timeout time.Duration
+}
+
+// New{{.Type}}Repository 创建仓储，timeout是单条SQL的超时，<=0表示只依赖调用方的context
+func New{{.Type}}Repository(db sqlx.ExtContext, timeout time.Duration) *{{.Type}}Repository {
+	return &{{.Type}}Repository{db: db, timeout: timeout}
+}
+
+// CreateTable 执行当前数据库的建表语句，表已存在时不做处理，用于测试和本地开发
+func (r *{{.Type}}Repository) CreateTable(ctx context.Context) error {
+	dialect, err := store.DialectFor(r.db.DriverName())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	for _, stmt := range {{.Type}}Schema[dialect.Name] {
+		if _, err := r.db.ExecContext(ctx, stmt); err != nil {
+			return store.TranslateError("创建{{.Table}}表失败", err)
+		}
+	}
+	return nil
+}
+
+// Get 按主键查询，记录不存在时返回 store.ErrNotFound
+func (r *{{.Type}}Repository) Get(ctx context.Context, {{.Param}} {{.PK.Type}}) (*{{.Type}}, error) {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var v {{.Type}}
+	if err := sqlx.GetContext(ctx, r.db, &v, r.db.Rebind("SELECT "+{{.Prefix}}Columns+" FROM {{.Table}} WHERE {{.PK.Name}} = ?"), {{.Param}}); err != nil {
+		return nil, store.TranslateError("查询{{.Type}}失败", err)
+	}
+	return &v, nil
+}
+
+// List 按主键排序分页查询
+func (r *{{.Type}}Repository) List(ctx context.Context, limit, offset int) ([]{{.Type}}, error) {
+	if limit <= 0 || offset < 0 {
+		return nil, fmt.Errorf("%w: limit必须大于0，offset不能为负数", store.ErrInvalidQuery)
+	}
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var list []{{.Type}}
+	if err := sqlx.SelectContext(ctx, r.db, &list, r.db.Rebind("SELECT "+{{.Prefix}}Columns+" FROM {{.Table}} ORDER BY {{.PK.Name}} LIMIT ? OFFSET ?"), limit, offset); err != nil {
+		return nil, store.TranslateError("查询{{.Type}}列表失败", err)
+	}
+	return list, nil
+}
+
+// Insert 插入一条记录，主键已存在时返回 store.ErrDuplicateKey
+func (r *{{.Type}}Repository) Insert(ctx context.Context, v *{{.Type}}) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if _, err := sqlx.NamedExecContext(ctx, r.db, {{.Prefix}}Insert, v); err != nil {
+		return store.TranslateError("插入{{.Type}}失败", err)
+	}
+	return nil
+}
+
+// Update 按主键更新其它所有列，记录不存在时返回 store.ErrNotFound
+// MySQL的DSN需要 clientFoundRows=true，否则更新为相同的值也会被当作记录不存在
+func (r *{{.Type}}Repository) Update(ctx context.Context, v *{{.Type}}) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := sqlx.NamedExecContext(ctx, r.db, {{.Prefix}}Update, v)
+	if err != nil {
+		return store.TranslateError("更新{{.Type}}失败", err)
+	}
+	return r.expectOne("更新{{.Type}}失败", result)
+}
+
+// Delete 按主键删除，记录不存在时返回 store.ErrNotFound
+func (r *{{.Type}}Repository) Delete(ctx context.Context, {{.Param}} {{.PK.Type}}) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind("DELETE FROM {{.Table}} WHERE {{.PK.Name}} = ?"), {{.Param}})
+	if err != nil {
+		return store.TranslateError("删除{{.Type}}失败", err)
+	}
+	return r.expectOne("删除{{.Type}}失败", result)
+}
+
+func (r *{{.Type}}Repository) expectOne(op string, result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return store.TranslateError(op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, store.ErrNotFound)
+	}
+	return nil
+}
+`))
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/store"
+	"Gocommunity/database/mysql/tenant"
+	"Gocommunity/database/mysql/transfer"
+)
+
+// ============================= 导入导出命令 ====================
+// 用法:
+//
+//	go run ./database/mysql/cmd/transfer [-config 配置文件] [-deleted] export [文件，默认标准输出]
+//	go run ./database/mysql/cmd/transfer [-mode upsert|insert] [-chunk 500] [-dry-run] import 文件
+//
+// 文件格式按扩展名(.csv/.jsonl/.ndjson)推断，使用标准输入输出时需要 -format 指定
+// 多租户的表用 -tenant 指定租户，导出文件中不包含租户
+// 导入时有行失败则退出码为1，失败的行和原因输出到标准错误
+func main() {
+	configPath := flag.String("config", "", "数据库配置文件(JSON)，默认读取 DB_CONFIG_FILE")
+	format := flag.String("format", "", "文件格式 csv|jsonl，默认按扩展名推断")
+	deleted := flag.Bool("deleted", false, "export: 同时导出已软删除的记录")
+	mode := flag.String("mode", string(transfer.ModeUpsert), "import: upsert按id新建或更新，insert只新建")
+	chunk := flag.Int("chunk", transfer.DefaultChunkSize, "import: 每个事务写入的行数")
+	dryRun := flag.Bool("dry-run", false, "import: 只校验并输出将会产生的结果，不提交")
+	maxErrors := flag.Int("max-errors", 100, "import: 失败行数达到该值时停止，0表示不限制")
+	tenantID := flag.String("tenant", "", "只导出或导入该租户的数据，配置了multi_tenant时必须指定")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "用法: %s [参数] export [文件]|import 文件\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, path := flag.Arg(0), flag.Arg(1)
+	if cmd == "import" && path == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f := transfer.Format(*format)
+	if f == "" {
+		var err error
+		if f, err = transfer.FormatOf(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Ctrl+C 时取消查询，导入已提交的块保留
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *tenantID != "" {
+		if err := tenant.Validate(*tenantID); err != nil {
+			log.Fatal(err)
+		}
+		ctx = tenant.NewContext(ctx, *tenantID)
+	}
+	cfg, err := dbx.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("加载数据库配置失败: %v", err)
+	}
+	db, err := dbx.Open(ctx, cfg)
+	if err != nil {
+		log.Fatalf("连接数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	switch cmd {
+	case "export":
+		var w io.Writer = os.Stdout
+		if path != "" && path != "-" {
+			file, err := os.Create(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer file.Close()
+			w = file
+		}
+		n, err := transfer.Export(ctx, db, w, transfer.ExportOptions{Format: f, IncludeDeleted: *deleted, Tenant: *tenantID})
+		if err != nil {
+			log.Fatalf("导出失败(已导出%d行): %v", n, err)
+		}
+		log.Printf("已导出%d行", n)
+
+	case "import":
+		var r io.Reader = os.Stdin
+		if path != "-" {
+			file, err := os.Open(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer file.Close()
+			r = file
+		}
+		result, err := transfer.Import(ctx, db, r, transfer.ImportOptions{
+			Format:      f,
+			Mode:        transfer.Mode(*mode),
+			ChunkSize:   *chunk,
+			DryRun:      *dryRun,
+			MaxErrors:   *maxErrors,
+			RepoOptions: store.ConfigOptions(cfg),
+		})
+		if result != nil {
+			for _, e := range result.Errors {
+				fmt.Fprintln(os.Stderr, e)
+			}
+			prefix := ""
+			if *dryRun {
+				prefix = "[dry-run] "
+			}
+			fmt.Printf("%s%s\n", prefix, result)
+		}
+		if err != nil {
+			log.Fatalf("导入中止: %v", err)
+		}
+		if len(result.Errors) > 0 {
+			os.Exit(1)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
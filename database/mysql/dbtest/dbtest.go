@@ -0,0 +1,212 @@
+// Package dbtest 为数据层测试提供隔离的数据库，不依赖Docker或testcontainers
+//
+// 每次调用 New 都会得到一个独立的、已经执行过迁移的空库，测试结束时自动删除:
+//
+//	func TestGetPerson(t *testing.T) {
+//		db := dbtest.New(t, dbtest.WithPersons(dbtest.DemoPersons...))
+//		repo := store.NewSQLPersonRepository(db)
+//		p, err := repo.Get(context.Background(), "12132")
+//		...
+//	}
+//
+// 数据库由环境变量 DBTEST_DRIVER 选择:
+//
+//	未设置或sqlite  进程内的SQLite(modernc.org/sqlite)，每个测试一个临时文件，CI中无需任何服务
+//	mysql/postgres  按 DB_* 环境变量连接服务器(任何MySQL兼容的服务器都可以)，
+//	                每个测试创建一个 dbtest_ 开头的库，测试结束后DROP
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/migrate"
+	"Gocommunity/database/mysql/migrations"
+	"Gocommunity/database/mysql/store"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// setupTimeout 建库、迁移和加载测试数据的超时时间
+const setupTimeout = 30 * time.Second
+
+// DemoPersons 与 fixtures/common 中的种子数据一致，database/mysql/main.go 的演示依赖这些记录
+var DemoPersons = []store.Person{
+	{UserId: "12132", Username: "张三", Age: 18, Address: "北京市"},
+	{UserId: "12133", Username: "小明", Age: 25, Address: "上海市"},
+	{UserId: "12134", Username: "小红", Age: 31, Address: "广州市"},
+}
+
+// dbSeq 同一进程内生成不重复的库名
+var dbSeq atomic.Int64
+
+// ============================= 1. 选项 ====================
+type options struct {
+	driver  string
+	persons []store.Person
+}
+
+// Option 创建测试库时的可选配置
+type Option func(*options)
+
+// WithDriver 指定数据库驱动，覆盖环境变量 DBTEST_DRIVER
+func WithDriver(driver string) Option {
+	return func(o *options) { o.driver = driver }
+}
+
+// WithPersons 迁移完成后写入的测试数据，见 InsertPersons
+func WithPersons(persons ...store.Person) Option {
+	return func(o *options) { o.persons = append(o.persons, persons...) }
+}
+
+// ============================= 2. 创建测试库 ====================
+// New 创建一个独立的测试库并执行迁移，失败时调用 t.Fatal
+// 返回的连接池在测试结束时关闭，服务器上的测试库同时被删除
+func New(t testing.TB, opts ...Option) *sqlx.DB {
+	t.Helper()
+
+	o := options{driver: os.Getenv("DBTEST_DRIVER")}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.driver == "" {
+		o.driver = dbx.DriverSQLite
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), setupTimeout)
+	defer cancel()
+
+	var db *sqlx.DB
+	switch o.driver {
+	case dbx.DriverSQLite:
+		db = openSQLite(ctx, t)
+	case dbx.DriverMySQL, dbx.DriverPostgres:
+		db = openServer(ctx, t, o.driver)
+	default:
+		t.Fatalf("dbtest: 不支持的驱动 %q", o.driver)
+	}
+
+	if err := migrateUp(ctx, db, o.driver); err != nil {
+		t.Fatalf("dbtest: 执行迁移失败: %v", err)
+	}
+	if len(o.persons) > 0 {
+		if err := InsertPersons(ctx, db, o.persons...); err != nil {
+			t.Fatalf("dbtest: 写入测试数据失败: %v", err)
+		}
+	}
+	return db
+}
+
+// openSQLite 在测试的临时目录中创建数据库文件，目录随测试结束删除
+// 使用文件而不是内存库，连接池中的多个连接才能看到同一份数据
+func openSQLite(ctx context.Context, t testing.TB) *sqlx.DB {
+	cfg := dbx.DefaultConfig()
+	cfg.Driver = dbx.DriverSQLite
+	cfg.Database = filepath.Join(t.TempDir(), "test.db")
+	cfg.PingRetries = 0
+
+	db, err := dbx.Open(ctx, cfg)
+	if err != nil {
+		t.Fatalf("dbtest: 打开SQLite失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// openServer 使用 DB_* 配置连接服务器，创建一个新库并连接到该库
+// 配置中的库只用于执行 CREATE DATABASE / DROP DATABASE
+func openServer(ctx context.Context, t testing.TB, driver string) *sqlx.DB {
+	cfg, err := dbx.LoadConfig("")
+	if err != nil {
+		t.Fatalf("dbtest: 加载数据库配置失败: %v", err)
+	}
+	cfg.Driver = driver
+	cfg.Replicas = nil
+	cfg.PingRetries = 0
+
+	admin, err := dbx.Open(ctx, cfg)
+	if err != nil {
+		t.Fatalf("dbtest: 连接%s失败: %v", driver, err)
+	}
+
+	// 库名只包含字母、数字和下划线，可以直接拼接
+	name := fmt.Sprintf("dbtest_%d_%d_%d", os.Getpid(), time.Now().UnixNano()%1e9, dbSeq.Add(1))
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+name); err != nil {
+		admin.Close()
+		t.Fatalf("dbtest: 创建测试库失败: %v", err)
+	}
+
+	cfg.Database = name
+	db, err := dbx.Open(ctx, cfg)
+	if err != nil {
+		dropDatabase(t, admin, name)
+		t.Fatalf("dbtest: 连接测试库失败: %v", err)
+	}
+	// Cleanup按注册的逆序执行: 先关闭测试库的连接，PostgreSQL才能删除该库
+	t.Cleanup(func() { dropDatabase(t, admin, name) })
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// dropDatabase 删除测试库并关闭管理连接，失败只记录日志，不影响测试结果
+func dropDatabase(t testing.TB, admin *sqlx.DB, name string) {
+	defer admin.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), setupTimeout)
+	defer cancel()
+	if _, err := admin.ExecContext(ctx, "DROP DATABASE IF EXISTS "+name); err != nil {
+		t.Logf("dbtest: 删除测试库 %s 失败: %v", name, err)
+	}
+}
+
+// migrateUp 执行内嵌的迁移文件，测试库与正式库的表结构完全一致
+func migrateUp(ctx context.Context, db *sqlx.DB, driver string) error {
+	source, err := migrations.For(driver)
+	if err != nil {
+		return err
+	}
+	runner, err := migrate.New(db, source)
+	if err != nil {
+		return err
+	}
+	_, err = runner.Up(ctx)
+	return err
+}
+
+// ============================= 3. 测试数据 ====================
+// InsertPersons 按原样写入测试数据，包括deleted_at和version，可以构造已软删除或特定版本的记录
+// CreatedAt为零值时使用当前时间，UpdatedAt为零值时与CreatedAt相同，Version为0时为1
+func InsertPersons(ctx context.Context, db sqlx.ExtContext, persons ...store.Person) error {
+	if len(persons) == 0 {
+		return nil
+	}
+	dialect, err := store.DialectFor(db.DriverName())
+	if err != nil {
+		return err
+	}
+
+	rows := make([]store.Person, len(persons))
+	t := time.Now().UTC().Truncate(time.Millisecond)
+	for i, p := range persons {
+		if p.CreatedAt.IsZero() {
+			p.CreatedAt = t
+		}
+		if p.UpdatedAt.IsZero() {
+			p.UpdatedAt = p.CreatedAt
+		}
+		if p.Version == 0 {
+			p.Version = 1
+		}
+		rows[i] = p
+	}
+
+	_, err = sqlx.NamedExecContext(ctx, db,
+		`INSERT INTO `+dialect.Table()+` (id, name, age, address, created_at, updated_at, deleted_at, version)
+		VALUES (:id, :name, :age, :address, :created_at, :updated_at, :deleted_at, :version)`, rows)
+	return err
+}
@@ -0,0 +1,59 @@
+package dbtest_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"Gocommunity/database/mysql/dbtest"
+	"Gocommunity/database/mysql/store"
+)
+
+func TestNewWithPersons(t *testing.T) {
+	t.Parallel()
+	deletedAt := time.Now().UTC().Truncate(time.Millisecond)
+	deleted := store.Person{UserId: "deleted", Username: "已删除", Age: 20, DeletedAt: &deletedAt, Version: 3}
+	db := dbtest.New(t, dbtest.WithPersons(dbtest.DemoPersons...), dbtest.WithPersons(deleted))
+	repo := store.NewSQLPersonRepository(db)
+	ctx := t.Context()
+
+	page, err := repo.List(ctx, store.ListOptions{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if page.Total != len(dbtest.DemoPersons) {
+		t.Errorf("未删除的记录 %d 条, want %d", page.Total, len(dbtest.DemoPersons))
+	}
+
+	// InsertPersons 按原样写入deleted_at和version
+	page, err = repo.List(ctx, store.ListOptions{Deleted: store.OnlyDeleted})
+	if err != nil {
+		t.Fatalf("List(deleted): %v", err)
+	}
+	if page.Total != 1 || page.Items[0].Version != 3 || !page.Items[0].DeletedAt.Equal(deletedAt) {
+		t.Errorf("已删除的记录 = %+v", page.Items)
+	}
+}
+
+// TestNewIsolated 并行的测试各自得到独立的库: 写入相同的主键互不冲突，也看不到对方的数据
+func TestNewIsolated(t *testing.T) {
+	for i := range 4 {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+			repo := store.NewSQLPersonRepository(dbtest.New(t))
+			ctx := t.Context()
+
+			name := fmt.Sprintf("测试%d", i)
+			if err := repo.Create(ctx, &store.Person{UserId: "same", Username: name, Age: i}); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			page, err := repo.List(ctx, store.ListOptions{})
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if page.Total != 1 || page.Items[0].Username != name {
+				t.Errorf("List = %+v, want 只有 %s", page.Items, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ============================= 1. 读写分离连接 ====================
+// Cluster 包装一个主库和多个只读从库:
+//   - Query/Get/Select 轮询发往健康的从库，没有健康从库时回退到主库
+//   - Exec 和事务(BeginTxx) 总是发往主库
+//
+// Cluster 实现了 sqlx.ExtContext，可以直接传给 store.NewSQLPersonRepository
+// 注意从库存在复制延迟，刚写入的数据需要通过 WithReadYourWrites/WithPrimary 从主库读取
+type Cluster struct {
+	primary  *sqlx.DB
+	replicas []*replica
+	next     atomic.Uint64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+var _ DB = (*Cluster)(nil)
+
+// replica 一个从库及其健康状态
+type replica struct {
+	db      *sqlx.DB
+	name    string
+	healthy atomic.Bool
+}
+
+// NewCluster 使用已经打开的连接池创建读写分离连接，并按interval在后台检查从库健康状态
+// 从库初始状态为健康，检查失败后不再接收读请求，恢复后自动重新加入
+func NewCluster(primary *sqlx.DB, replicas map[string]*sqlx.DB, interval time.Duration) *Cluster {
+	c := &Cluster{primary: primary, stop: make(chan struct{})}
+	for name, db := range replicas {
+		r := &replica{db: db, name: name}
+		r.healthy.Store(true)
+		c.replicas = append(c.replicas, r)
+	}
+
+	if len(c.replicas) > 0 && interval > 0 {
+		c.wg.Add(1)
+		go c.healthLoop(interval)
+	}
+	return c
+}
+
+// OpenCluster 按配置打开主库和从库，主库不可用时返回错误
+// 从库启动时不可用不会导致失败，由健康检查决定是否使用
+func OpenCluster(ctx context.Context, cfg Config) (*Cluster, error) {
+	primary, err := Open(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make(map[string]*sqlx.DB, len(cfg.Replicas))
+	for _, addr := range cfg.Replicas {
+		rcfg, err := cfg.ReplicaConfig(addr)
+		if err == nil {
+			replicas[addr], err = openPool(rcfg)
+		}
+		if err != nil {
+			primary.Close()
+			for _, db := range replicas {
+				db.Close()
+			}
+			return nil, err
+		}
+	}
+	return NewCluster(primary, replicas, time.Duration(cfg.ReplicaCheckInterval)), nil
+}
+
+// Primary 返回主库连接池，用于迁移等只能在主库执行的操作
+func (c *Cluster) Primary() *sqlx.DB {
+	return c.primary
+}
+
+// Pools 返回所有连接池，key为 "primary" 或从库地址，用于上报连接池状态
+func (c *Cluster) Pools() map[string]*sqlx.DB {
+	pools := map[string]*sqlx.DB{"primary": c.primary}
+	for _, r := range c.replicas {
+		pools["replica "+r.name] = r.db
+	}
+	return pools
+}
+
+// Close 停止健康检查并关闭所有连接池
+func (c *Cluster) Close() error {
+	close(c.stop)
+	c.wg.Wait()
+
+	errs := []error{c.primary.Close()}
+	for _, r := range c.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// ============================= 2. 读写路由 ====================
+type routeKey struct{}
+
+// routeState 单个请求的路由状态
+type routeState struct {
+	forcePrimary bool
+	wrote        atomic.Bool
+}
+
+// WithPrimary 让ctx上的所有读请求都发往主库
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routeKey{}, &routeState{forcePrimary: true})
+}
+
+// WithReadYourWrites 开启"读己之写": 在ctx上发生过写操作后，后续的读请求发往主库
+// 一般在HTTP中间件中为每个请求调用一次
+func WithReadYourWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(routeKey{}).(*routeState); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, routeKey{}, &routeState{})
+}
+
+// reader 选择执行读请求的连接池
+func (c *Cluster) reader(ctx context.Context) *sqlx.DB {
+	if state, ok := ctx.Value(routeKey{}).(*routeState); ok && (state.forcePrimary || state.wrote.Load()) {
+		return c.primary
+	}
+
+	// 从上次的位置开始轮询，跳过不健康的从库
+	n := uint64(len(c.replicas))
+	start := c.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if r := c.replicas[(start+i)%n]; r.healthy.Load() {
+			return r.db
+		}
+	}
+	return c.primary
+}
+
+// markWrite 记录ctx上发生过写操作
+func markWrite(ctx context.Context) {
+	if state, ok := ctx.Value(routeKey{}).(*routeState); ok {
+		state.wrote.Store(true)
+	}
+}
+
+func (c *Cluster) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.reader(ctx).QueryContext(ctx, query, args...)
+}
+
+func (c *Cluster) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return c.reader(ctx).QueryxContext(ctx, query, args...)
+}
+
+func (c *Cluster) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	return c.reader(ctx).QueryRowxContext(ctx, query, args...)
+}
+
+// ExecContext 写操作发往主库
+func (c *Cluster) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	markWrite(ctx)
+	return c.primary.ExecContext(ctx, query, args...)
+}
+
+// BeginTxx 事务中的读写都在主库执行
+func (c *Cluster) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	markWrite(ctx)
+	return c.primary.BeginTxx(ctx, opts)
+}
+
+// PingContext 检查主库是否可用，从库的状态由后台健康检查维护
+func (c *Cluster) PingContext(ctx context.Context) error {
+	return c.primary.PingContext(ctx)
+}
+
+func (c *Cluster) DriverName() string {
+	return c.primary.DriverName()
+}
+
+func (c *Cluster) Rebind(query string) string {
+	return c.primary.Rebind(query)
+}
+
+func (c *Cluster) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return c.primary.BindNamed(query, arg)
+}
+
+// ============================= 3. 从库健康检查 ====================
+// ReplicaStatus 从库的健康状态
+type ReplicaStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+}
+
+// Replicas 返回所有从库的当前状态
+func (c *Cluster) Replicas() []ReplicaStatus {
+	statuses := make([]ReplicaStatus, 0, len(c.replicas))
+	for _, r := range c.replicas {
+		statuses = append(statuses, ReplicaStatus{Name: r.name, Healthy: r.healthy.Load()})
+	}
+	return statuses
+}
+
+func (c *Cluster) healthLoop(interval time.Duration) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.checkReplicas(interval)
+		}
+	}
+}
+
+// checkReplicas Ping每个从库，状态变化时打印日志
+func (c *Cluster) checkReplicas(timeout time.Duration) {
+	for _, r := range c.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := r.db.PingContext(ctx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("从库 %s 已恢复", r.name)
+			} else {
+				log.Printf("从库 %s 不可用，读请求暂时不再发往该从库: %v", r.name, err)
+			}
+		}
+	}
+}
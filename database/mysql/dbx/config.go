@@ -0,0 +1,378 @@
+package dbx
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 支持的数据库驱动，与 sqlx.Open 使用的驱动名一致
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres" // github.com/lib/pq
+	DriverSQLite   = "sqlite"   // modernc.org/sqlite，纯Go实现，不需要CGO
+)
+
+// ============================= 1. 配置结构定义 ====================
+// Config 数据库连接配置，包含DSN各组成部分和连接池参数
+// 可以从JSON配置文件加载，再由环境变量覆盖
+type Config struct {
+	// Driver 数据库类型: mysql(默认)、postgres、sqlite
+	// sqlite 只使用Database(数据库文件路径，":memory:"表示内存库)，忽略主机和账号
+	Driver   string            `json:"driver"`
+	Host     string            `json:"host"`
+	Port     int               `json:"port"` // 0表示使用驱动的默认端口: MySQL 3306，PostgreSQL 5432
+	User     string            `json:"user"`
+	Password string            `json:"password"`
+	Database string            `json:"database"`
+	TLS      string            `json:"tls"`    // true/false/skip-verify/preferred 或 mysql.RegisterTLSConfig 注册的名称
+	Params   map[string]string `json:"params"` // 额外的DSN参数，例如MySQL的 charset、loc
+
+	// 连接池配置
+	MaxOpenConns    int      `json:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time"`
+
+	// QueryTimeout 单条SQL的超时时间，防止慢查询一直占用调用方
+	QueryTimeout Duration `json:"query_timeout"`
+
+	// SlowQueryThreshold 超过该耗时的SQL打印慢查询日志
+	SlowQueryThreshold Duration `json:"slow_query_threshold"`
+
+	// Replicas 只读从库地址(host:port)，账号和库名与主库相同，为空表示不做读写分离
+	Replicas []string `json:"replicas"`
+	// ReplicaCheckInterval 从库健康检查间隔
+	ReplicaCheckInterval Duration `json:"replica_check_interval"`
+
+	// HealthCheckInterval 主库健康检查(HealthChecker)的Ping间隔
+	HealthCheckInterval Duration `json:"health_check_interval"`
+	// HealthFailureThreshold 连续失败多少次后判定为不可用，/readyz 返回503
+	HealthFailureThreshold int `json:"health_failure_threshold"`
+
+	// 启动时Ping重试配置，退避时间每次翻倍，最长不超过 maxPingBackoff
+	PingRetries int      `json:"ping_retries"`
+	PingBackoff Duration `json:"ping_backoff"`
+
+	// EncryptionKeys 字段加密密钥，格式为 "id:base64密钥,id:base64密钥"，第一个用于加密新数据
+	// 为空表示不加密，见 secret.ParseKeyring；与密码一样建议通过环境变量提供
+	EncryptionKeys string `json:"encryption_keys"`
+
+	// Outbox 为true时用户的增删改在同一事务中写入outbox表，由 outbox.Relay(cmd/relay)投递给下游
+	Outbox bool `json:"outbox"`
+
+	// MultiTenant 为true时user表按tenant_id隔离，所有读写都必须在context中指定租户(见 tenant 包)
+	MultiTenant bool `json:"multi_tenant"`
+}
+
+// Duration 支持在JSON中使用 "30s"、"5m" 这样的字符串表示时长
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("时长必须是字符串，例如\"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// DefaultConfig 返回本地开发使用的默认配置，密码需要通过配置文件或环境变量提供
+func DefaultConfig() Config {
+	return Config{
+		Driver:   DriverMySQL,
+		Host:     "127.0.0.1",
+		User:     "root",
+		Database: "test",
+
+		MaxOpenConns:    20,
+		MaxIdleConns:    10,
+		ConnMaxLifetime: Duration(30 * time.Minute),
+		ConnMaxIdleTime: Duration(5 * time.Minute),
+
+		QueryTimeout:       Duration(DefaultQueryTimeout),
+		SlowQueryThreshold: Duration(DefaultSlowQueryThreshold),
+
+		ReplicaCheckInterval: Duration(5 * time.Second),
+
+		HealthCheckInterval:    Duration(DefaultHealthInterval),
+		HealthFailureThreshold: DefaultFailureThreshold,
+
+		PingRetries: 5,
+		PingBackoff: Duration(200 * time.Millisecond),
+	}
+}
+
+// ============================= 2. 加载配置 ====================
+// LoadConfig 按 默认值 -> 配置文件 -> 环境变量 的顺序加载配置
+// path为空时读取环境变量 DB_CONFIG_FILE，仍为空则跳过配置文件
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	if path == "" {
+		path = os.Getenv("DB_CONFIG_FILE")
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("读取数据库配置文件失败: %w", err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("解析数据库配置文件 %s 失败: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// applyEnv 使用 DB_ 前缀的环境变量覆盖配置
+//
+//	DB_DRIVER                                   mysql/postgres/sqlite
+//	DB_HOST DB_PORT DB_USER DB_PASSWORD DB_NAME DB_TLS
+//	DB_PARAMS               例如 "charset=utf8mb4&loc=Local"
+//	DB_MAX_OPEN_CONNS DB_MAX_IDLE_CONNS
+//	DB_CONN_MAX_LIFETIME DB_CONN_MAX_IDLE_TIME  例如 "30m"
+//	DB_QUERY_TIMEOUT                            例如 "5s"
+//	DB_SLOW_QUERY_THRESHOLD                     例如 "200ms"
+//	DB_REPLICAS                                 例如 "10.0.0.2:3306,10.0.0.3:3306"
+//	DB_REPLICA_CHECK_INTERVAL                   例如 "5s"
+//	DB_HEALTH_CHECK_INTERVAL DB_HEALTH_FAILURE_THRESHOLD
+//	DB_PING_RETRIES DB_PING_BACKOFF
+//	DB_ENCRYPTION_KEYS                          例如 "k2:base64密钥,k1:base64密钥"
+//	DB_OUTBOX DB_MULTI_TENANT                   true/false
+func (c *Config) applyEnv() error {
+	setString := func(key string, dst *string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+	setInt := func(key string, dst *int) error {
+		v, ok := os.LookupEnv(key)
+		if !ok {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s=%q 不是整数", key, v)
+		}
+		*dst = n
+		return nil
+	}
+	setDuration := func(key string, dst *Duration) error {
+		v, ok := os.LookupEnv(key)
+		if !ok {
+			return nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s=%q 不是合法时长", key, v)
+		}
+		*dst = Duration(d)
+		return nil
+	}
+
+	setString("DB_DRIVER", &c.Driver)
+	setString("DB_HOST", &c.Host)
+	setString("DB_USER", &c.User)
+	setString("DB_PASSWORD", &c.Password)
+	setString("DB_NAME", &c.Database)
+	setString("DB_TLS", &c.TLS)
+	setString("DB_ENCRYPTION_KEYS", &c.EncryptionKeys)
+	if v, ok := os.LookupEnv("DB_OUTBOX"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 DB_OUTBOX=%q 不是布尔值", v)
+		}
+		c.Outbox = b
+	}
+	if v, ok := os.LookupEnv("DB_MULTI_TENANT"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 DB_MULTI_TENANT=%q 不是布尔值", v)
+		}
+		c.MultiTenant = b
+	}
+
+	if v, ok := os.LookupEnv("DB_PARAMS"); ok {
+		if c.Params == nil {
+			c.Params = make(map[string]string)
+		}
+		for _, pair := range strings.Split(v, "&") {
+			if pair == "" {
+				continue
+			}
+			key, value, found := strings.Cut(pair, "=")
+			if !found {
+				return fmt.Errorf("环境变量 DB_PARAMS 格式错误: %q", pair)
+			}
+			c.Params[key] = value
+		}
+	}
+
+	if v, ok := os.LookupEnv("DB_REPLICAS"); ok {
+		c.Replicas = nil
+		for _, addr := range strings.Split(v, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				c.Replicas = append(c.Replicas, addr)
+			}
+		}
+	}
+
+	for key, dst := range map[string]*int{
+		"DB_PORT":           &c.Port,
+		"DB_MAX_OPEN_CONNS": &c.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS": &c.MaxIdleConns,
+		"DB_PING_RETRIES":   &c.PingRetries,
+
+		"DB_HEALTH_FAILURE_THRESHOLD": &c.HealthFailureThreshold,
+	} {
+		if err := setInt(key, dst); err != nil {
+			return err
+		}
+	}
+	for key, dst := range map[string]*Duration{
+		"DB_CONN_MAX_LIFETIME":      &c.ConnMaxLifetime,
+		"DB_CONN_MAX_IDLE_TIME":     &c.ConnMaxIdleTime,
+		"DB_QUERY_TIMEOUT":          &c.QueryTimeout,
+		"DB_SLOW_QUERY_THRESHOLD":   &c.SlowQueryThreshold,
+		"DB_REPLICA_CHECK_INTERVAL": &c.ReplicaCheckInterval,
+		"DB_PING_BACKOFF":           &c.PingBackoff,
+		"DB_HEALTH_CHECK_INTERVAL":  &c.HealthCheckInterval,
+	} {
+		if err := setDuration(key, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ============================= 3. 生成DSN ====================
+// DSN 按Driver生成对应驱动的数据源字符串
+func (c Config) DSN() string {
+	switch c.DriverName() {
+	case DriverPostgres:
+		return c.postgresDSN()
+	case DriverSQLite:
+		return c.sqliteDSN()
+	default:
+		return c.mysqlDSN()
+	}
+}
+
+// mysqlDSN 生成 go-sql-driver/mysql 使用的数据源字符串
+// 固定开启 parseTime(时间列映射为time.Time) 和 clientFoundRows(UPDATE返回匹配行数)
+// 未指定charset时使用utf8mb4
+func (c Config) mysqlDSN() string {
+	mc := mysql.NewConfig()
+	mc.User = c.User
+	mc.Passwd = c.Password
+	mc.Net = "tcp"
+	mc.Addr = c.addr()
+	mc.DBName = c.Database
+	mc.TLSConfig = c.TLS
+	mc.ParseTime = true
+	mc.ClientFoundRows = true
+	mc.Params = map[string]string{"charset": "utf8mb4"}
+	for k, v := range c.Params {
+		mc.Params[k] = v
+	}
+	return mc.FormatDSN()
+}
+
+// postgresDSN 生成 lib/pq 使用的URL格式数据源字符串
+// TLS沿用MySQL的取值: 空或false不加密，true校验证书，skip-verify/preferred加密但不校验证书
+func (c Config) postgresDSN() string {
+	query := url.Values{}
+	switch c.TLS {
+	case "", "false":
+		query.Set("sslmode", "disable")
+	case "true":
+		query.Set("sslmode", "verify-full")
+	default:
+		query.Set("sslmode", "require")
+	}
+	for k, v := range c.Params {
+		query.Set(k, v)
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     c.addr(),
+		Path:     "/" + c.Database,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// sqliteDSN 生成 modernc.org/sqlite 使用的数据源字符串
+// 默认设置忙等待超时，避免多个连接同时写入时立即返回 SQLITE_BUSY
+func (c Config) sqliteDSN() string {
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "foreign_keys(1)")
+	for k, v := range c.Params {
+		query.Add(k, v)
+	}
+	return "file:" + c.Database + "?" + query.Encode()
+}
+
+// addr 返回 host:port，未配置端口时使用驱动的默认端口
+func (c Config) addr() string {
+	port := c.Port
+	if port == 0 {
+		port = 3306
+		if c.DriverName() == DriverPostgres {
+			port = 5432
+		}
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// DriverName 返回 sqlx.Open 使用的驱动名，未配置时为mysql
+func (c Config) DriverName() string {
+	if c.Driver == "" {
+		return DriverMySQL
+	}
+	return c.Driver
+}
+
+// String 用于日志输出，隐藏密码
+func (c Config) String() string {
+	if c.DriverName() == DriverSQLite {
+		return "sqlite:" + c.Database
+	}
+	return fmt.Sprintf("%s@%s/%s", c.User, c.addr(), c.Database)
+}
+
+// ReplicaConfig 返回指定从库的配置，除地址外与主库相同
+func (c Config) ReplicaConfig(addr string) (Config, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return c, fmt.Errorf("从库地址 %q 格式错误，应为host:port: %w", addr, err)
+	}
+	replica := c
+	replica.Host = host
+	if replica.Port, err = strconv.Atoi(port); err != nil {
+		return c, fmt.Errorf("从库地址 %q 端口不是整数", addr)
+	}
+	replica.Replicas = nil
+	return replica, nil
+}
@@ -0,0 +1,248 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	DefaultHealthInterval   = 5 * time.Second // 默认每5秒Ping一次
+	DefaultFailureThreshold = 3               // 默认连续失败3次后判定为不可用
+)
+
+// ============================= 1. 健康检查配置 ====================
+// Pinger 可以检查连接是否可用的数据库，*sqlx.DB 和 *Cluster 都实现了该接口
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// HealthOption 创建HealthChecker时的可选配置
+type HealthOption func(*HealthChecker)
+
+// WithHealthInterval Ping的间隔，<=0时使用DefaultHealthInterval
+func WithHealthInterval(d time.Duration) HealthOption {
+	return func(h *HealthChecker) { h.interval = d }
+}
+
+// WithHealthTimeout 单次Ping的超时，默认与间隔相同
+func WithHealthTimeout(d time.Duration) HealthOption {
+	return func(h *HealthChecker) { h.timeout = d }
+}
+
+// WithFailureThreshold 连续失败多少次后判定为不可用，<=0时使用DefaultFailureThreshold
+// 偶尔一次Ping超时不会让服务被摘除，恢复时一次成功即可重新就绪
+func WithFailureThreshold(n int) HealthOption {
+	return func(h *HealthChecker) { h.threshold = n }
+}
+
+// HealthConfigOptions 按配置文件中的 health_check_interval 和 health_failure_threshold 生成选项
+func HealthConfigOptions(cfg Config) []HealthOption {
+	return []HealthOption{
+		WithHealthInterval(time.Duration(cfg.HealthCheckInterval)),
+		WithFailureThreshold(cfg.HealthFailureThreshold),
+	}
+}
+
+// HealthStatus 健康检查的结果和连接池状态
+type HealthStatus struct {
+	// Ready 至少成功过一次，且连续失败次数小于阈值
+	Ready               bool      `json:"ready"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastCheck           time.Time `json:"last_check"`
+	LastSuccess         time.Time `json:"last_success"`
+	LatencyMs           float64   `json:"latency_ms"` // 最近一次Ping的耗时
+
+	// LastError 驱动返回的原始错误，可能包含主机、端口和账号，只写日志，不出现在 /healthz 和 /readyz 的响应中
+	LastError string `json:"-"`
+
+	Pools    map[string]PoolSnapshot `json:"pools,omitempty"`
+	Replicas []ReplicaStatus         `json:"replicas,omitempty"`
+}
+
+// ============================= 2. 后台健康检查 ====================
+// HealthChecker 在后台定期Ping数据库，记录连续失败次数，为 /healthz 和 /readyz 提供状态
+// initDB 只在启动时Ping一次，运行期间数据库不可用时由它通知编排系统暂停向本实例转发流量
+// db为*Cluster时检查主库，同时上报所有连接池和从库的状态
+type HealthChecker struct {
+	db        Pinger
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int
+	pools     map[string]*sqlx.DB
+	cluster   *Cluster
+
+	mu     sync.RWMutex
+	status HealthStatus
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewHealthChecker 创建并启动健康检查，创建时同步执行第一次检查(最长等待单次Ping的超时)，
+// 返回后的状态就是数据库当前的状态
+func NewHealthChecker(db Pinger, opts ...HealthOption) *HealthChecker {
+	h := &HealthChecker{db: db, stop: make(chan struct{})}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.interval <= 0 {
+		h.interval = DefaultHealthInterval
+	}
+	if h.timeout <= 0 {
+		h.timeout = h.interval
+	}
+	if h.threshold <= 0 {
+		h.threshold = DefaultFailureThreshold
+	}
+	switch db := db.(type) {
+	case *sqlx.DB:
+		h.pools = map[string]*sqlx.DB{"primary": db}
+	case *Cluster:
+		h.pools, h.cluster = db.Pools(), db
+	}
+
+	h.check()
+	h.wg.Add(1)
+	go h.loop()
+	return h
+}
+
+func (h *HealthChecker) loop() {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			h.check()
+		}
+	}
+}
+
+func (h *HealthChecker) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	h.Check(ctx)
+}
+
+// Check 立即执行一次检查并更新状态，返回Ping的错误
+func (h *HealthChecker) Check(ctx context.Context) error {
+	start := time.Now()
+	err := h.db.PingContext(ctx)
+	elapsed := time.Since(start)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	wasReady := h.ready()
+	h.status.LastCheck = start
+	h.status.LatencyMs = ms(elapsed)
+	if err == nil {
+		h.status.ConsecutiveFailures = 0
+		h.status.LastSuccess = start
+		h.status.LastError = ""
+	} else {
+		// 错误变化时才打印，数据库持续不可用时不会每次检查都打印
+		if msg := err.Error(); msg != h.status.LastError {
+			log.Printf("数据库Ping失败: %v", err)
+		}
+		h.status.ConsecutiveFailures++
+		h.status.LastError = err.Error()
+	}
+
+	// 只在状态变化时打印日志，启动时一直连不上也会在达到阈值时打印一次
+	switch ready := h.ready(); {
+	case ready && !wasReady:
+		log.Printf("数据库已就绪")
+	case !ready && h.status.ConsecutiveFailures == h.threshold:
+		log.Printf("数据库不可用(连续失败%d次): %v", h.status.ConsecutiveFailures, err)
+	}
+	return err
+}
+
+// ready 调用方需要持有锁
+func (h *HealthChecker) ready() bool {
+	return !h.status.LastSuccess.IsZero() && h.status.ConsecutiveFailures < h.threshold
+}
+
+// Ready 数据库是否可用，h为nil(没有使用数据库)时总是可用
+func (h *HealthChecker) Ready() bool {
+	if h == nil {
+		return true
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.ready()
+}
+
+// Status 返回最近一次检查的结果和连接池的当前状态
+// h为nil时表示没有使用数据库，返回就绪状态
+func (h *HealthChecker) Status() HealthStatus {
+	if h == nil {
+		return HealthStatus{Ready: true}
+	}
+	h.mu.RLock()
+	status := h.status
+	status.Ready = h.ready()
+	h.mu.RUnlock()
+
+	if len(h.pools) > 0 {
+		status.Pools = make(map[string]PoolSnapshot, len(h.pools))
+		for name, db := range h.pools {
+			status.Pools[name] = poolSnapshot(db)
+		}
+	}
+	if h.cluster != nil {
+		status.Replicas = h.cluster.Replicas()
+	}
+	return status
+}
+
+// Close 停止后台检查，不关闭数据库
+func (h *HealthChecker) Close() {
+	close(h.stop)
+	h.wg.Wait()
+}
+
+// ============================= 3. HTTP接口 ====================
+// healthResponse /healthz 和 /readyz 的响应体
+type healthResponse struct {
+	Status   string       `json:"status"`
+	Database HealthStatus `json:"database"`
+}
+
+// LiveHandler 存活检查(/healthz): 进程能处理请求就返回200，响应中附带数据库状态
+// 接口不需要认证，响应中不包含Ping的错误信息，错误只写日志
+// 数据库不可用时重启进程无济于事，所以存活检查不依赖数据库，避免编排系统反复重启实例
+func (h *HealthChecker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, healthResponse{Status: "ok", Database: h.Status()})
+	})
+}
+
+// ReadyHandler 就绪检查(/readyz): 数据库可用时返回200，否则返回503，编排系统据此暂停转发流量
+func (h *HealthChecker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := h.Status()
+		if !status.Ready {
+			writeHealth(w, http.StatusServiceUnavailable, healthResponse{Status: "unavailable", Database: status})
+			return
+		}
+		writeHealth(w, http.StatusOK, healthResponse{Status: "ready", Database: status})
+	})
+}
+
+func writeHealth(w http.ResponseWriter, code int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,34 @@
+package dbx_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Gocommunity/database/mysql/dbx"
+)
+
+type failingPinger struct{ err error }
+
+func (p failingPinger) PingContext(context.Context) error { return p.err }
+
+// TestHealthHandlersHideError 公开的健康检查接口不返回驱动的错误信息
+func TestHealthHandlersHideError(t *testing.T) {
+	secret := "dial tcp 10.0.0.7:3306: connect: connection refused (user root)"
+	h := dbx.NewHealthChecker(failingPinger{errors.New(secret)}, dbx.WithFailureThreshold(1))
+	t.Cleanup(h.Close)
+
+	if got := h.Status().LastError; got != secret {
+		t.Errorf("Status().LastError = %q, want %q", got, secret)
+	}
+	for path, handler := range map[string]http.Handler{"/healthz": h.LiveHandler(), "/readyz": h.ReadyHandler()} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if body := w.Body.String(); strings.Contains(body, "10.0.0.7") || strings.Contains(body, "last_error") {
+			t.Errorf("%s 响应包含错误信息: %s", path, body)
+		}
+	}
+}
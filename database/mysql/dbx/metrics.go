@@ -0,0 +1,340 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultSlowQueryThreshold 默认慢查询阈值
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+// maxStatementKey 统计时SQL文本的最大长度，过长的语句(如批量插入)截断后合并统计
+const maxStatementKey = 200
+
+// latencyBuckets 延迟直方图的桶上限，最后一个桶之外的计入 +Inf
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// DB 仓储需要的数据库能力: sqlx.ExtContext 加上开启事务
+// *sqlx.DB、*Cluster 和 *InstrumentedDB 都满足
+type DB interface {
+	sqlx.ExtContext
+	TxBeginner
+}
+
+// ============================= 1. 指标收集 ====================
+// Metrics 记录每条SQL的延迟直方图和错误数，以及连接池状态
+// 可并发使用，一个进程通常只需要一个实例
+type Metrics struct {
+	// SlowThreshold 超过该耗时的SQL会打印慢查询日志，<=0表示不打印
+	SlowThreshold time.Duration
+	// Logf 慢查询日志输出，默认使用log.Printf
+	Logf func(format string, args ...any)
+
+	mu         sync.Mutex
+	statements map[string]*statementStats
+	pools      map[string]*sqlx.DB
+}
+
+// statementStats 单条SQL的统计
+type statementStats struct {
+	count   int64
+	errors  int64
+	slow    int64
+	total   time.Duration
+	max     time.Duration
+	buckets []int64 // 与latencyBuckets一一对应，多出的最后一个是 +Inf
+}
+
+// NewMetrics 创建指标收集器
+func NewMetrics(slowThreshold time.Duration) *Metrics {
+	return &Metrics{
+		SlowThreshold: slowThreshold,
+		Logf:          log.Printf,
+		statements:    make(map[string]*statementStats),
+		pools:         make(map[string]*sqlx.DB),
+	}
+}
+
+// RegisterPool 注册需要上报 db.Stats() 的连接池
+func (m *Metrics) RegisterPool(name string, db *sqlx.DB) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools[name] = db
+}
+
+// observe 记录一次SQL执行
+func (m *Metrics) observe(query string, args []interface{}, elapsed time.Duration, err error) {
+	key := normalizeQuery(query)
+	// sql.ErrNoRows 是正常的查询结果，不算错误
+	failed := err != nil && err != sql.ErrNoRows
+	slow := m.SlowThreshold > 0 && elapsed >= m.SlowThreshold
+
+	m.mu.Lock()
+	s, ok := m.statements[key]
+	if !ok {
+		s = &statementStats{buckets: make([]int64, len(latencyBuckets)+1)}
+		m.statements[key] = s
+	}
+	s.count++
+	s.total += elapsed
+	s.max = max(s.max, elapsed)
+	s.buckets[sort.Search(len(latencyBuckets), func(i int) bool { return elapsed <= latencyBuckets[i] })]++
+	if failed {
+		s.errors++
+	}
+	if slow {
+		s.slow++
+	}
+	m.mu.Unlock()
+
+	if slow {
+		m.Logf("慢查询 %v: %s args=%s err=%v", elapsed, key, redactArgs(args), err)
+	}
+}
+
+// normalizeQuery 合并多余的空白并截断，使同一条语句的不同写法归为一类
+func normalizeQuery(query string) string {
+	key := strings.Join(strings.Fields(query), " ")
+	if len(key) > maxStatementKey {
+		key = key[:maxStatementKey] + "..."
+	}
+	return key
+}
+
+// redactArgs 日志中只输出参数类型和长度，不输出参数值，避免泄露用户数据
+func redactArgs(args []interface{}) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+			parts[i] = "NULL"
+		case string:
+			parts[i] = fmt.Sprintf("string(%d)", len(v))
+		case []byte:
+			parts[i] = fmt.Sprintf("bytes(%d)", len(v))
+		default:
+			parts[i] = fmt.Sprintf("%T", v)
+		}
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// ============================= 2. 指标快照 ====================
+// StatementSnapshot 单条SQL的统计快照，耗时单位为毫秒
+type StatementSnapshot struct {
+	Query   string           `json:"query"`
+	Count   int64            `json:"count"`
+	Errors  int64            `json:"errors"`
+	Slow    int64            `json:"slow"`
+	AvgMs   float64          `json:"avg_ms"`
+	MaxMs   float64          `json:"max_ms"`
+	Buckets map[string]int64 `json:"buckets"` // 桶上限 -> 落在该桶内的次数
+}
+
+// PoolSnapshot 连接池状态，来自 db.Stats()
+type PoolSnapshot struct {
+	MaxOpenConnections int     `json:"max_open_connections"`
+	OpenConnections    int     `json:"open_connections"`
+	InUse              int     `json:"in_use"`
+	Idle               int     `json:"idle"`
+	WaitCount          int64   `json:"wait_count"`
+	WaitDurationMs     float64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64   `json:"max_idle_closed"`
+	MaxLifetimeClosed  int64   `json:"max_lifetime_closed"`
+}
+
+// Snapshot 所有指标的快照
+type Snapshot struct {
+	Statements []StatementSnapshot     `json:"statements"`
+	Pools      map[string]PoolSnapshot `json:"pools"`
+}
+
+// Snapshot 返回当前指标，语句按执行次数降序排列
+func (m *Metrics) Snapshot() Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snap := Snapshot{
+		Statements: make([]StatementSnapshot, 0, len(m.statements)),
+		Pools:      make(map[string]PoolSnapshot, len(m.pools)),
+	}
+	for query, s := range m.statements {
+		st := StatementSnapshot{
+			Query:   query,
+			Count:   s.count,
+			Errors:  s.errors,
+			Slow:    s.slow,
+			AvgMs:   ms(s.total) / float64(s.count),
+			MaxMs:   ms(s.max),
+			Buckets: make(map[string]int64, len(s.buckets)),
+		}
+		for i, n := range s.buckets {
+			label := "+Inf"
+			if i < len(latencyBuckets) {
+				label = latencyBuckets[i].String()
+			}
+			st.Buckets[label] = n
+		}
+		snap.Statements = append(snap.Statements, st)
+	}
+	sort.Slice(snap.Statements, func(i, j int) bool { return snap.Statements[i].Count > snap.Statements[j].Count })
+
+	for name, db := range m.pools {
+		snap.Pools[name] = poolSnapshot(db)
+	}
+	return snap
+}
+
+// poolSnapshot 读取连接池当前状态
+func poolSnapshot(db *sqlx.DB) PoolSnapshot {
+	stats := db.Stats()
+	return PoolSnapshot{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     ms(stats.WaitDuration),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
+
+// Handler 以JSON输出指标快照，挂载到HTTP服务的 /metrics
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m.Snapshot())
+	})
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// ============================= 3. 带指标的数据库包装 ====================
+// TxStatement 事务在统计中的名称，记录 WithTx 每次执行事务(重试的每一次单独计数)从开始到提交或回滚的耗时
+const TxStatement = "TRANSACTION"
+
+// instrumented 记录每条SQL的耗时和错误，InstrumentedDB 和 InstrumentedTx 共用
+type instrumented struct {
+	ext     sqlx.ExtContext
+	metrics *Metrics
+}
+
+func (d *instrumented) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := d.ext.QueryContext(ctx, query, args...)
+	d.metrics.observe(query, args, time.Since(start), err)
+	return rows, err
+}
+
+// QueryxContext 耗时统计到返回结果集为止，不包括调用方遍历结果的时间
+func (d *instrumented) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	start := time.Now()
+	rows, err := d.ext.QueryxContext(ctx, query, args...)
+	d.metrics.observe(query, args, time.Since(start), err)
+	return rows, err
+}
+
+func (d *instrumented) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	start := time.Now()
+	row := d.ext.QueryRowxContext(ctx, query, args...)
+	d.metrics.observe(query, args, time.Since(start), row.Err())
+	return row
+}
+
+func (d *instrumented) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := d.ext.ExecContext(ctx, query, args...)
+	d.metrics.observe(query, args, time.Since(start), err)
+	return result, err
+}
+
+func (d *instrumented) DriverName() string {
+	return d.ext.DriverName()
+}
+
+func (d *instrumented) Rebind(query string) string {
+	return d.ext.Rebind(query)
+}
+
+func (d *instrumented) BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	return d.ext.BindNamed(query, arg)
+}
+
+// InstrumentedDB 在每次执行SQL时记录耗时和错误，行为与被包装的DB完全一致
+// BeginTxx 返回的*sqlx.Tx本身不记录指标: 通过 WithTx 执行的事务整体记为 TxStatement，
+// 事务内的语句用 InstrumentTx 包装后同样逐条统计
+type InstrumentedDB struct {
+	instrumented
+	db DB
+}
+
+var _ DB = (*InstrumentedDB)(nil)
+
+// Instrument 包装db，指标记录到m
+func Instrument(db DB, m *Metrics) *InstrumentedDB {
+	return &InstrumentedDB{instrumented: instrumented{ext: db, metrics: m}, db: db}
+}
+
+func (d *InstrumentedDB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	return d.db.BeginTxx(ctx, opts)
+}
+
+// observeTx 记录一次事务，由 WithTx 调用
+func (d *InstrumentedDB) observeTx(elapsed time.Duration, err error) {
+	d.metrics.observe(TxStatement, nil, elapsed, err)
+}
+
+// InstrumentedTx 记录事务内每条SQL的耗时和错误，由 InstrumentTx 创建
+type InstrumentedTx struct {
+	instrumented
+	tx *sqlx.Tx
+}
+
+// InstrumentTx 在 WithTx 的回调中使用: db是带指标的 InstrumentedDB 时包装tx，否则原样返回tx
+//
+//	dbx.WithTx(ctx, db, opts, func(tx *sqlx.Tx) error {
+//		repo := store.NewSQLPersonRepository(dbx.InstrumentTx(db, tx))
+//		...
+//	})
+func InstrumentTx(db TxBeginner, tx *sqlx.Tx) sqlx.ExtContext {
+	if d, ok := db.(*InstrumentedDB); ok {
+		return &InstrumentedTx{instrumented: instrumented{ext: tx, metrics: d.metrics}, tx: tx}
+	}
+	return tx
+}
+
+// Tx 返回被包装的事务
+func (t *InstrumentedTx) Tx() *sqlx.Tx {
+	return t.tx
+}
+
+// InTx db是否是一个事务(*sqlx.Tx 或 InstrumentedTx)，在事务中执行的操作不能再开启新事务
+func InTx(db sqlx.ExtContext) bool {
+	switch db.(type) {
+	case *sqlx.Tx, *InstrumentedTx:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"Gocommunity/database/mysql/secret"
+
+	_ "github.com/go-sql-driver/mysql" // 注册mysql驱动
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"  // 注册postgres驱动
+	_ "modernc.org/sqlite" // 注册sqlite驱动
+)
+
+// sqlx按驱动名决定占位符格式，modernc.org/sqlite注册的"sqlite"不在内置列表中
+func init() {
+	sqlx.BindDriver(DriverSQLite, sqlx.QUESTION)
+}
+
+// maxPingBackoff Ping重试的最长等待时间
+const maxPingBackoff = 10 * time.Second
+
+// ============================= 打开连接池 ====================
+// Open 按配置创建连接池并Ping确认数据库可用
+// Ping失败时按指数退避重试，全部失败后关闭连接池并返回错误，不再panic
+// 配置了 EncryptionKeys 时同时设置 secret 包的全局密钥环
+func Open(ctx context.Context, cfg Config) (*sqlx.DB, error) {
+	if cfg.EncryptionKeys != "" {
+		keyring, err := secret.ParseKeyring(cfg.EncryptionKeys)
+		if err != nil {
+			return nil, fmt.Errorf("加载字段加密密钥失败: %w", err)
+		}
+		secret.SetKeyring(keyring)
+	}
+	return Connect(ctx, cfg)
+}
+
+// Connect 与 Open 相同，但忽略 EncryptionKeys，不修改全局密钥环
+// 密钥环是进程级的，同一进程中打开多个库(例如每个租户一个库)时，除主库外都应使用 Connect
+func Connect(ctx context.Context, cfg Config) (*sqlx.DB, error) {
+	db, err := openPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pingWithRetry(ctx, db, cfg.PingRetries, time.Duration(cfg.PingBackoff)); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("数据库ping失败(%s): %w", cfg, err)
+	}
+	return db, nil
+}
+
+// pingWithRetry 首次Ping失败后最多重试retries次，每次等待时间翻倍
+func pingWithRetry(ctx context.Context, db *sqlx.DB, retries int, backoff time.Duration) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = db.PingContext(ctx); err == nil {
+			return nil
+		}
+		if attempt >= retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (最后一次错误: %v)", ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxPingBackoff)
+	}
+}
+
+// openPool 创建连接池并设置连接池参数，不检查数据库是否可用
+func openPool(cfg Config) (*sqlx.DB, error) {
+	db, err := sqlx.Open(cfg.DriverName(), cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("数据库连接失败(%s): %w", cfg, err)
+	}
+
+	// 连接池设置
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime))
+
+	// SQLite内存库每个连接是一个独立的数据库，只能使用一个连接且不能被回收
+	if cfg.DriverName() == DriverSQLite && cfg.Database == ":memory:" {
+		db.SetMaxOpenConns(1)
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
+	}
+	return db, nil
+}
@@ -0,0 +1,18 @@
+package dbx
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultQueryTimeout 单条SQL的默认超时时间
+const DefaultQueryTimeout = 5 * time.Second
+
+// WithTimeout 为单次查询派生带超时的context
+// 父context(例如HTTP请求)被取消或有更早的截止时间时，以父context为准；d<=0表示不额外设置超时
+func WithTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,169 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+)
+
+// MySQL 错误码
+const (
+	ErrCodeLockWaitTimeout = 1205 // 等待行锁超时
+	ErrCodeDeadlock        = 1213 // 检测到死锁，事务已被回滚
+)
+
+// PostgreSQL 和 SQLite 中对应的可重试错误
+const (
+	pgSerializationFailure = "40001" // 可串行化隔离级别下的读写冲突
+	pgDeadlockDetected     = "40P01"
+	sqliteBusy             = 5 // 数据库文件被其他连接锁定
+	sqliteLocked           = 6 // 同一连接内的表锁冲突
+)
+
+// ============================= 1. 事务选项 ====================
+// TxOptions 控制事务隔离级别、只读以及死锁重试策略
+type TxOptions struct {
+	Isolation sql.IsolationLevel // 默认使用数据库的隔离级别(MySQL为REPEATABLE READ)
+	ReadOnly  bool
+
+	// MaxRetries 遇到死锁/锁等待超时时最多重试的次数，0表示不重试
+	MaxRetries int
+	// BaseBackoff 第一次重试前的等待时间，之后翻倍并加入随机抖动
+	BaseBackoff time.Duration
+	// MaxBackoff 单次等待的上限
+	MaxBackoff time.Duration
+}
+
+// DefaultTxOptions 默认重试3次，等待时间从20ms开始，最长1s
+func DefaultTxOptions() TxOptions {
+	return TxOptions{
+		MaxRetries:  3,
+		BaseBackoff: 20 * time.Millisecond,
+		MaxBackoff:  time.Second,
+	}
+}
+
+// TxBeginner 可以开启sqlx事务的对象，*sqlx.DB 和 *sqlx.Conn 都满足
+type TxBeginner interface {
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+// txObserver 记录事务耗时和结果的DB，见 InstrumentedDB
+type txObserver interface {
+	observeTx(elapsed time.Duration, err error)
+}
+
+// ============================= 2. 事务执行 ====================
+// WithTx 在事务中执行fn: fn返回nil时提交，返回错误或panic时回滚
+// 遇到死锁(1213)或锁等待超时(1205)时整个fn会被重新执行，因此fn必须可以安全重试，
+// 不要在fn中做发送消息等无法回滚的操作
+// db是 InstrumentedDB 时每次执行的耗时和结果记为 TxStatement
+func WithTx(ctx context.Context, db TxBeginner, opts TxOptions, fn func(tx *sqlx.Tx) error) error {
+	observer, _ := db.(txObserver)
+	backoff := opts.BaseBackoff
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		err := runTx(ctx, db, opts, fn)
+		if observer != nil {
+			observer.observeTx(time.Since(start), err)
+		}
+		if err == nil || !IsRetryable(err) || attempt >= opts.MaxRetries {
+			return err
+		}
+
+		wait := backoff
+		if wait > 0 {
+			// 加入随机抖动，避免冲突的事务同时重试再次死锁
+			wait = wait/2 + rand.N(wait/2+1)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (最后一次错误: %v)", ctx.Err(), err)
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, opts.MaxBackoff)
+	}
+}
+
+// runTx 执行一次事务
+func runTx(ctx context.Context, db TxBeginner, opts TxOptions, fn func(tx *sqlx.Tx) error) (err error) {
+	tx, err := db.BeginTxx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("事务开始失败: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			// 回滚失败不覆盖原始错误，原始错误更有助于定位问题
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("事务提交失败: %w", err)
+	}
+	return nil
+}
+
+// ============================= 3. 错误判断 ====================
+// MySQLErrorNumber 取出MySQL服务端错误码，非MySQL错误返回false
+func MySQLErrorNumber(err error) (uint16, bool) {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number, true
+	}
+	return 0, false
+}
+
+// PostgresErrorCode 取出PostgreSQL的SQLSTATE错误码，非PostgreSQL错误返回false
+func PostgresErrorCode(err error) (string, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code), true
+	}
+	return "", false
+}
+
+// SQLiteErrorCode 取出SQLite的主错误码(去掉扩展部分)，非SQLite错误返回false
+func SQLiteErrorCode(err error) (int, bool) {
+	code, ok := SQLiteExtendedErrorCode(err)
+	return code & 0xff, ok
+}
+
+// SQLiteExtendedErrorCode 取出SQLite的扩展错误码，例如 2067(SQLITE_CONSTRAINT_UNIQUE)
+func SQLiteExtendedErrorCode(err error) (int, bool) {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code(), true
+	}
+	return 0, false
+}
+
+// IsRetryable 判断错误是否是可以通过重试整个事务解决的锁冲突
+func IsRetryable(err error) bool {
+	if code, ok := MySQLErrorNumber(err); ok {
+		return code == ErrCodeDeadlock || code == ErrCodeLockWaitTimeout
+	}
+	if code, ok := PostgresErrorCode(err); ok {
+		return code == pgSerializationFailure || code == pgDeadlockDetected
+	}
+	if code, ok := SQLiteErrorCode(err); ok {
+		return code == sqliteBusy || code == sqliteLocked
+	}
+	return false
+}
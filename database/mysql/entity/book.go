@@ -0,0 +1,19 @@
+// Package entity 数据访问代码由 cmd/sqlxgen 生成的实体
+//
+// 每个实体只需要定义结构体和db、ddl标签，*_gen.go 中的仓储和建表语句由 go generate 生成，
+// 修改结构体后重新执行 go generate ./database/mysql/entity 即可
+package entity
+
+import "time"
+
+//go:generate go run Gocommunity/database/mysql/cmd/sqlxgen -type Book -table books
+
+// ============================= 图书 ====================
+// Book 图书，字段与 webdevelop 中图书API的Book一致，ISDN是主键
+type Book struct {
+	ISDN        string     `db:"isdn" json:"isdn" ddl:"pk,size=32"`
+	Title       string     `db:"title" json:"title" ddl:"size=128,index"`
+	Author      string     `db:"author" json:"author" ddl:"size=64,index"`
+	Pages       int        `db:"pages" json:"pages"`
+	PublishedAt *time.Time `db:"published_at" json:"published_at,omitempty"` // 出版日期，未知时为NULL
+}
@@ -0,0 +1,165 @@
+// Code generated by sqlxgen from book.go; DO NOT EDIT.
+
+package entity
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/store"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// books表的SQL，列的顺序与Book的字段一致
+const (
+	bookColumns = "isdn, title, author, pages, published_at"
+	bookInsert  = "INSERT INTO books (isdn, title, author, pages, published_at) VALUES (:isdn, :title, :author, :pages, :published_at)"
+	bookUpdate  = "UPDATE books SET title = :title, author = :author, pages = :pages, published_at = :published_at WHERE isdn = :isdn"
+)
+
+// BookSchema books表的建表语句，按驱动名索引，由Book的db和ddl标签生成
+var BookSchema = map[string][]string{
+	dbx.DriverMySQL: {
+		`CREATE TABLE IF NOT EXISTS books (
+    isdn         VARCHAR(32)  NOT NULL,
+    title        VARCHAR(128) NOT NULL,
+    author       VARCHAR(64)  NOT NULL,
+    pages        INT          NOT NULL,
+    published_at DATETIME(3)  NULL DEFAULT NULL,
+    PRIMARY KEY (isdn),
+    INDEX idx_books_title (title),
+    INDEX idx_books_author (author)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+	},
+	dbx.DriverPostgres: {
+		`CREATE TABLE IF NOT EXISTS books (
+    isdn         VARCHAR(32)  NOT NULL,
+    title        VARCHAR(128) NOT NULL,
+    author       VARCHAR(64)  NOT NULL,
+    pages        INT          NOT NULL,
+    published_at TIMESTAMP(3) NULL DEFAULT NULL,
+    PRIMARY KEY (isdn)
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_books_title ON books (title)`,
+		`CREATE INDEX IF NOT EXISTS idx_books_author ON books (author)`,
+	},
+	dbx.DriverSQLite: {
+		`CREATE TABLE IF NOT EXISTS books (
+    isdn         VARCHAR(32)  NOT NULL,
+    title        VARCHAR(128) NOT NULL,
+    author       VARCHAR(64)  NOT NULL,
+    pages        INT          NOT NULL,
+    published_at DATETIME     NULL DEFAULT NULL,
+    PRIMARY KEY (isdn)
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_books_title ON books (title)`,
+		`CREATE INDEX IF NOT EXISTS idx_books_author ON books (author)`,
+	},
+}
+
+// BookRepository books表的增删改查，db可以是*sqlx.DB、*dbx.Cluster，也可以是*sqlx.Tx
+// 写入使用命名参数，由sqlx按驱动转换占位符；错误按 store 包的哨兵错误归类
+type BookRepository struct {
+	db      sqlx.ExtContext
+	timeout time.Duration
+}
+
+// NewBookRepository 创建仓储，timeout是单条SQL的超时，<=0表示只依赖调用方的context
+func NewBookRepository(db sqlx.ExtContext, timeout time.Duration) *BookRepository {
+	return &BookRepository{db: db, timeout: timeout}
+}
+
+// CreateTable 执行当前数据库的建表语句，表已存在时不做处理，用于测试和本地开发
+func (r *BookRepository) CreateTable(ctx context.Context) error {
+	dialect, err := store.DialectFor(r.db.DriverName())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	for _, stmt := range BookSchema[dialect.Name] {
+		if _, err := r.db.ExecContext(ctx, stmt); err != nil {
+			return store.TranslateError("创建books表失败", err)
+		}
+	}
+	return nil
+}
+
+// Get 按主键查询，记录不存在时返回 store.ErrNotFound
+func (r *BookRepository) Get(ctx context.Context, isdn string) (*Book, error) {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var v Book
+	if err := sqlx.GetContext(ctx, r.db, &v, r.db.Rebind("SELECT "+bookColumns+" FROM books WHERE isdn = ?"), isdn); err != nil {
+		return nil, store.TranslateError("查询Book失败", err)
+	}
+	return &v, nil
+}
+
+// List 按主键排序分页查询
+func (r *BookRepository) List(ctx context.Context, limit, offset int) ([]Book, error) {
+	if limit <= 0 || offset < 0 {
+		return nil, fmt.Errorf("%w: limit必须大于0，offset不能为负数", store.ErrInvalidQuery)
+	}
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var list []Book
+	if err := sqlx.SelectContext(ctx, r.db, &list, r.db.Rebind("SELECT "+bookColumns+" FROM books ORDER BY isdn LIMIT ? OFFSET ?"), limit, offset); err != nil {
+		return nil, store.TranslateError("查询Book列表失败", err)
+	}
+	return list, nil
+}
+
+// Insert 插入一条记录，主键已存在时返回 store.ErrDuplicateKey
+func (r *BookRepository) Insert(ctx context.Context, v *Book) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if _, err := sqlx.NamedExecContext(ctx, r.db, bookInsert, v); err != nil {
+		return store.TranslateError("插入Book失败", err)
+	}
+	return nil
+}
+
+// Update 按主键更新其它所有列，记录不存在时返回 store.ErrNotFound
+// MySQL的DSN需要 clientFoundRows=true，否则更新为相同的值也会被当作记录不存在
+func (r *BookRepository) Update(ctx context.Context, v *Book) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := sqlx.NamedExecContext(ctx, r.db, bookUpdate, v)
+	if err != nil {
+		return store.TranslateError("更新Book失败", err)
+	}
+	return r.expectOne("更新Book失败", result)
+}
+
+// Delete 按主键删除，记录不存在时返回 store.ErrNotFound
+func (r *BookRepository) Delete(ctx context.Context, isdn string) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind("DELETE FROM books WHERE isdn = ?"), isdn)
+	if err != nil {
+		return store.TranslateError("删除Book失败", err)
+	}
+	return r.expectOne("删除Book失败", result)
+}
+
+func (r *BookRepository) expectOne(op string, result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return store.TranslateError(op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, store.ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+//go:generate go run Gocommunity/database/mysql/cmd/sqlxgen -type User -table users
+
+// ============================= 账号 ====================
+// User 账号，字段与 gin/file 中的User一致，对应users表
+// 与 store.Person 使用的user表无关
+type User struct {
+	ID        int64     `db:"id" json:"id" ddl:"pk"`
+	Username  string    `db:"username" json:"username" ddl:"size=64,unique"`
+	Email     string    `db:"email" json:"email" ddl:"unique"`
+	Age       int       `db:"age" json:"age"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,165 @@
+// Code generated by sqlxgen from user.go; DO NOT EDIT.
+
+package entity
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/store"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// users表的SQL，列的顺序与User的字段一致
+const (
+	userColumns = "id, username, email, age, created_at"
+	userInsert  = "INSERT INTO users (id, username, email, age, created_at) VALUES (:id, :username, :email, :age, :created_at)"
+	userUpdate  = "UPDATE users SET username = :username, email = :email, age = :age, created_at = :created_at WHERE id = :id"
+)
+
+// UserSchema users表的建表语句，按驱动名索引，由User的db和ddl标签生成
+var UserSchema = map[string][]string{
+	dbx.DriverMySQL: {
+		`CREATE TABLE IF NOT EXISTS users (
+    id         BIGINT       NOT NULL,
+    username   VARCHAR(64)  NOT NULL,
+    email      VARCHAR(255) NOT NULL,
+    age        INT          NOT NULL,
+    created_at DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_users_username UNIQUE (username),
+    CONSTRAINT uk_users_email UNIQUE (email)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4`,
+	},
+	dbx.DriverPostgres: {
+		`CREATE TABLE IF NOT EXISTS users (
+    id         BIGINT       NOT NULL,
+    username   VARCHAR(64)  NOT NULL,
+    email      VARCHAR(255) NOT NULL,
+    age        INT          NOT NULL,
+    created_at TIMESTAMP(3) NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_users_username UNIQUE (username),
+    CONSTRAINT uk_users_email UNIQUE (email)
+)`,
+	},
+	dbx.DriverSQLite: {
+		`CREATE TABLE IF NOT EXISTS users (
+    id         BIGINT       NOT NULL,
+    username   VARCHAR(64)  NOT NULL,
+    email      VARCHAR(255) NOT NULL,
+    age        INT          NOT NULL,
+    created_at DATETIME     NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_users_username UNIQUE (username),
+    CONSTRAINT uk_users_email UNIQUE (email)
+)`,
+	},
+}
+
+// UserRepository users表的增删改查，db可以是*sqlx.DB、*dbx.Cluster，也可以是*sqlx.Tx
+// 写入使用命名参数，由sqlx按驱动转换占位符；错误按 store 包的哨兵错误归类
+type UserRepository struct {
+	db      sqlx.ExtContext
+	timeout time.Duration
+}
+
+// NewUserRepository 创建仓储，timeout是单条SQL的超时，<=0表示只依赖调用方的context
+func NewUserRepository(db sqlx.ExtContext, timeout time.Duration) *UserRepository {
+	return &UserRepository{db: db, timeout: timeout}
+}
+
+// CreateTable 执行当前数据库的建表语句，表已存在时不做处理，用于测试和本地开发
+func (r *UserRepository) CreateTable(ctx context.Context) error {
+	dialect, err := store.DialectFor(r.db.DriverName())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	for _, stmt := range UserSchema[dialect.Name] {
+		if _, err := r.db.ExecContext(ctx, stmt); err != nil {
+			return store.TranslateError("创建users表失败", err)
+		}
+	}
+	return nil
+}
+
+// Get 按主键查询，记录不存在时返回 store.ErrNotFound
+func (r *UserRepository) Get(ctx context.Context, id int64) (*User, error) {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var v User
+	if err := sqlx.GetContext(ctx, r.db, &v, r.db.Rebind("SELECT "+userColumns+" FROM users WHERE id = ?"), id); err != nil {
+		return nil, store.TranslateError("查询User失败", err)
+	}
+	return &v, nil
+}
+
+// List 按主键排序分页查询
+func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]User, error) {
+	if limit <= 0 || offset < 0 {
+		return nil, fmt.Errorf("%w: limit必须大于0，offset不能为负数", store.ErrInvalidQuery)
+	}
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var list []User
+	if err := sqlx.SelectContext(ctx, r.db, &list, r.db.Rebind("SELECT "+userColumns+" FROM users ORDER BY id LIMIT ? OFFSET ?"), limit, offset); err != nil {
+		return nil, store.TranslateError("查询User列表失败", err)
+	}
+	return list, nil
+}
+
+// Insert 插入一条记录，主键已存在时返回 store.ErrDuplicateKey
+func (r *UserRepository) Insert(ctx context.Context, v *User) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if _, err := sqlx.NamedExecContext(ctx, r.db, userInsert, v); err != nil {
+		return store.TranslateError("插入User失败", err)
+	}
+	return nil
+}
+
+// Update 按主键更新其它所有列，记录不存在时返回 store.ErrNotFound
+// MySQL的DSN需要 clientFoundRows=true，否则更新为相同的值也会被当作记录不存在
+func (r *UserRepository) Update(ctx context.Context, v *User) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := sqlx.NamedExecContext(ctx, r.db, userUpdate, v)
+	if err != nil {
+		return store.TranslateError("更新User失败", err)
+	}
+	return r.expectOne("更新User失败", result)
+}
+
+// Delete 按主键删除，记录不存在时返回 store.ErrNotFound
+func (r *UserRepository) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, r.db.Rebind("DELETE FROM users WHERE id = ?"), id)
+	if err != nil {
+		return store.TranslateError("删除User失败", err)
+	}
+	return r.expectOne("删除User失败", result)
+}
+
+func (r *UserRepository) expectOne(op string, result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return store.TranslateError(op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, store.ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+// Package fixtures 内嵌用户表的种子数据，由 cmd/seed 写入数据库
+//
+// 目录即数据集: common 在所有环境中加载，dev、test 等目录只在对应环境加载，
+// 同一id在环境目录中的数据覆盖common中的数据
+// 文件格式支持 .yaml/.yml、.json 和 .csv，字段名与 store.Person 的json/db标签一致
+package fixtures
+
+import "embed"
+
+//go:embed */*
+var FS embed.FS
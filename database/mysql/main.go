@@ -1,212 +1,523 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql" // MySQL驱动，使用_只导入不直接使用
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/entity"
+	"Gocommunity/database/mysql/store"
+	"Gocommunity/database/mysql/tenant"
+
 	"github.com/jmoiron/sqlx"
 )
 
 // ============================= 1. 数据库连接配置 ====================
-var db *sqlx.DB
-
-// ============================= 2. 数据模型定义 ====================
-// Person 用户结构体，使用db标签映射数据库字段
-type Person struct {
-	UserId   string `db:"id"`      // 用户ID，对应数据库id字段
-	Username string `db:"name"`    // 用户名，对应数据库name字段
-	Age      int    `db:"age"`     // 年龄，对应数据库age字段
-	Address  string `db:"address"` // 地址，对应数据库address字段
-}
+// db 读写分离连接: 查询发往从库，写操作和事务发往主库，未配置从库时全部走主库
+var db *dbx.Cluster
+
+// ============================= 2. 数据访问层 ====================
+// Person 的定义和增删改查都在 store 包中，这里只依赖 PersonRepository 接口
+// 想脱离MySQL运行时，可以换成 store.NewMemoryPersonRepository()
+var repo store.PersonRepository
+
+// repoOptions 按配置生成的仓储选项(超时、outbox、租户隔离)，事务中创建的仓储使用同样的选项
+var repoOptions []store.SQLOption
+
+// health 后台定期Ping主库，启动之后数据库不可用时也能及时发现
+var health *dbx.HealthChecker
+
+// metrics 记录每条SQL的耗时和错误，超过slow_query_threshold的打印慢查询日志
+var metrics *dbx.Metrics
 
 // ============================= 3. 初始化数据库连接 ====================
-func initDB() {
-	// 数据源格式：用户名:密码@协议(地址:端口)/数据库名
-	conn, err := sqlx.Open("mysql", "root:wyh246859@tcp(127.0.0.1:3306)/test")
+// initDB 从配置文件(DB_CONFIG_FILE)和DB_*环境变量加载配置，参考 config.example.json
+// 连接失败时返回错误，由调用方决定如何处理
+func initDB() error {
+	cfg, err := dbx.LoadConfig("")
 	if err != nil {
-		panic(fmt.Sprintf("数据库连接失败: %v", err))
+		return err
 	}
 
-	// 测试连接是否成功
-	if err := conn.Ping(); err != nil {
-		panic(fmt.Sprintf("数据库ping失败: %v", err))
+	// OpenCluster内部会设置连接池参数，并在主库Ping失败时按指数退避重试
+	conn, err := dbx.OpenCluster(context.Background(), cfg)
+	if err != nil {
+		return err
 	}
 
 	db = conn
-	fmt.Println("数据库连接成功")
+	health = dbx.NewHealthChecker(conn, dbx.HealthConfigOptions(cfg)...)
+	metrics = dbx.NewMetrics(time.Duration(cfg.SlowQueryThreshold))
+	for name, pool := range conn.Pools() {
+		metrics.RegisterPool(name, pool)
+	}
+	// 每条SQL都会在 QueryTimeout 内结束，避免MySQL变慢时调用方一直阻塞
+	// Get经过读穿透缓存: 本地LRU + 数据库，通过repo写入时自动失效
+	// 全文检索: MySQL上有FULLTEXT索引时在数据库中检索，否则使用进程内倒排索引
+	repoOptions = store.ConfigOptions(cfg)
+	repo = store.NewCachedPersonRepository(
+		store.NewSearchablePersonRepository(
+			store.NewSQLPersonRepository(dbx.Instrument(conn, metrics), repoOptions...)),
+		store.WithCacheTTL(30*time.Second),
+	)
+	fmt.Printf("数据库连接成功: %s\n", cfg)
+	return nil
 }
 
 // ============================= 4. 查询操作 ====================
+// 各操作只返回错误，不在内部打印，由调用方决定如何处理
+
 // 4.1 单条记录查询
-func querySingle() {
-	var person Person
+func querySingle(ctx context.Context) error {
 	// Get用于查询单条记录，结果映射到结构体
-	err := db.Get(&person, "SELECT id, name, age, address FROM user WHERE id = ?", "12132")
+	person, err := repo.Get(ctx, "12132")
 	if err != nil {
-		fmt.Printf("单条查询失败: %v\n", err)
-		return
+		return err
+	}
+	fmt.Printf("单条查询成功: %+v\n", *person)
+
+	// 第二次查询命中缓存，不再访问数据库
+	if _, err := repo.Get(ctx, "12132"); err != nil {
+		return err
 	}
-	fmt.Printf("单条查询成功: %+v\n", person)
+	if cached, ok := repo.(*store.CachedPersonRepository); ok {
+		fmt.Printf("缓存统计: %+v\n", cached.Stats())
+	}
+	return nil
 }
 
 // 4.2 多条记录查询
-func queryMultiple() {
-	var persons []Person
-	// Select用于查询多条记录，结果映射到结构体切片
-	err := db.Select(&persons, "SELECT id, name, age, address FROM user")
-	if err != nil {
-		fmt.Printf("多条查询失败: %v\n", err)
-		return
+func queryMultiple(ctx context.Context) error {
+	// 分页查询: 每页2条，按年龄倒序，使用游标逐页向后翻
+	opts := store.ListOptions{SortBy: "age", Desc: true, Limit: 2}
+	for pageNo := 1; ; pageNo++ {
+		page, err := repo.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("多条查询成功，共%d条记录，第%d页: %+v\n", page.Total, pageNo, page.Items)
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		opts.Cursor = page.NextCursor
 	}
-	fmt.Printf("多条查询成功，共%d条记录: %+v\n", len(persons), persons)
 }
 
-// ============================= 5. 增删改操作 ====================
-// 5.1 插入数据
-func insertData() {
-	// Exec执行不返回结果的SQL语句
-	result, err := db.Exec("INSERT INTO user VALUES (?, ?, ?, ?)",
-		"120230", "李四", 12, "广州市")
+// 4.3 全文检索
+func searchPersons(ctx context.Context) error {
+	// 关键字可以是用户名或地址的一部分，多个词需要同时匹配，结果按相关度排序
+	result, err := repo.(store.Searcher).Search(ctx, store.SearchOptions{Query: "张", Limit: 5})
 	if err != nil {
-		fmt.Printf("插入失败: %v\n", err)
-		return
+		return err
 	}
+	fmt.Printf("检索\"张\"共%d条:\n", result.Total)
+	for _, hit := range result.Items {
+		fmt.Printf("  %s 相关度%.4f 高亮%v\n", hit.UserId, hit.Score, hit.Highlights)
+	}
+	return nil
+}
 
-	// 获取最后插入的ID
-	id, err := result.LastInsertId()
-	if err != nil {
-		fmt.Printf("获取插入ID失败: %v\n", err)
-		return
+// ============================= 5. 增删改操作 ====================
+// 5.1 插入数据
+func insertData(ctx context.Context) error {
+	// 命名参数插入，字段通过db标签映射
+	person := &store.Person{UserId: "120230", Username: "李四", Age: 12, Address: "广州市"}
+	if err := repo.Create(ctx, person); err != nil {
+		return err
 	}
-	fmt.Printf("插入成功，插入ID: %d\n", id)
+	fmt.Printf("插入成功，插入ID: %s\n", person.UserId)
+	return nil
 }
 
 // 5.2 更新数据
-func updateData() {
-	result, err := db.Exec("UPDATE user SET name = ? WHERE id = ?",
-		"赵六", "120230")
+func updateData(ctx context.Context) error {
+	// 先读取再更新: Update会校验读取时的版本号，期间被其他人修改过则返回ErrVersionConflict
+	person, err := repo.Get(ctx, "120230")
 	if err != nil {
-		fmt.Printf("更新失败: %v\n", err)
-		return
+		return err
 	}
 
-	// 获取受影响的行数
-	affected, err := result.RowsAffected()
-	if err != nil {
-		fmt.Printf("获取影响行数失败: %v\n", err)
-		return
+	person.Username = "赵六"
+	if err := repo.Update(ctx, person); err != nil {
+		return err
 	}
-	fmt.Printf("更新成功，影响行数: %d\n", affected)
+	fmt.Printf("更新成功: %+v\n", *person)
+	return nil
 }
 
 // 5.3 删除数据
-func deleteData() {
-	result, err := db.Exec("DELETE FROM user WHERE id = ?", "120230")
-	if err != nil {
-		fmt.Printf("删除失败: %v\n", err)
-		return
+func deleteData(ctx context.Context) error {
+	// Delete是软删除，只设置deleted_at，默认查询不再返回该记录
+	if err := repo.Delete(ctx, "120230"); err != nil {
+		return err
+	}
+	fmt.Println("软删除成功")
+
+	trash, ok := repo.(store.SoftDeleter)
+	if !ok {
+		return nil
+	}
+	// 误删可以恢复
+	if err := trash.Restore(ctx, "120230"); err != nil {
+		return err
+	}
+	fmt.Println("恢复成功")
+
+	// 确认要删除时，先软删除再彻底删除
+	if err := repo.Delete(ctx, "120230"); err != nil {
+		return err
+	}
+	if err := trash.Purge(ctx, "120230"); err != nil {
+		return err
+	}
+	fmt.Println("彻底删除成功")
+	return nil
+}
+
+// 5.4 批量写入
+func batchImport(ctx context.Context) error {
+	persons := []store.Person{
+		{UserId: "200001", Username: "王五", Age: 20, Address: "深圳市"},
+		{UserId: "200002", Username: "孙七", Age: 22, Address: "杭州市"},
+		{UserId: "200003", Username: "周八", Age: 24, Address: "成都市"},
+	}
+	writer, ok := repo.(store.BatchWriter)
+	if !ok {
+		return fmt.Errorf("当前仓储不支持批量写入")
 	}
 
-	affected, err := result.RowsAffected()
+	// Upsert: 重复执行demo时已存在的记录会被更新而不是报主键冲突
+	result, err := writer.BatchInsert(ctx, persons, store.BatchOptions{BatchSize: 2, Upsert: true})
 	if err != nil {
-		fmt.Printf("获取影响行数失败: %v\n", err)
-		return
+		return err
 	}
-	fmt.Printf("删除成功，影响行数: %d\n", affected)
+	fmt.Printf("批量写入成功，共%d批，每批影响行数: %v\n", len(result.Affected), result.Affected)
+	return nil
 }
 
 // ============================= 6. 事务操作 ====================
-func transactionDemo() {
-	// 开始事务
-	tx, err := db.Begin()
+func transactionDemo(ctx context.Context) error {
+	// WithTx 负责开始/提交/回滚事务，遇到死锁(1213)或锁等待超时(1205)会自动重试
+	opts := dbx.DefaultTxOptions()
+	opts.Isolation = sql.LevelReadCommitted
+
+	// 经过指标包装: 事务整体记为TRANSACTION，事务内的语句同样逐条统计
+	txDB := dbx.Instrument(db, metrics)
+	err := dbx.WithTx(ctx, txDB, opts, func(tx *sqlx.Tx) error {
+		// 仓储可以直接基于事务创建，事务内的操作要么全部成功，要么全部回滚
+		txRepo := store.NewSQLPersonRepository(dbx.InstrumentTx(txDB, tx), repoOptions...)
+		person := &store.Person{UserId: "99999", Username: "事务测试", Age: 30, Address: "事务地址"}
+		if err := txRepo.Create(ctx, person); err != nil {
+			return fmt.Errorf("事务内插入失败: %w", err)
+		}
+
+		person.Age = 99
+		if err := txRepo.Update(ctx, person); err != nil {
+			return fmt.Errorf("事务内更新失败: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		fmt.Printf("事务开始失败: %v\n", err)
-		return
+		return err
+	}
+	// 事务中的仓储绕过了缓存，提交后需要手动失效
+	if cached, ok := repo.(*store.CachedPersonRepository); ok {
+		cached.Invalidate(ctx, "99999")
 	}
 
-	// 确保事务回滚（如果提交成功，回滚将无效）
-	defer tx.Rollback()
+	fmt.Println("事务执行成功")
+	return nil
+}
 
-	// 在事务中执行多个操作
-	_, err = tx.Exec("INSERT INTO user VALUES (?, ?, ?, ?)",
-		"99999", "事务测试", 30, "事务地址")
-	if err != nil {
-		fmt.Printf("事务内插入失败: %v\n", err)
-		return
+// tenantDemo 每个租户一个独立的仓储(这里用内存仓储演示)，不同租户可以使用相同的ID
+// 共享一张表时配置 multi_tenant=true，由 store.WithTenantScope 按tenant_id隔离，效果相同
+func tenantDemo(ctx context.Context) error {
+	router := store.NewTenantRouter(store.MemoryPerTenant())
+	defer router.Close()
+
+	for _, t := range []string{"acme", "globex"} {
+		tctx := tenant.NewContext(ctx, t)
+		if err := router.Create(tctx, &store.Person{UserId: "1", Username: t + "的管理员", Age: 30}); err != nil {
+			return err
+		}
+		p, err := router.Get(tctx, "1")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("租户%s: %s\n", t, p.Username)
 	}
 
-	_, err = tx.Exec("UPDATE user SET age = ? WHERE id = ?", 99, "99999")
-	if err != nil {
-		fmt.Printf("事务内更新失败: %v\n", err)
-		return
+	// 没有租户的查询会被拒绝
+	_, err := router.Get(context.Background(), "1")
+	fmt.Printf("未指定租户: %v\n", err)
+	return nil
+}
+
+// generatedDemo books表的仓储由 cmd/sqlxgen 按 entity.Book 的标签生成，没有手写SQL
+func generatedDemo(ctx context.Context) error {
+	books := entity.NewBookRepository(db, dbx.DefaultQueryTimeout)
+	// 表由迁移0007创建，CreateTable 只在表不存在时建表，方便没有执行迁移时运行demo
+	if err := books.CreateTable(ctx); err != nil {
+		return err
 	}
 
-	// 提交事务
-	if err := tx.Commit(); err != nil {
-		fmt.Printf("事务提交失败: %v\n", err)
-		return
+	book := &entity.Book{ISDN: "978-7-111-54742-6", Title: "Go程序设计语言", Author: "Donovan", Pages: 380}
+	// 重复运行demo时记录已经存在，改为更新
+	err := books.Insert(ctx, book)
+	if errors.Is(err, store.ErrDuplicateKey) {
+		err = books.Update(ctx, book)
 	}
+	if err != nil {
+		return err
+	}
+	got, err := books.Get(ctx, book.ISDN)
+	if err != nil {
+		return err
+	}
+	list, err := books.List(ctx, 10, 0)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("生成的仓储: %+v，books表共%d条(最多显示10条)\n", *got, len(list))
+	return nil
+}
 
-	fmt.Println("事务执行成功")
+// ============================= 7. 错误处理 ====================
+// describeError 根据错误类别给出提示，errors.Is 可以穿透多层包装
+func describeError(err error) string {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return "记录不存在"
+	case errors.Is(err, store.ErrDuplicateKey):
+		return "记录已存在"
+	case errors.Is(err, store.ErrVersionConflict):
+		return "记录已被修改，请重新读取后再更新"
+	case errors.Is(err, store.ErrConstraint):
+		return "数据不满足约束"
+	case errors.Is(err, store.ErrConnection):
+		return "数据库连接不可用，请稍后重试"
+	default:
+		return "未知错误"
+	}
 }
 
 func main() {
 	// 初始化数据库连接
-	initDB()
+	if err := initDB(); err != nil {
+		log.Fatalf("初始化数据库失败: %v", err)
+	}
 	defer db.Close() // 确保程序退出前关闭数据库连接
+	defer health.Close()
+
+	// 所有操作共享同一个根context，按Ctrl+C会取消正在执行的SQL
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// demo中写入后马上读取，开启读己之写避免从库延迟读不到刚写入的数据
+	ctx = dbx.WithReadYourWrites(ctx)
+	// 配置了multi_tenant时所有读写都必须指定租户，demo的数据属于租户demo
+	ctx = tenant.NewContext(ctx, "demo")
+
+	// 执行各种数据库操作，失败时打印错误类别和详细信息
+	steps := []struct {
+		name string
+		run  func(context.Context) error
+	}{
+		{"单条查询", querySingle},
+		{"多条查询", queryMultiple},
+		{"全文检索", searchPersons},
+		{"插入数据", insertData},
+		{"更新数据", updateData},
+		{"删除数据", deleteData},
+		{"批量写入", batchImport},
+		{"事务演示", transactionDemo},
+		{"多租户", tenantDemo},
+		{"生成的仓储", generatedDemo},
+		{"最终数据", queryMultiple},
+	}
+	for _, step := range steps {
+		fmt.Printf("\n=== %s ===\n", step.name)
+		if err := step.run(ctx); err != nil {
+			fmt.Printf("%s失败(%s): %v\n", step.name, describeError(err), err)
+		}
+	}
 
-	// 执行各种数据库操作
-	fmt.Println("\n=== 单条查询 ===")
-	querySingle()
-
-	fmt.Println("\n=== 多条查询 ===")
-	queryMultiple()
-
-	fmt.Println("\n=== 插入数据 ===")
-	insertData()
-
-	fmt.Println("\n=== 更新数据 ===")
-	updateData()
-
-	fmt.Println("\n=== 删除数据 ===")
-	deleteData()
+	printMetrics()
+	printHealth()
+}
 
-	fmt.Println("\n=== 事务演示 ===")
-	transactionDemo()
+// printHealth 打印健康检查的结果，HTTP服务中通过 /healthz 和 /readyz 暴露
+func printHealth() {
+	status := health.Status()
+	fmt.Println("\n=== 健康检查 ===")
+	fmt.Printf("就绪: %v 连续失败: %d 最近一次Ping: %.2fms\n", status.Ready, status.ConsecutiveFailures, status.LatencyMs)
+	if status.LastError != "" {
+		fmt.Printf("最近的错误: %s\n", status.LastError)
+	}
+}
 
-	fmt.Println("\n=== 最终数据 ===")
-	queryMultiple()
+// printMetrics 打印各条SQL的执行统计和连接池状态
+func printMetrics() {
+	snap := metrics.Snapshot()
+	fmt.Println("\n=== SQL统计 ===")
+	for _, st := range snap.Statements {
+		fmt.Printf("%4d次 错误%d 慢%d 平均%.2fms 最大%.2fms  %s\n", st.Count, st.Errors, st.Slow, st.AvgMs, st.MaxMs, st.Query)
+	}
+	for name, pool := range snap.Pools {
+		fmt.Printf("连接池 %s: 打开%d 使用中%d 空闲%d 等待%d次\n", name, pool.OpenConnections, pool.InUse, pool.Idle, pool.WaitCount)
+	}
 }
 
 // ============================= 总结知识点 ====================
 /*
 1. 数据库驱动:
-   - 使用 _ "github.com/go-sql-driver/mysql" 导入MySQL驱动
+   - 使用 _ "github.com/go-sql-driver/mysql" 导入MySQL驱动(在dbx包中)
    - 驱动会自动注册到database/sql
 
 2. 连接数据库:
-   - 使用sqlx.Open("mysql", DSN)建立连接
-   - DSN格式: 用户名:密码@协议(地址:端口)/数据库名
-   - 使用Ping()测试连接
+   - dbx.LoadConfig(): 默认值 -> JSON配置文件 -> DB_*环境变量
+   - Config.DSN(): 用mysql.Config拼接DSN，避免手写字符串
+   - DSN格式: 用户名:密码@协议(地址:端口)/数据库名?参数
+   - dbx.Open(): 设置连接池(最大连接数、空闲数、连接生命周期)
+   - Ping失败按指数退避重试，最终返回error而不是panic
 
 3. 数据映射:
    - 定义结构体，使用db标签映射数据库字段
    - Get()查询单条记录到结构体
    - Select()查询多条记录到结构体切片
+   - NamedExec()使用:name形式的命名参数，直接绑定结构体
+   - 列表查询必须分页: offset分页简单，游标(keyset)分页在深翻页时不会越来越慢
+   - 排序字段走白名单，LIKE参数转义 % 和 _
+
+4. 仓储模式:
+   - store.PersonRepository 接口封装增删改查
+   - SQLPersonRepository 基于sqlx，MemoryPersonRepository 基于map
+   - sql.ErrNoRows 和影响行数为0统一转换为 store.ErrNotFound
 
-4. SQL执行:
+5. SQL执行:
    - Exec()执行不返回结果的SQL(INSERT/UPDATE/DELETE)
    - LastInsertId()获取最后插入ID
    - RowsAffected()获取影响行数
+   - 批量插入: NamedExec传入结构体切片，sqlx展开为多行VALUES
+   - 分批同时考虑行数和语句大小，避免超过max_allowed_packet和65535个占位符
+   - Upsert: ON DUPLICATE KEY UPDATE，影响行数 插入计1、更新计2
 
-5. 事务处理:
+6. 事务处理:
    - Begin()开始事务
    - Commit()提交事务
    - Rollback()回滚事务
    - 使用defer确保事务回滚，避免资源泄漏
+   - dbx.WithTx(): 回调返回nil提交，返回错误或panic回滚
+   - TxOptions 支持隔离级别、只读事务，以及死锁/锁等待超时的有限次退避重试
+   - 回调可能被执行多次，必须是可重试的(不要在其中发消息、调外部接口)
 
-6. 最佳实践:
+7. Context与超时:
+   - 使用GetContext/SelectContext/ExecContext，而不是Get/Select/Exec
+   - 仓储为每条SQL派生带超时的context(QueryTimeout)，父context取消时SQL同样被取消
+   - HTTP服务中传入r.Context()/c.Request.Context()，客户端断开后查询随之结束
+
+8. 最佳实践:
    - 及时关闭数据库连接(defer db.Close())
    - 使用预处理语句防止SQL注入
-   - 合理的错误处理
+   - 合理的错误处理: 数据层只返回错误，不打印
+   - 错误归类: ErrNotFound/ErrDuplicateKey(1062)/ErrConstraint/ErrConnection
+   - HTTP层用 store.HTTPStatus(err) 统一映射为 404/409/400/503
    - 事务中确保原子性操作
+
+9. 软删除与审计字段:
+   - created_at/updated_at 由仓储在写入时自动设置
+   - Delete 只设置 deleted_at，查询默认带 deleted_at IS NULL 条件
+   - Restore 恢复回收站中的记录，Purge 只能物理删除已软删除的记录
+   - ListOptions.Deleted 可以查询包含已删除或只查询已删除的记录
+
+10. 乐观锁:
+   - version列每次修改加1，UPDATE ... WHERE id = ? AND version = ?
+   - 影响行数为0时再查一次，区分记录不存在(ErrNotFound)和版本冲突(ErrVersionConflict)
+   - HTTP接口: GET返回ETag，PUT必须带If-Match，版本不一致返回412
+
+11. 读写分离:
+   - dbx.Cluster 实现 sqlx.ExtContext，Query发往从库(轮询)，Exec和事务发往主库
+   - 后台定时Ping从库，不健康的从库自动摘除，全部不可用时回退主库
+   - dbx.WithReadYourWrites(ctx): 同一请求写过之后的读走主库
+   - dbx.WithPrimary(ctx): 强制读主库
+
+12. 表结构迁移:
+   - 运行demo前先执行 go run ./database/mysql/cmd/migrate up 创建user表
+   - 再执行 go run ./database/mysql/cmd/seed 写入演示数据(单条查询依赖id为12132的记录)
+   - migrations目录存放 <版本>_<名称>.up.sql/.down.sql，已执行版本记录在schema_migrations表
+   - 迁移期间持有MySQL命名锁(GET_LOCK)，多个实例不会同时执行
+
+13. 查询指标与慢查询日志:
+   - dbx.Instrument(db, metrics) 包装数据库，记录每条SQL的耗时直方图和错误数
+   - 连接池状态来自 db.Stats()，等待次数多说明 MaxOpenConns 偏小
+   - 超过 slow_query_threshold 的SQL打印慢查询日志，参数只输出类型不输出值
+   - dbx.WithTx 的每次执行记为 TRANSACTION(包括死锁重试)，回调中用 dbx.InstrumentTx 包装事务，事务内的SQL同样被统计
+   - HTTP服务通过 GET /metrics 以JSON输出指标
+
+14. 多数据库方言:
+   - 配置 driver 为 mysql/postgres/sqlite，dbx.Open 按驱动生成DSN
+   - 仓储中的SQL统一写?占位符，执行前用 db.Rebind() 转换(PostgreSQL为$1、$2)
+   - store.Dialect 处理表名引号、LIKE转义和upsert(ON DUPLICATE KEY / ON CONFLICT)的差异
+   - 本地开发可以不启动数据库: DB_DRIVER=sqlite DB_NAME=:memory: 并先执行迁移
+   - storetest.TestPersonRepository 是各实现共用的一致性检查，见 cmd/conformance
+
+15. 集成测试:
+   - dbtest.New(t) 返回已执行迁移的独立测试库，测试结束自动删除，不需要Docker
+   - 默认使用进程内SQLite；DBTEST_DRIVER=mysql 时按 DB_* 连接服务器，每个测试一个 dbtest_ 库
+   - dbtest.WithPersons(dbtest.DemoPersons...) 写入测试数据，本文件的演示函数可以这样测试:
+     db = dbx.NewCluster(dbtest.New(t, ...), nil, 0); repo = store.NewSQLPersonRepository(db)
+   - store/sql_test.go 覆盖增删改查、批量写入和事务的死锁重试，dbtest/dbtest_test.go 检查并行测试之间的隔离
+
+16. 种子数据:
+   - fixtures/<数据集>/ 下的 .yaml/.json/.csv 文件，common 总是加载，-env 指定的数据集覆盖同id记录
+   - seed.Apply 按id比较后新建、更新或恢复，重复执行结果相同
+   - cmd/seed 在一个事务中写入，-dry-run 只输出将要执行的修改
+17. 导入导出:
+   - transfer.Export 按id顺序流式读取，输出CSV(表头为db标签)或JSON Lines
+   - transfer.Import 逐行校验，每块一个事务提交，失败的行记录行号和原因后继续导入其余行
+   - cmd/transfer export/import，-dry-run 只校验不提交，-mode insert 遇到已存在的id报错
+18. 字段加密:
+   - Person.Address 的类型是 secret.String，实现 sql.Scanner/driver.Valuer，写入时AES-GCM加密、读取时解密
+   - 密文格式 enc:v1:<密钥ID>:<base64>，DB_ENCRYPTION_KEYS 的第一个密钥加密新数据，其余只用于解密
+   - cmd/reencrypt 把明文和旧密钥密文改写为当前密钥，完成后才能删除旧密钥；加密后不支持按地址LIKE筛选
+   - 相同明文每次加密的密文不同，SQL中无法比较地址；批量upsert先读出已有记录在Go中比较，未变化的行不写入
+19. 查询构造器:
+   - query.NewTable 按结构体db标签生成列白名单，未知列在ToSQL时返回 query.ErrUnknownColumn
+   - Select/Where/And/Or/In/OrderBy/Limit 生成?占位符的SQL和参数，值全部参数化
+   - repo.Query() + repo.Find/Count 执行自定义查询，List 也改为使用构造器
+20. 变更事件(outbox):
+   - 配置 outbox=true(DB_OUTBOX) 后，增删改和批量写入在同一事务中向outbox表写入 person.* 事件
+   - outbox.Relay 按ID顺序投递到 Sink(LogSink、Webhook、进程内Bus)，失败时指数退避重试，同一用户的事件保持顺序
+   - 至少投递一次，下游按事件ID去重；cmd/relay 运行投递进程
+21. 读缓存:
+   - store.NewCachedPersonRepository 包装任意仓储，Get依次查找本地LRU(带TTL)、外部缓存(cache.Store)和数据库
+   - 同一ID的并发未命中只查询一次(cache.Group)，不存在的ID短时间缓存，写操作和 Invalidate 使缓存失效
+   - cache.Local 是外部缓存的进程内替身，接入Redis时实现 cache.Store 即可
+22. 多租户:
+   - HTTP中间件用 tenant.Resolver 确定租户，通过 tenant.NewContext 放入context，仓储从context读取
+   - tenant.PrincipalResolver 按认证后的身份查出租户；tenant.HeaderResolver 信任 X-Tenant-ID 请求头，任何人都能切换租户，
+     演示服务只在 TENANT_TRUST_HEADER=true 时使用
+   - 共享表: 配置 multi_tenant=true(store.WithTenantScope)，所有SQL带 tenant_id 条件，主键为(tenant_id, id)
+   - 未指定租户的读写返回 tenant.ErrMissing，Query构造器未限定租户时返回 query.ErrUnscoped
+   - 独立schema/数据库: store.NewTenantRouter + SchemaPerTenant/DatabasePerTenant，按租户转发到各自的仓储
+   - DatabasePerTenant 用 dbx.Connect 打开租户库，不替换进程级的加密密钥环，租户配置不能单独设置密钥
+23. 健康检查:
+   - dbx.NewHealthChecker 在后台按 health_check_interval 定期Ping主库，记录连续失败次数、Ping耗时和连接池状态
+   - 连续失败达到 health_failure_threshold 次才判定为不可用，一次成功即恢复，避免偶发超时导致实例被摘除
+   - /healthz 存活检查总是返回200(重启进程不能修复数据库)，/readyz 在数据库不可用时返回503
+24. 代码生成:
+   - 结构体写好db标签和ddl标签(pk/unique/index/size=N/text)，加上 //go:generate go run Gocommunity/database/mysql/cmd/sqlxgen -type Book -table books
+   - go generate 生成 <类型>_gen.go: Get/List/Insert/Update/Delete(命名参数)和三种数据库的建表语句 <类型>Schema
+   - entity.Book 和 entity.User 是生成的例子；建表语句用于新表，之后的结构变更仍然写迁移
+25. 全文检索:
+   - store.NewSearchablePersonRepository 为仓储提供 Search，按用户名和地址检索，支持中文片段，结果带相关度和<em>高亮
+   - MySQL执行迁移0008(FULLTEXT ... WITH PARSER ngram)后用 MATCH ... AGAINST 在数据库中检索
+   - PostgreSQL、SQLite、内存实现和加密的地址使用进程内倒排索引(单字+相邻两字)，随写入更新，过期后重建
+   - HTTP接口: GET /persons/search?q=关键字&limit=&offset=
 */
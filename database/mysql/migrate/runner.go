@@ -0,0 +1,269 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// versionTable 记录已执行迁移的表
+	versionTable = "schema_migrations"
+	// lockName MySQL命名锁，防止多个实例同时执行迁移
+	lockName = "schema_migrations_lock"
+	// advisoryLockKey PostgreSQL咨询锁的键，作用与lockName相同
+	advisoryLockKey = 7240531
+	// lockPollInterval PostgreSQL尝试加锁的间隔
+	lockPollInterval = 200 * time.Millisecond
+)
+
+// ErrLocked 在等待LockTimeout后仍未拿到迁移锁时返回
+var ErrLocked = errors.New("其他进程正在执行迁移")
+
+// ============================= 1. 迁移执行器 ====================
+// Runner 负责按版本执行迁移，并在schema_migrations表中记录结果
+// 支持MySQL、PostgreSQL和SQLite，按 db.DriverName() 选择加锁方式和建表语句
+type Runner struct {
+	db         *sqlx.DB
+	migrations []Migration
+
+	// LockTimeout 等待迁移锁的最长时间
+	LockTimeout time.Duration
+	// Logf 输出执行进度，默认不输出
+	Logf func(format string, args ...any)
+}
+
+// Status 某个迁移版本的执行状态
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// New 从fsys加载迁移文件并创建执行器
+func New(db *sqlx.DB, fsys fs.FS) (*Runner, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{
+		db:          db,
+		migrations:  migrations,
+		LockTimeout: 30 * time.Second,
+		Logf:        func(string, ...any) {},
+	}, nil
+}
+
+// ============================= 2. 升级与回滚 ====================
+// Up 执行所有未执行的迁移，返回本次执行的迁移
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := r.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range r.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			r.Logf("执行迁移 %d_%s", m.Version, m.Name)
+			if err := execScript(ctx, conn, m.Up); err != nil {
+				return fmt.Errorf("迁移 %d_%s 执行失败: %w", m.Version, m.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx,
+				conn.Rebind("INSERT INTO "+versionTable+" (version, name, applied_at) VALUES (?, ?, ?)"),
+				m.Version, m.Name, time.Now()); err != nil {
+				return fmt.Errorf("记录迁移 %d 失败: %w", m.Version, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 按版本倒序回滚最近的steps个迁移，返回本次回滚的迁移
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := r.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(r.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			m := r.migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("迁移 %d_%s 没有down文件，无法回滚", m.Version, m.Name)
+			}
+			r.Logf("回滚迁移 %d_%s", m.Version, m.Name)
+			if err := execScript(ctx, conn, m.Down); err != nil {
+				return fmt.Errorf("迁移 %d_%s 回滚失败: %w", m.Version, m.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx,
+				conn.Rebind("DELETE FROM "+versionTable+" WHERE version = ?"), m.Version); err != nil {
+				return fmt.Errorf("删除迁移记录 %d 失败: %w", m.Version, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status 返回每个迁移版本是否已执行
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	conn, err := r.db.Connx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取数据库连接失败: %w", err)
+	}
+	defer conn.Close()
+
+	applied, err := r.appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		at, ok := applied[m.Version]
+		statuses = append(statuses, Status{Migration: m, Applied: ok, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+// ============================= 3. 内部实现 ====================
+// withLock 在同一个连接上获取迁移锁并执行fn
+// MySQL的GET_LOCK和PostgreSQL的咨询锁都是会话级别的，所以迁移必须和加锁使用同一个连接
+// SQLite是单文件数据库，通常只有一个进程执行迁移，不加锁
+func (r *Runner) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) (err error) {
+	conn, err := r.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %w", err)
+	}
+	defer conn.Close()
+
+	var release func(context.Context) error
+	switch r.db.DriverName() {
+	case "postgres":
+		release, err = r.lockPostgres(ctx, conn)
+	case "sqlite":
+		release = func(context.Context) error { return nil }
+	default:
+		release, err = r.lockMySQL(ctx, conn)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// 使用独立的context释放锁，避免ctx取消后锁无法释放
+		if releaseErr := release(context.Background()); releaseErr != nil && err == nil {
+			err = fmt.Errorf("释放迁移锁失败: %w", releaseErr)
+		}
+	}()
+
+	if err := r.ensureVersionTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// lockMySQL 使用GET_LOCK获取命名锁，最多等待LockTimeout
+func (r *Runner) lockMySQL(ctx context.Context, conn *sqlx.Conn) (func(context.Context) error, error) {
+	var got int
+	if err := conn.GetContext(ctx, &got, "SELECT COALESCE(GET_LOCK(?, ?), 0)",
+		lockName, int(r.LockTimeout.Seconds())); err != nil {
+		return nil, fmt.Errorf("获取迁移锁失败: %w", err)
+	}
+	if got != 1 {
+		return nil, ErrLocked
+	}
+	return func(ctx context.Context) error {
+		_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+		return err
+	}, nil
+}
+
+// lockPostgres 轮询pg_try_advisory_lock，超过LockTimeout仍未拿到锁时返回ErrLocked
+func (r *Runner) lockPostgres(ctx context.Context, conn *sqlx.Conn) (func(context.Context) error, error) {
+	deadline := time.Now().Add(r.LockTimeout)
+	for {
+		var got bool
+		if err := conn.GetContext(ctx, &got, "SELECT pg_try_advisory_lock($1)", advisoryLockKey); err != nil {
+			return nil, fmt.Errorf("获取迁移锁失败: %w", err)
+		}
+		if got {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+	return func(ctx context.Context) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", advisoryLockKey)
+		return err
+	}, nil
+}
+
+func (r *Runner) ensureVersionTable(ctx context.Context, conn *sqlx.Conn) error {
+	ddl := `CREATE TABLE IF NOT EXISTS ` + versionTable + ` (
+    version    BIGINT       NOT NULL,
+    name       VARCHAR(255) NOT NULL,
+    applied_at %s NOT NULL,
+    PRIMARY KEY (version)
+)`
+	switch r.db.DriverName() {
+	case "postgres":
+		ddl = fmt.Sprintf(ddl, "TIMESTAMP")
+	case "sqlite":
+		ddl = fmt.Sprintf(ddl, "DATETIME")
+	default:
+		ddl = fmt.Sprintf(ddl, "DATETIME") + " ENGINE = InnoDB DEFAULT CHARSET = utf8mb4"
+	}
+	if _, err := conn.ExecContext(ctx, ddl); err != nil {
+		return fmt.Errorf("创建%s表失败: %w", versionTable, err)
+	}
+	return nil
+}
+
+// appliedVersions 返回已执行的版本号及执行时间
+func (r *Runner) appliedVersions(ctx context.Context, conn *sqlx.Conn) (map[int64]time.Time, error) {
+	if err := r.ensureVersionTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Version   int64     `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := conn.SelectContext(ctx, &rows, "SELECT version, applied_at FROM "+versionTable); err != nil {
+		return nil, fmt.Errorf("查询迁移记录失败: %w", err)
+	}
+	applied := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+// execScript 逐条执行脚本中的语句
+// 注意: MySQL的DDL会隐式提交，迁移中途失败时需要人工检查并修复
+// PostgreSQL和SQLite的DDL可以放在事务中，但这里为了行为一致同样逐条执行
+func execScript(ctx context.Context, conn *sqlx.Conn, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
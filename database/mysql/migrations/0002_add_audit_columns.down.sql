@@ -0,0 +1,6 @@
+-- 回滚前已软删除的记录会重新变为可见
+ALTER TABLE user
+    DROP INDEX idx_user_deleted_at,
+    DROP COLUMN deleted_at,
+    DROP COLUMN updated_at,
+    DROP COLUMN created_at;
@@ -0,0 +1,7 @@
+-- 审计字段和软删除标记
+-- deleted_at 为NULL表示未删除，查询默认只返回未删除的记录
+ALTER TABLE user
+    ADD COLUMN created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    ADD COLUMN updated_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),
+    ADD COLUMN deleted_at DATETIME(3) NULL DEFAULT NULL,
+    ADD INDEX idx_user_deleted_at (deleted_at);
@@ -0,0 +1,3 @@
+-- 乐观锁版本号，每次修改加1，更新时必须携带读取时的版本号
+ALTER TABLE user
+    ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
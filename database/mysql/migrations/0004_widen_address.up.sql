@@ -0,0 +1,3 @@
+-- 地址加密存储(secret.String)后长度会增加: 255个字符(utf8mb4最多1020字节)加密后约1440个字符
+ALTER TABLE user
+    MODIFY COLUMN address VARCHAR(2048) NOT NULL DEFAULT '';
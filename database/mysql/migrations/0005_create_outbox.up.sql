@@ -0,0 +1,16 @@
+-- 事务性发件箱: 写入user的同一事务中插入变更事件，由 outbox.Relay 投递给下游服务
+-- published_at 为NULL表示尚未投递成功，next_attempt_at 之前不会再次尝试
+CREATE TABLE IF NOT EXISTS outbox (
+    id              BIGINT        NOT NULL AUTO_INCREMENT,
+    topic           VARCHAR(64)   NOT NULL,
+    aggregate_id    VARCHAR(64)   NOT NULL,
+    payload         TEXT          NOT NULL,
+    created_at      DATETIME(3)   NOT NULL,
+    attempts        INT           NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME(3)   NOT NULL,
+    published_at    DATETIME(3)   NULL DEFAULT NULL,
+    last_error      VARCHAR(1024) NOT NULL DEFAULT '',
+    PRIMARY KEY (id),
+    INDEX idx_outbox_pending (published_at, next_attempt_at),
+    INDEX idx_outbox_aggregate (aggregate_id, published_at, id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,5 @@
+-- 不同租户中存在相同的用户ID时回滚会因主键冲突失败，需要先清理数据
+ALTER TABLE user
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (id),
+    DROP COLUMN tenant_id;
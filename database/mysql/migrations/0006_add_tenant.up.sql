@@ -0,0 +1,6 @@
+-- 多租户: 多个租户的数据保存在同一张表中，启用租户隔离(store.WithTenantScope)后所有读写都带 tenant_id 条件
+-- 主键改为(tenant_id, id)，不同租户可以使用相同的用户ID，已有数据属于默认租户('')
+ALTER TABLE user
+    ADD COLUMN tenant_id VARCHAR(32) NOT NULL DEFAULT '' FIRST,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (tenant_id, id);
@@ -0,0 +1 @@
+ALTER TABLE user DROP INDEX ft_user_name_address;
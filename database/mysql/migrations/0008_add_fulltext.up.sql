@@ -0,0 +1,4 @@
+-- 用户名和地址的全文索引，ngram分词按ngram_token_size(默认2)切分，中文不需要分词词典
+-- 用于 SQLPersonRepository.Search；配置了地址加密时索引中是密文，检索改用进程内索引
+-- 大表上创建FULLTEXT索引会重建表，请在低峰期执行
+ALTER TABLE user ADD FULLTEXT INDEX ft_user_name_address (name, address) WITH PARSER ngram;
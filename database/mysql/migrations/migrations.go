@@ -0,0 +1,28 @@
+// Package migrations 内嵌user表的版本化迁移SQL
+//
+// 文件命名规则: <版本号>_<名称>.up.sql / <版本号>_<名称>.down.sql
+// 版本号递增且不可修改已发布的文件，新变更请新增一个版本
+//
+// 根目录是MySQL的迁移，postgres 和 sqlite 子目录是对应数据库的迁移，
+// 各数据库的版本号和表结构保持一致
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+//go:embed *.sql postgres/*.sql sqlite/*.sql
+var FS embed.FS
+
+// For 返回指定驱动使用的迁移文件
+func For(driver string) (fs.FS, error) {
+	switch driver {
+	case "", "mysql":
+		return FS, nil
+	case "postgres", "sqlite":
+		return fs.Sub(FS, driver)
+	}
+	return nil, fmt.Errorf("没有 %s 的迁移文件", driver)
+}
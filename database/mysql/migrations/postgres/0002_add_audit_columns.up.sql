@@ -0,0 +1,7 @@
+-- 审计字段和软删除标记
+-- PostgreSQL没有 ON UPDATE CURRENT_TIMESTAMP，updated_at 由仓储在写入时设置
+ALTER TABLE "user"
+    ADD COLUMN created_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN updated_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN deleted_at TIMESTAMP(3) NULL DEFAULT NULL;
+CREATE INDEX idx_user_deleted_at ON "user" (deleted_at);
@@ -0,0 +1,3 @@
+-- 回滚前地址中不能有超过255个字符的值，加密存储的数据需要先停用加密并改写为明文
+ALTER TABLE "user"
+    ALTER COLUMN address TYPE VARCHAR(255);
@@ -0,0 +1,3 @@
+-- 地址加密存储(secret.String)后长度会增加: 255个字符加密后约1440个字符
+ALTER TABLE "user"
+    ALTER COLUMN address TYPE VARCHAR(2048);
@@ -0,0 +1,16 @@
+-- 事务性发件箱: 写入user的同一事务中插入变更事件，由 outbox.Relay 投递给下游服务
+-- published_at 为NULL表示尚未投递成功，next_attempt_at 之前不会再次尝试
+CREATE TABLE IF NOT EXISTS outbox (
+    id              BIGSERIAL     NOT NULL,
+    topic           VARCHAR(64)   NOT NULL,
+    aggregate_id    VARCHAR(64)   NOT NULL,
+    payload         TEXT          NOT NULL,
+    created_at      TIMESTAMP(3)  NOT NULL,
+    attempts        INT           NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP(3)  NOT NULL,
+    published_at    TIMESTAMP(3)  NULL DEFAULT NULL,
+    last_error      VARCHAR(1024) NOT NULL DEFAULT '',
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_outbox_pending ON outbox (published_at, next_attempt_at);
+CREATE INDEX idx_outbox_aggregate ON outbox (aggregate_id, published_at, id);
@@ -0,0 +1,6 @@
+-- 不同租户中存在相同的用户ID时回滚会因主键冲突失败，需要先清理数据
+ALTER TABLE "user"
+    DROP CONSTRAINT user_pkey,
+    ADD PRIMARY KEY (id);
+ALTER TABLE "user"
+    DROP COLUMN tenant_id;
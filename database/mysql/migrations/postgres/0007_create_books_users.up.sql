@@ -0,0 +1,22 @@
+-- books和users表，与 entity 包中由 cmd/sqlxgen 生成的 BookSchema、UserSchema 一致
+-- 之后修改 Book 或 User 的字段时，需要在这里新增迁移，而不是修改建表语句
+CREATE TABLE IF NOT EXISTS books (
+    isdn         VARCHAR(32)  NOT NULL,
+    title        VARCHAR(128) NOT NULL,
+    author       VARCHAR(64)  NOT NULL,
+    pages        INT          NOT NULL,
+    published_at TIMESTAMP(3) NULL DEFAULT NULL,
+    PRIMARY KEY (isdn)
+);
+CREATE INDEX IF NOT EXISTS idx_books_title ON books (title);
+CREATE INDEX IF NOT EXISTS idx_books_author ON books (author);
+CREATE TABLE IF NOT EXISTS users (
+    id         BIGINT       NOT NULL,
+    username   VARCHAR(64)  NOT NULL,
+    email      VARCHAR(255) NOT NULL,
+    age        INT          NOT NULL,
+    created_at TIMESTAMP(3) NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_users_username UNIQUE (username),
+    CONSTRAINT uk_users_email UNIQUE (email)
+);
@@ -0,0 +1,9 @@
+-- 用户表，字段与 store.Person 的db标签一一对应
+-- SQLite不检查VARCHAR长度，这里保留长度便于与MySQL对照
+CREATE TABLE IF NOT EXISTS user (
+    id      VARCHAR(64)  NOT NULL,
+    name    VARCHAR(64)  NOT NULL,
+    age     INT          NOT NULL DEFAULT 0,
+    address VARCHAR(255) NOT NULL DEFAULT '',
+    PRIMARY KEY (id)
+);
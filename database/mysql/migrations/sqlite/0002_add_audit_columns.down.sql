@@ -0,0 +1,6 @@
+-- 回滚前已软删除的记录会重新变为可见
+-- SQLite 3.35起支持DROP COLUMN，有索引的列需要先删除索引
+DROP INDEX IF EXISTS idx_user_deleted_at;
+ALTER TABLE user DROP COLUMN deleted_at;
+ALTER TABLE user DROP COLUMN updated_at;
+ALTER TABLE user DROP COLUMN created_at;
@@ -0,0 +1,8 @@
+-- 审计字段和软删除标记
+-- SQLite每条ALTER TABLE只能加一列，且新增列的默认值必须是常量，已有记录的时间随后单独更新
+-- 列类型声明为DATETIME，驱动读取时会解析为time.Time
+ALTER TABLE user ADD COLUMN created_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE user ADD COLUMN updated_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE user ADD COLUMN deleted_at DATETIME NULL DEFAULT NULL;
+UPDATE user SET created_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now');
+CREATE INDEX idx_user_deleted_at ON user (deleted_at);
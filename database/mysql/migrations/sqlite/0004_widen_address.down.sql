@@ -0,0 +1 @@
+-- SQLite不检查VARCHAR长度，无需回滚
@@ -0,0 +1 @@
+-- SQLite不检查VARCHAR长度，不需要修改列，保留该版本使各数据库的迁移版本号一致
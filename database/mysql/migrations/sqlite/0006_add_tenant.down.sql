@@ -0,0 +1,17 @@
+-- 不同租户中存在相同的用户ID时回滚会因主键冲突失败，需要先清理数据
+CREATE TABLE user_old (
+    id         VARCHAR(64)  NOT NULL,
+    name       VARCHAR(64)  NOT NULL,
+    age        INT          NOT NULL DEFAULT 0,
+    address    VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME     NOT NULL DEFAULT '1970-01-01 00:00:00',
+    updated_at DATETIME     NOT NULL DEFAULT '1970-01-01 00:00:00',
+    deleted_at DATETIME     NULL DEFAULT NULL,
+    version    BIGINT       NOT NULL DEFAULT 1,
+    PRIMARY KEY (id)
+);
+INSERT INTO user_old (id, name, age, address, created_at, updated_at, deleted_at, version)
+    SELECT id, name, age, address, created_at, updated_at, deleted_at, version FROM user;
+DROP TABLE user;
+ALTER TABLE user_old RENAME TO user;
+CREATE INDEX idx_user_deleted_at ON user (deleted_at);
@@ -0,0 +1,20 @@
+-- 多租户: 多个租户的数据保存在同一张表中，启用租户隔离(store.WithTenantScope)后所有读写都带 tenant_id 条件
+-- 主键改为(tenant_id, id)，不同租户可以使用相同的用户ID，已有数据属于默认租户('')
+-- SQLite不能修改主键，需要新建表、复制数据后替换原表
+CREATE TABLE user_new (
+    tenant_id  VARCHAR(32)  NOT NULL DEFAULT '',
+    id         VARCHAR(64)  NOT NULL,
+    name       VARCHAR(64)  NOT NULL,
+    age        INT          NOT NULL DEFAULT 0,
+    address    VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME     NOT NULL DEFAULT '1970-01-01 00:00:00',
+    updated_at DATETIME     NOT NULL DEFAULT '1970-01-01 00:00:00',
+    deleted_at DATETIME     NULL DEFAULT NULL,
+    version    BIGINT       NOT NULL DEFAULT 1,
+    PRIMARY KEY (tenant_id, id)
+);
+INSERT INTO user_new (id, name, age, address, created_at, updated_at, deleted_at, version)
+    SELECT id, name, age, address, created_at, updated_at, deleted_at, version FROM user;
+DROP TABLE user;
+ALTER TABLE user_new RENAME TO user;
+CREATE INDEX idx_user_deleted_at ON user (deleted_at);
@@ -0,0 +1 @@
+-- 没有结构变更
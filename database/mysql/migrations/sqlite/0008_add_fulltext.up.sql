@@ -0,0 +1,2 @@
+-- MySQL在此版本创建FULLTEXT索引，这里没有结构变更，只保持版本号一致
+-- 全文检索由 store.NewSearchablePersonRepository 的进程内倒排索引提供
@@ -0,0 +1,92 @@
+// Package outbox 实现事务性发件箱(transactional outbox)
+// 业务数据和变更事件在同一个事务中写入，事务提交后由 Relay 读取outbox表并投递给 Sink，
+// 投递成功前会一直重试，保证至少投递一次(at-least-once)；下游需要按事件ID去重
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"Gocommunity/database/mysql/secret"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ============================= 1. 事件 ====================
+// Event 一条变更事件
+type Event struct {
+	ID        int64           `json:"id"`         // 自增ID，同一Key的事件按ID顺序投递，可用于去重
+	Topic     string          `json:"topic"`      // 事件类型，例如 person.created
+	Key       string          `json:"key"`        // 聚合ID，例如用户ID
+	Payload   json.RawMessage `json:"payload"`    // 事件内容(JSON)
+	CreatedAt time.Time       `json:"created_at"` // 写入时间
+	Attempts  int             `json:"attempts"`   // 此前投递失败的次数
+}
+
+// NewEvent 将payload序列化为JSON后创建事件
+func NewEvent(topic, key string, payload any) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("序列化事件 %s 失败: %w", topic, err)
+	}
+	return Event{Topic: topic, Key: key, Payload: data}, nil
+}
+
+// record outbox表的一行
+// payload使用 secret.String，配置了加密密钥时事件内容与业务数据一样加密存储
+type record struct {
+	ID            int64         `db:"id"`
+	Topic         string        `db:"topic"`
+	AggregateID   string        `db:"aggregate_id"`
+	Payload       secret.String `db:"payload"`
+	CreatedAt     time.Time     `db:"created_at"`
+	Attempts      int           `db:"attempts"`
+	NextAttemptAt time.Time     `db:"next_attempt_at"`
+}
+
+func (r record) event() Event {
+	return Event{
+		ID:        r.ID,
+		Topic:     r.Topic,
+		Key:       r.AggregateID,
+		Payload:   json.RawMessage(r.Payload),
+		CreatedAt: r.CreatedAt,
+		Attempts:  r.Attempts,
+	}
+}
+
+// ============================= 2. 写入事件 ====================
+// maxInsertRows 单条INSERT最多写入的事件数，每行5个占位符，远低于各数据库的占位符上限
+const maxInsertRows = 1000
+
+const insertSQL = `INSERT INTO outbox (topic, aggregate_id, payload, created_at, next_attempt_at)
+	VALUES (:topic, :aggregate_id, :payload, :created_at, :next_attempt_at)`
+
+// Insert 写入事件，db应当是写入业务数据的同一个事务，事务回滚时事件也不会被投递
+func Insert(ctx context.Context, db sqlx.ExtContext, events ...Event) error {
+	t := now()
+	records := make([]record, len(events))
+	for i, ev := range events {
+		records[i] = record{
+			Topic:         ev.Topic,
+			AggregateID:   ev.Key,
+			Payload:       secret.String(ev.Payload),
+			CreatedAt:     t,
+			NextAttemptAt: t,
+		}
+	}
+	for start := 0; start < len(records); start += maxInsertRows {
+		end := min(start+maxInsertRows, len(records))
+		if _, err := sqlx.NamedExecContext(ctx, db, insertSQL, records[start:end]); err != nil {
+			return fmt.Errorf("写入outbox事件失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// now 截断到毫秒，与 DATETIME(3) 的精度一致
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
@@ -0,0 +1,247 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"Gocommunity/database/mysql/dbx"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxErrorLength last_error列保存的错误信息最大字符数
+const maxErrorLength = 1000
+
+// ============================= 1. 投递配置 ====================
+// Relay 轮询outbox表，把未投递的事件按ID顺序交给Sink
+//
+// 投递失败时按指数退避重试，不会丢弃事件；同一Key的事件在前一条成功之前不会投递，保证单个Key内有序
+// 可以运行多个实例: 每条事件投递前先"租用"(把next_attempt_at推迟到租期结束)，避免被其他实例重复投递；
+// 但投递成功后标记失败、租期内未完成等情况仍会导致重复，下游需要按事件ID去重
+type Relay struct {
+	db   sqlx.ExtContext
+	sink Sink
+
+	batchSize      int
+	pollInterval   time.Duration
+	publishTimeout time.Duration
+	baseBackoff    time.Duration
+	maxBackoff     time.Duration
+	retention      time.Duration
+	logf           func(format string, args ...any)
+}
+
+// RelayOption Relay的可选配置
+type RelayOption func(*Relay)
+
+// WithBatchSize 每次最多读取的事件数，默认100
+func WithBatchSize(n int) RelayOption {
+	return func(r *Relay) { r.batchSize = n }
+}
+
+// WithPollInterval 没有待投递事件时的轮询间隔，默认1秒
+func WithPollInterval(d time.Duration) RelayOption {
+	return func(r *Relay) { r.pollInterval = d }
+}
+
+// WithPublishTimeout 单个事件的投递超时，默认10秒，同时决定租期的长度
+func WithPublishTimeout(d time.Duration) RelayOption {
+	return func(r *Relay) { r.publishTimeout = d }
+}
+
+// WithBackoff 第n次失败后等待 base*2^(n-1)，不超过limit，默认1秒到5分钟
+func WithBackoff(base, limit time.Duration) RelayOption {
+	return func(r *Relay) { r.baseBackoff, r.maxBackoff = base, limit }
+}
+
+// WithRetention 已投递的事件保留的时长，超过后由Run删除，默认0表示不删除
+func WithRetention(d time.Duration) RelayOption {
+	return func(r *Relay) { r.retention = d }
+}
+
+// WithLogf 设置日志函数，默认使用 log.Printf
+func WithLogf(logf func(format string, args ...any)) RelayOption {
+	return func(r *Relay) { r.logf = logf }
+}
+
+// NewRelay 创建投递器，db为*dbx.Cluster时读写都在主库进行
+func NewRelay(db sqlx.ExtContext, sink Sink, opts ...RelayOption) *Relay {
+	r := &Relay{
+		db:             db,
+		sink:           sink,
+		batchSize:      100,
+		pollInterval:   time.Second,
+		publishTimeout: 10 * time.Second,
+		baseBackoff:    time.Second,
+		maxBackoff:     5 * time.Minute,
+		logf:           log.Printf,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// ============================= 2. 投递循环 ====================
+// Run 持续投递直到ctx取消，单次失败只打印日志
+func (r *Relay) Run(ctx context.Context) error {
+	var lastCleanup time.Time
+	for {
+		n, err := r.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logf("outbox: 投递失败: %v", err)
+		}
+
+		if r.retention > 0 && time.Since(lastCleanup) > time.Minute {
+			lastCleanup = time.Now()
+			if deleted, err := r.DeletePublished(ctx, time.Now().Add(-r.retention)); err != nil && ctx.Err() == nil {
+				r.logf("outbox: 清理已投递事件失败: %v", err)
+			} else if deleted > 0 {
+				r.logf("outbox: 清理已投递事件%d条", deleted)
+			}
+		}
+
+		// 读满一批说明还有积压，立即继续
+		if err == nil && n == r.batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// RunOnce 读取一批到期的事件并逐条投递，返回读取的事件数
+// 单条事件投递失败不会返回错误，而是安排下次重试；返回的错误表示读写outbox表失败
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	// 事件刚刚在主库写入，从库可能还没有
+	ctx = dbx.WithPrimary(ctx)
+	t := now()
+
+	// 同一Key中有更早的事件在等待重试(或被其他实例租用)时，后面的事件也不能投递
+	var records []record
+	err := sqlx.SelectContext(ctx, r.db, &records, r.db.Rebind(`
+		SELECT id, topic, aggregate_id, payload, created_at, attempts, next_attempt_at FROM outbox o
+		WHERE published_at IS NULL AND next_attempt_at <= ?
+		AND NOT EXISTS (
+			SELECT 1 FROM outbox p
+			WHERE p.aggregate_id = o.aggregate_id AND p.published_at IS NULL AND p.id < o.id AND p.next_attempt_at > ?
+		)
+		ORDER BY id LIMIT ?`), t, t, r.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("读取outbox事件失败: %w", err)
+	}
+
+	// blocked 本批中投递失败的Key，之后同一Key的事件留到下次
+	blocked := make(map[string]bool)
+	for _, rec := range records {
+		if blocked[rec.AggregateID] {
+			continue
+		}
+		claimed, err := r.claim(ctx, rec.ID)
+		if err != nil {
+			return len(records), err
+		}
+		if !claimed {
+			blocked[rec.AggregateID] = true
+			continue
+		}
+
+		if err := r.publish(ctx, rec.event()); err != nil {
+			blocked[rec.AggregateID] = true
+			if err := r.markFailed(ctx, rec, err); err != nil {
+				return len(records), err
+			}
+			continue
+		}
+		if err := r.markPublished(ctx, rec.ID); err != nil {
+			return len(records), err
+		}
+	}
+	return len(records), nil
+}
+
+// claim 租用事件: 把next_attempt_at推迟到租期结束，已被其他实例租用时返回false
+// 租期比投递超时多一些，进程在投递中途退出时，租期结束后事件会被重新投递
+func (r *Relay) claim(ctx context.Context, id int64) (bool, error) {
+	t := now()
+	lease := t.Add(r.publishTimeout + 5*time.Second)
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(
+		"UPDATE outbox SET next_attempt_at = ? WHERE id = ? AND published_at IS NULL AND next_attempt_at <= ?"), lease, id, t)
+	if err != nil {
+		return false, fmt.Errorf("租用outbox事件%d失败: %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("租用outbox事件%d失败: %w", id, err)
+	}
+	return n == 1, nil
+}
+
+// publish 投递单个事件，Sink的panic按失败处理
+func (r *Relay) publish(ctx context.Context, ev Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("sink panic: %v", p)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, r.publishTimeout)
+	defer cancel()
+	return r.sink.Publish(ctx, ev)
+}
+
+func (r *Relay) markPublished(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(
+		"UPDATE outbox SET published_at = ?, attempts = attempts + 1, last_error = '' WHERE id = ?"), now(), id)
+	if err != nil {
+		// 事件已经投递，下次会再投递一次，这正是至少一次语义允许的
+		return fmt.Errorf("标记outbox事件%d已投递失败: %w", id, err)
+	}
+	return nil
+}
+
+func (r *Relay) markFailed(ctx context.Context, rec record, cause error) error {
+	attempts := rec.Attempts + 1
+	next := now().Add(r.backoff(attempts))
+	msg := []rune(cause.Error())
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	r.logf("outbox: 事件%d(%s %s)第%d次投递失败，%s后重试: %v",
+		rec.ID, rec.Topic, rec.AggregateID, attempts, time.Until(next).Round(time.Millisecond), cause)
+
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(
+		"UPDATE outbox SET attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?"), attempts, next, string(msg), rec.ID)
+	if err != nil {
+		return fmt.Errorf("记录outbox事件%d投递失败: %w", rec.ID, err)
+	}
+	return nil
+}
+
+// backoff 第n次失败后的等待时间，加入随机抖动避免大量事件同时重试
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.baseBackoff
+	for i := 1; i < attempts && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, r.maxBackoff)
+	if d > 0 {
+		d = d/2 + rand.N(d/2+1)
+	}
+	return d
+}
+
+// DeletePublished 删除在before之前已投递的事件，返回删除的条数
+func (r *Relay) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(
+		"DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < ?"), before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("清理outbox事件失败: %w", err)
+	}
+	return res.RowsAffected()
+}
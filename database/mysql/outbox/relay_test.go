@@ -0,0 +1,252 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"Gocommunity/database/mysql/dbtest"
+	"Gocommunity/database/mysql/outbox"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// recorder 记录投递到的事件，fail返回非nil时该次投递失败
+type recorder struct {
+	mu     sync.Mutex
+	events []outbox.Event
+	fail   func(ev outbox.Event) error
+}
+
+func (r *recorder) Publish(ctx context.Context, ev outbox.Event) error {
+	if r.fail != nil {
+		if err := r.fail(ev); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+	return nil
+}
+
+// delivered 按投递顺序返回 key:序号
+func (r *recorder) delivered() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]string, len(r.events))
+	for i, ev := range r.events {
+		out[i] = ev.Key + ":" + string(ev.Payload)
+	}
+	return out
+}
+
+// insert 按顺序写入事件，key:n 的payload为n
+func insert(t *testing.T, db *sqlx.DB, keys ...string) {
+	t.Helper()
+	events := make([]outbox.Event, len(keys))
+	seq := make(map[string]int)
+	for i, key := range keys {
+		seq[key]++
+		ev, err := outbox.NewEvent("person.updated", key, seq[key])
+		if err != nil {
+			t.Fatal(err)
+		}
+		events[i] = ev
+	}
+	if err := outbox.Insert(t.Context(), db, events...); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+}
+
+// pending 未投递的事件数
+func pending(t *testing.T, db *sqlx.DB) int {
+	t.Helper()
+	var n int
+	if err := db.GetContext(t.Context(), &n, "SELECT COUNT(*) FROM outbox WHERE published_at IS NULL"); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func quiet(string, ...any) {}
+
+// TestRelayOrder 按ID顺序投递，批大小小于积压时分多次读取，已投递的不再投递
+func TestRelayOrder(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	insert(t, db, "a", "b", "a", "c", "b")
+
+	sink := &recorder{}
+	relay := outbox.NewRelay(db, sink, outbox.WithBatchSize(2), outbox.WithLogf(quiet))
+	for _, want := range []int{2, 2, 1, 0} {
+		if n, err := relay.RunOnce(t.Context()); err != nil || n != want {
+			t.Fatalf("RunOnce = %d, %v, want %d", n, err, want)
+		}
+	}
+
+	if got, want := sink.delivered(), []string{"a:1", "b:1", "a:2", "c:1", "b:2"}; !slices.Equal(got, want) {
+		t.Errorf("投递顺序 %v, want %v", got, want)
+	}
+	if n := pending(t, db); n != 0 {
+		t.Errorf("还有%d条未投递", n)
+	}
+}
+
+// TestRelayRetry 投递失败的事件退避后重试，重试前同一Key后面的事件不投递，其它Key不受影响
+func TestRelayRetry(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	ctx := t.Context()
+	insert(t, db, "a", "a", "b")
+
+	errDown := errors.New("下游不可用")
+	failures := 0
+	sink := &recorder{fail: func(ev outbox.Event) error {
+		if ev.Key == "a" && failures == 0 {
+			failures++
+			return errDown
+		}
+		return nil
+	}}
+	relay := outbox.NewRelay(db, sink, outbox.WithBackoff(time.Hour, time.Hour), outbox.WithLogf(quiet))
+
+	if _, err := relay.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if got := sink.delivered(); !slices.Equal(got, []string{"b:1"}) {
+		t.Fatalf("第一次投递 %v, want [b:1]", got)
+	}
+	var failed struct {
+		Attempts  int    `db:"attempts"`
+		LastError string `db:"last_error"`
+	}
+	if err := db.GetContext(ctx, &failed, "SELECT attempts, last_error FROM outbox WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if failed.Attempts != 1 || failed.LastError != errDown.Error() {
+		t.Errorf("失败的事件 = %+v", failed)
+	}
+
+	// 退避期间a的两条事件都不投递
+	if n, err := relay.RunOnce(ctx); err != nil || n != 0 {
+		t.Fatalf("退避期间 RunOnce = %d, %v, want 0", n, err)
+	}
+
+	// 退避结束后按顺序投递，Attempts是此前失败的次数
+	if _, err := db.ExecContext(ctx, "UPDATE outbox SET next_attempt_at = created_at WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := relay.RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if got, want := sink.delivered(), []string{"b:1", "a:1", "a:2"}; !slices.Equal(got, want) {
+		t.Errorf("投递顺序 %v, want %v", got, want)
+	}
+	if ev := sink.events[1]; ev.Attempts != 1 {
+		t.Errorf("重试的事件 Attempts = %d, want 1", ev.Attempts)
+	}
+}
+
+// TestRelayPanic Sink的panic按投递失败处理
+func TestRelayPanic(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	insert(t, db, "a")
+
+	sink := &recorder{fail: func(outbox.Event) error { panic("boom") }}
+	relay := outbox.NewRelay(db, sink, outbox.WithLogf(quiet))
+	if _, err := relay.RunOnce(t.Context()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if n := pending(t, db); n != 1 {
+		t.Errorf("panic后未投递的事件 %d 条, want 1", n)
+	}
+}
+
+// TestRelayClaim 另一个实例在本实例读取之后、租用之前投递了事件，本实例租用失败，不再投递
+func TestRelayClaim(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	ctx := t.Context()
+	insert(t, db, "a", "b", "c")
+
+	other := &recorder{}
+	otherRelay := outbox.NewRelay(db, other, outbox.WithLogf(quiet))
+	first := &recorder{}
+	first.fail = func(ev outbox.Event) error {
+		// 第一个实例投递第一条事件时，第二个实例运行一轮
+		if ev.Key == "a" {
+			if _, err := otherRelay.RunOnce(ctx); err != nil {
+				t.Errorf("other RunOnce: %v", err)
+			}
+		}
+		return nil
+	}
+	relay := outbox.NewRelay(db, first, outbox.WithLogf(quiet))
+
+	if n, err := relay.RunOnce(ctx); err != nil || n != 3 {
+		t.Fatalf("RunOnce = %d, %v, want 3", n, err)
+	}
+	// a已被第一个实例租用，第二个实例只能投递b和c
+	if got, want := first.delivered(), []string{"a:1"}; !slices.Equal(got, want) {
+		t.Errorf("第一个实例投递 %v, want %v", got, want)
+	}
+	if got, want := other.delivered(), []string{"b:1", "c:1"}; !slices.Equal(got, want) {
+		t.Errorf("第二个实例投递 %v, want %v", got, want)
+	}
+}
+
+// TestRelayConcurrent 两个实例同时运行，每个事件只投递一次，同一Key内按顺序
+func TestRelayConcurrent(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	const keys, perKey = 5, 20
+	var all []string
+	for i := range perKey * keys {
+		all = append(all, fmt.Sprintf("k%d", i%keys))
+	}
+	insert(t, db, all...)
+
+	// 两个实例共用一个recorder，events即全局的投递顺序
+	sink := &recorder{}
+	ctx, cancel := context.WithTimeout(t.Context(), 30*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for range 2 {
+		relay := outbox.NewRelay(db, sink, outbox.WithBatchSize(7), outbox.WithLogf(quiet))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				n, err := relay.RunOnce(ctx)
+				if err == nil && n == 0 {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int64]int)
+	last := make(map[string]int64)
+	for _, ev := range sink.events {
+		seen[ev.ID]++
+		if ev.ID < last[ev.Key] {
+			t.Errorf("%s 的事件%d在%d之后投递", ev.Key, ev.ID, last[ev.Key])
+		}
+		last[ev.Key] = ev.ID
+	}
+	if len(seen) != len(all) || pending(t, db) != 0 {
+		t.Errorf("投递了%d个事件, want %d", len(seen), len(all))
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("事件%d投递了%d次", id, n)
+		}
+	}
+}
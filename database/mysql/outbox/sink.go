@@ -0,0 +1,161 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ============================= 1. 投递目标 ====================
+// Sink 事件的投递目标，返回nil表示投递成功，返回错误时事件会在退避后重新投递
+// 同一事件可能被投递多次，实现需要是幂等的或由下游按 Event.ID 去重
+type Sink interface {
+	Publish(ctx context.Context, ev Event) error
+}
+
+// SinkFunc 把函数转换为Sink
+type SinkFunc func(ctx context.Context, ev Event) error
+
+func (f SinkFunc) Publish(ctx context.Context, ev Event) error {
+	return f(ctx, ev)
+}
+
+// LogSink 把事件打印到日志，用于本地开发和排查问题
+func LogSink(logf func(format string, args ...any)) Sink {
+	return SinkFunc(func(ctx context.Context, ev Event) error {
+		logf("outbox: 事件%d %s key=%s payload=%s", ev.ID, ev.Topic, ev.Key, ev.Payload)
+		return nil
+	})
+}
+
+// ============================= 2. HTTP Webhook ====================
+// Webhook 以JSON格式POST事件，2xx表示成功，其它状态码和网络错误都会重试
+//
+// 请求头:
+//
+//	X-Event-ID     事件ID，下游据此去重
+//	X-Event-Topic  事件类型
+//	X-Signature    配置了Secret时为 sha256=<hex(HMAC-SHA256(Secret, 请求体))>
+type Webhook struct {
+	URL    string
+	Secret []byte
+	Header http.Header  // 额外的请求头，例如Authorization
+	Client *http.Client // 为空时使用 http.DefaultClient，超时由Relay的投递超时控制
+}
+
+// maxResponseSnippet 错误信息中最多包含的响应体字节数
+const maxResponseSnippet = 256
+
+func (w *Webhook) Publish(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("序列化事件失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range w.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatInt(ev.ID, 10))
+	req.Header.Set("X-Event-Topic", ev.Topic)
+	if len(w.Secret) > 0 {
+		mac := hmac.New(sha256.New, w.Secret)
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSnippet))
+	io.Copy(io.Discard, resp.Body) // 读完响应体以便复用连接
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook返回 %s: %s", resp.Status, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// ============================= 3. 进程内事件总线 ====================
+// Handler 事件处理函数
+type Handler func(ctx context.Context, ev Event) error
+
+// Bus 进程内的发布订阅，用于同一进程中的模块之间传递变更事件，以及在测试中观察事件
+// Publish 同步调用所有匹配的处理函数，任一处理函数失败时整个事件会被重新投递，
+// 已经成功的处理函数也会再次收到该事件
+type Bus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]subscription
+}
+
+type subscription struct {
+	topic   string
+	handler Handler
+}
+
+// NewBus 创建事件总线
+func NewBus() *Bus {
+	return &Bus{subs: make(map[int]subscription)}
+}
+
+// Subscribe 订阅事件，返回取消订阅的函数
+// topic为空表示所有事件，以 .* 结尾表示前缀匹配，例如 person.* 匹配 person.created
+func (b *Bus) Subscribe(topic string, h Handler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = subscription{topic: topic, handler: h}
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+// Publish 实现Sink，返回所有处理函数的错误
+func (b *Bus) Publish(ctx context.Context, ev Event) error {
+	b.mu.RLock()
+	var handlers []Handler
+	for _, s := range b.subs {
+		if matchTopic(s.topic, ev.Topic) {
+			handlers = append(handlers, s.handler)
+		}
+	}
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, h := range handlers {
+		if err := h(ctx, ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func matchTopic(pattern, topic string) bool {
+	if pattern == "" || pattern == topic {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(pattern, "*")
+	return ok && strings.HasPrefix(topic, prefix)
+}
@@ -0,0 +1,151 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================= 查询构造器 ====================
+// Builder 逐步添加条件、排序和分页，最后调用ToSQL生成语句
+// 方法修改并返回同一个Builder，不能在多个goroutine中共享
+// 用法错误(未知列、负数Limit)会被记录下来，在ToSQL时返回
+type Builder struct {
+	table   *Table
+	columns []string
+	where   []Cond
+	orders  []string
+	limit   int
+	offset  int
+	err     error
+
+	scope  any
+	scoped bool
+}
+
+func (b *Builder) checkColumn(column string) {
+	if !b.table.known[column] && b.err == nil {
+		b.err = fmt.Errorf("%w: %q", ErrUnknownColumn, column)
+	}
+}
+
+// Where 添加条件，与已有条件之间是AND关系
+func (b *Builder) Where(conds ...Cond) *Builder {
+	b.where = append(b.where, compact(conds)...)
+	return b
+}
+
+// And 同 Where
+func (b *Builder) And(conds ...Cond) *Builder {
+	return b.Where(conds...)
+}
+
+// Or 已有条件整体与conds(AND连接)之间是OR关系: (已有条件) OR (conds)
+func (b *Builder) Or(conds ...Cond) *Builder {
+	conds = compact(conds)
+	if len(conds) == 0 {
+		return b
+	}
+	if len(b.where) == 0 {
+		b.where = conds
+		return b
+	}
+	b.where = []Cond{Or(And(b.where...), And(conds...))}
+	return b
+}
+
+// Scope 设置范围列的值，多次调用时以最后一次为准
+// 表没有范围列时ToSQL返回ErrInvalid，表有范围列而没有调用Scope时返回ErrUnscoped
+func (b *Builder) Scope(value any) *Builder {
+	b.scope, b.scoped = value, true
+	return b
+}
+
+// OrderBy 按列升序，多次调用时按调用顺序排序
+func (b *Builder) OrderBy(column string) *Builder {
+	b.checkColumn(column)
+	b.orders = append(b.orders, column+" ASC")
+	return b
+}
+
+// OrderByDesc 按列降序
+func (b *Builder) OrderByDesc(column string) *Builder {
+	b.checkColumn(column)
+	b.orders = append(b.orders, column+" DESC")
+	return b
+}
+
+// Limit 最多返回n行
+func (b *Builder) Limit(n int) *Builder {
+	if n < 0 && b.err == nil {
+		b.err = fmt.Errorf("%w: limit不能为负数", ErrInvalid)
+	}
+	b.limit = n
+	return b
+}
+
+// Offset 跳过前n行
+func (b *Builder) Offset(n int) *Builder {
+	if n < 0 && b.err == nil {
+		b.err = fmt.Errorf("%w: offset不能为负数", ErrInvalid)
+	}
+	b.offset = n
+	return b
+}
+
+// ToSQL 生成SELECT语句和参数
+func (b *Builder) ToSQL() (string, []any, error) {
+	w := b.writer("SELECT " + strings.Join(b.columns, ", "))
+	if len(b.orders) > 0 {
+		w.sql.WriteString(" ORDER BY " + strings.Join(b.orders, ", "))
+	}
+	if b.limit >= 0 {
+		w.sql.WriteString(" LIMIT ")
+		w.arg(b.limit)
+	}
+	if b.offset > 0 {
+		// MySQL不支持只有OFFSET没有LIMIT
+		if b.limit < 0 {
+			return "", nil, fmt.Errorf("%w: 使用offset时必须指定limit", ErrInvalid)
+		}
+		w.sql.WriteString(" OFFSET ")
+		w.arg(b.offset)
+	}
+	return w.result()
+}
+
+// CountSQL 生成统计满足条件的行数的语句，忽略选择的列、排序和分页
+func (b *Builder) CountSQL() (string, []any, error) {
+	return b.writer("SELECT COUNT(*)").result()
+}
+
+func (b *Builder) writer(head string) *writer {
+	w := &writer{table: b.table, err: b.err}
+	w.sql.WriteString(head + " FROM " + b.table.name)
+	scoped := b.table.scope != ""
+	switch {
+	case scoped && !b.scoped:
+		w.err = fmt.Errorf("%w: %s必须指定%s", ErrUnscoped, b.table.name, b.table.scope)
+	case !scoped && b.scoped:
+		w.err = fmt.Errorf("%w: %s没有范围列", ErrInvalid, b.table.name)
+	case scoped:
+		// 范围条件与其余条件是AND关系，Or添加的条件已经整体加了括号
+		w.sql.WriteString(" WHERE " + b.table.scope + " = ")
+		w.arg(b.scope)
+	}
+	for i, c := range b.where {
+		if i == 0 && !scoped {
+			w.sql.WriteString(" WHERE ")
+		} else {
+			w.sql.WriteString(" AND ")
+		}
+		c.writeTo(w)
+	}
+	return w
+}
+
+func (w *writer) result() (string, []any, error) {
+	if w.err != nil {
+		return "", nil, w.err
+	}
+	return w.sql.String(), w.args, nil
+}
@@ -0,0 +1,180 @@
+package query_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/query"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type person struct {
+	ID        string    `db:"id"`
+	Name      string    `db:"name"`
+	Age       int       `db:"age"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+var (
+	persons = query.NewTable("user", person{})
+	scoped  = persons.WithScope("tenant_id")
+)
+
+func TestToSQL(t *testing.T) {
+	cases := []struct {
+		name string
+		q    *query.Builder
+		sql  string
+		args []any
+		err  error
+	}{
+		{
+			name: "所有列",
+			q:    persons.Select(),
+			sql:  "SELECT id, name, age, created_at FROM user",
+		},
+		{
+			name: "条件排序分页",
+			q: persons.Select("id", "name").
+				Where(query.Gte("age", 18), nil, query.IsNull("created_at")).
+				OrderByDesc("age").OrderBy("id").Limit(20).Offset(40),
+			sql:  "SELECT id, name FROM user WHERE age >= ? AND created_at IS NULL ORDER BY age DESC, id ASC LIMIT ? OFFSET ?",
+			args: []any{18, 20, 40},
+		},
+		{
+			name: "Or包住已有条件",
+			q:    persons.Select("id").Where(query.Eq("name", "张三"), query.Lt("age", 30)).Or(query.In("id", "1", "2")),
+			sql:  "SELECT id FROM user WHERE ((name = ? AND age < ?) OR id IN (?, ?))",
+			args: []any{"张三", 30, "1", "2"},
+		},
+		{
+			name: "In空列表不匹配任何行",
+			q:    persons.Select("id").Where(query.In[string]("id")),
+			sql:  "SELECT id FROM user WHERE id IN (NULL)",
+		},
+		{
+			name: "Not和空的组合条件",
+			q:    persons.Select("id").Where(query.Not(query.Or()), query.And()),
+			sql:  "SELECT id FROM user WHERE NOT (1 = 0) AND 1 = 1",
+		},
+		{
+			name: "Contains转义通配符",
+			q:    persons.WithName("t_user").Select("id").Where(query.Contains("name", `50%_\`)),
+			sql:  "SELECT id FROM t_user WHERE name LIKE ?",
+			args: []any{`%50\%\_\\%`},
+		},
+		{
+			name: "LIKE转义声明",
+			q:    query.NewTable("user", person{}, query.WithLikeEscape(` ESCAPE '\'`)).Select("id").Where(query.Prefix("name", "张")),
+			sql:  `SELECT id FROM user WHERE name LIKE ? ESCAPE '\'`,
+			args: []any{"张%"},
+		},
+		{
+			name: "范围条件在最前",
+			q:    scoped.Select("id").Where(query.Eq("name", "张三")).Or(query.Gt("age", 60)).Scope("t1"),
+			sql:  "SELECT id FROM user WHERE tenant_id = ? AND (name = ? OR age > ?)",
+			args: []any{"t1", "张三", 60},
+		},
+		{
+			name: "未调用Scope",
+			q:    scoped.Select("id").Where(query.Eq("id", "1")),
+			err:  query.ErrUnscoped,
+		},
+		{
+			name: "没有范围列的表调用Scope",
+			q:    persons.Select("id").Scope("t1"),
+			err:  query.ErrInvalid,
+		},
+		{
+			name: "offset没有limit",
+			q:    persons.Select("id").Offset(10),
+			err:  query.ErrInvalid,
+		},
+		{
+			name: "负数limit",
+			q:    persons.Select("id").Limit(-1),
+			err:  query.ErrInvalid,
+		},
+		{
+			name: "负数offset",
+			q:    persons.Select("id").Limit(10).Offset(-1),
+			err:  query.ErrInvalid,
+		},
+		{
+			name: "未知的选择列",
+			q:    persons.Select("password"),
+			err:  query.ErrUnknownColumn,
+		},
+		{
+			name: "未知的条件列",
+			q:    persons.Select("id").Where(query.Or(query.Eq("id", "1"), query.Eq("tenant_id", "t2"))),
+			err:  query.ErrUnknownColumn,
+		},
+		{
+			name: "未知的排序列",
+			q:    persons.Select("id").OrderBy("id; DROP TABLE user"),
+			err:  query.ErrUnknownColumn,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlStr, args, err := tc.q.ToSQL()
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("err = %v, want %v", err, tc.err)
+				}
+				if sqlStr != "" || args != nil {
+					t.Errorf("出错时返回了 %q %v", sqlStr, args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ToSQL: %v", err)
+			}
+			if sqlStr != tc.sql {
+				t.Errorf("sql = %q\nwant  %q", sqlStr, tc.sql)
+			}
+			if !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("args = %#v, want %#v", args, tc.args)
+			}
+		})
+	}
+}
+
+func TestCountSQL(t *testing.T) {
+	sqlStr, args, err := scoped.Select("id").Where(query.Prefix("name", "张")).OrderBy("age").Limit(10).Scope("t1").CountSQL()
+	if err != nil {
+		t.Fatalf("CountSQL: %v", err)
+	}
+	if want := "SELECT COUNT(*) FROM user WHERE tenant_id = ? AND name LIKE ?"; sqlStr != want {
+		t.Errorf("sql = %q, want %q", sqlStr, want)
+	}
+	if want := []any{"t1", "张%"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+
+	if _, _, err := scoped.Select().CountSQL(); !errors.Is(err, query.ErrUnscoped) {
+		t.Errorf("未调用Scope: err = %v, want ErrUnscoped", err)
+	}
+}
+
+// TestRebind 生成的?占位符按驱动转换，参数顺序不变
+func TestRebind(t *testing.T) {
+	sqlStr, _, err := scoped.Select("id").Where(query.In("age", 1, 2)).Limit(5).Scope("t1").ToSQL()
+	if err != nil {
+		t.Fatalf("ToSQL: %v", err)
+	}
+	for driver, want := range map[string]string{
+		dbx.DriverMySQL:    "SELECT id FROM user WHERE tenant_id = ? AND age IN (?, ?) LIMIT ?",
+		dbx.DriverSQLite:   "SELECT id FROM user WHERE tenant_id = ? AND age IN (?, ?) LIMIT ?",
+		dbx.DriverPostgres: "SELECT id FROM user WHERE tenant_id = $1 AND age IN ($2, $3) LIMIT $4",
+	} {
+		if got := sqlx.Rebind(sqlx.BindType(driver), sqlStr); got != want {
+			t.Errorf("%s: %q, want %q", driver, got, want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================= 1. 条件 ====================
+// Cond 一个WHERE条件，使用下面的函数创建，可以用And、Or、Not组合
+type Cond interface {
+	writeTo(w *writer)
+}
+
+// writer 拼接SQL并收集参数，记录遇到的第一个错误
+type writer struct {
+	table *Table
+	sql   strings.Builder
+	args  []any
+	err   error
+}
+
+func (w *writer) column(name string) {
+	if !w.table.known[name] && w.err == nil {
+		w.err = fmt.Errorf("%w: %q", ErrUnknownColumn, name)
+	}
+	w.sql.WriteString(name)
+}
+
+func (w *writer) arg(v any) {
+	w.sql.WriteString("?")
+	w.args = append(w.args, v)
+}
+
+// compare 列与值比较
+type compare struct {
+	column string
+	op     string
+	value  any
+}
+
+func (c compare) writeTo(w *writer) {
+	w.column(c.column)
+	w.sql.WriteString(" " + c.op + " ")
+	w.arg(c.value)
+}
+
+func Eq(column string, value any) Cond  { return compare{column, "=", value} }
+func Ne(column string, value any) Cond  { return compare{column, "<>", value} }
+func Gt(column string, value any) Cond  { return compare{column, ">", value} }
+func Gte(column string, value any) Cond { return compare{column, ">=", value} }
+func Lt(column string, value any) Cond  { return compare{column, "<", value} }
+func Lte(column string, value any) Cond { return compare{column, "<=", value} }
+
+// like LIKE匹配，转义声明来自表的配置
+type like struct {
+	column  string
+	pattern string
+}
+
+func (c like) writeTo(w *writer) {
+	w.column(c.column)
+	w.sql.WriteString(" LIKE ")
+	w.arg(c.pattern)
+	w.sql.WriteString(w.table.likeEscape)
+}
+
+// Like pattern中的 % 和 _ 是通配符，包含用户输入时使用 EscapeLike 或 Prefix/Contains
+func Like(column, pattern string) Cond { return like{column, pattern} }
+
+// Prefix 以s开头，s中的通配符按普通字符匹配
+func Prefix(column, s string) Cond { return like{column, EscapeLike(s) + "%"} }
+
+// Contains 包含s，s中的通配符按普通字符匹配；无法使用索引，数据量大时注意性能
+func Contains(column, s string) Cond { return like{column, "%" + EscapeLike(s) + "%"} }
+
+// EscapeLike 转义LIKE中的通配符，用户输入的 % 和 _ 按普通字符匹配
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// in 列的值在列表中
+type in struct {
+	column string
+	values []any
+}
+
+func (c in) writeTo(w *writer) {
+	// 空列表在SQL中是语法错误，按"不匹配任何行"处理
+	if len(c.values) == 0 {
+		w.column(c.column)
+		w.sql.WriteString(" IN (NULL)")
+		return
+	}
+	w.column(c.column)
+	w.sql.WriteString(" IN (")
+	for i, v := range c.values {
+		if i > 0 {
+			w.sql.WriteString(", ")
+		}
+		w.arg(v)
+	}
+	w.sql.WriteString(")")
+}
+
+// In 列的值在values中，values为空时不匹配任何行
+// 使用泛型可以直接传入 []string、[]int 等切片: In("id", ids...)
+func In[T any](column string, values ...T) Cond {
+	vs := make([]any, len(values))
+	for i, v := range values {
+		vs[i] = v
+	}
+	return in{column, vs}
+}
+
+// null IS NULL / IS NOT NULL
+type null struct {
+	column string
+	not    bool
+}
+
+func (c null) writeTo(w *writer) {
+	w.column(c.column)
+	if c.not {
+		w.sql.WriteString(" IS NOT NULL")
+	} else {
+		w.sql.WriteString(" IS NULL")
+	}
+}
+
+func IsNull(column string) Cond  { return null{column, false} }
+func NotNull(column string) Cond { return null{column, true} }
+
+// ============================= 2. 组合条件 ====================
+// group 用AND或OR连接多个条件，多于一个条件时加括号
+type group struct {
+	op    string
+	conds []Cond
+}
+
+func (g group) writeTo(w *writer) {
+	switch len(g.conds) {
+	case 0:
+		// 空的AND恒为真，空的OR恒为假
+		if g.op == "AND" {
+			w.sql.WriteString("1 = 1")
+		} else {
+			w.sql.WriteString("1 = 0")
+		}
+		return
+	case 1:
+		g.conds[0].writeTo(w)
+		return
+	}
+	w.sql.WriteString("(")
+	for i, c := range g.conds {
+		if i > 0 {
+			w.sql.WriteString(" " + g.op + " ")
+		}
+		c.writeTo(w)
+	}
+	w.sql.WriteString(")")
+}
+
+// And 所有条件都满足，忽略nil条件
+func And(conds ...Cond) Cond { return group{"AND", compact(conds)} }
+
+// Or 任一条件满足，忽略nil条件
+func Or(conds ...Cond) Cond { return group{"OR", compact(conds)} }
+
+type not struct{ cond Cond }
+
+func (c not) writeTo(w *writer) {
+	w.sql.WriteString("NOT (")
+	c.cond.writeTo(w)
+	w.sql.WriteString(")")
+}
+
+// Not 条件取反
+func Not(cond Cond) Cond { return not{cond} }
+
+// compact 去掉nil条件，便于按参数是否为空拼接可选条件
+func compact(conds []Cond) []Cond {
+	out := make([]Cond, 0, len(conds))
+	for _, c := range conds {
+		if c != nil {
+			out = append(out, c)
+		}
+	}
+	return out
+}
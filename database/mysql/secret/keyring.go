@@ -0,0 +1,183 @@
+// Package secret 提供数据库字段的透明加密
+// 字段类型声明为 secret.String 后，写入数据库时使用AES-GCM加密，读取时自动解密，
+// 密文中记录加密使用的密钥ID，轮换密钥后旧数据仍能用旧密钥解密，再由重新加密任务迁移到新密钥
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	ErrUnknownKey = errors.New("未知的加密密钥")
+	ErrNoKeyring  = errors.New("未配置加密密钥")
+	ErrCorrupted  = errors.New("密文损坏或密钥不匹配")
+	ErrReserved   = errors.New("明文不能以密文前缀 enc:v1: 开头")
+)
+
+// ============================= 1. 密钥环 ====================
+// Keyring 保存所有可用的密钥，新数据使用当前密钥加密，旧密钥只用于解密
+type Keyring struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring 创建密钥环，current为加密新数据使用的密钥ID
+// 密钥长度必须是16、24或32字节(AES-128/192/256)，密钥ID不超过32个字符，只能包含字母、数字、-和_
+func NewKeyring(current string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: 当前密钥 %q 不在密钥列表中", ErrUnknownKey, current)
+	}
+	k := &Keyring{current: current, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if !validKeyID(id) {
+			return nil, fmt.Errorf("密钥ID %q 不合法: 不超过%d个字符，只能包含字母、数字、-和_", id, maxKeyIDLength)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("密钥 %s: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("密钥 %s: %w", id, err)
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// ParseKeyring 解析 "id:base64密钥,id:base64密钥" 格式的密钥列表，第一个是当前密钥
+// 轮换时把新密钥放在最前面，旧密钥保留到重新加密任务完成之后
+func ParseKeyring(spec string) (*Keyring, error) {
+	var current string
+	keys := make(map[string][]byte)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("密钥格式应为 id:base64: %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("密钥 %s 不是合法的base64: %w", id, err)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("密钥ID %q 重复", id)
+		}
+		keys[id] = key
+		if current == "" {
+			current = id
+		}
+	}
+	if current == "" {
+		return nil, fmt.Errorf("%w: 密钥列表为空", ErrNoKeyring)
+	}
+	return NewKeyring(current, keys)
+}
+
+// Current 返回加密新数据使用的密钥ID
+func (k *Keyring) Current() string {
+	return k.current
+}
+
+// maxKeyIDLength 密钥ID的最大长度，决定了密文比明文多出的长度
+const maxKeyIDLength = 32
+
+func validKeyID(id string) bool {
+	if id == "" || len(id) > maxKeyIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// ============================= 2. 加密格式 ====================
+// 密文格式: enc:v1:<密钥ID>:<base64(nonce + 密文 + tag)>
+// 前缀和密钥ID作为附加数据参与认证，篡改密钥ID会导致解密失败
+const prefix = "enc:v1:"
+
+// Encrypt 使用当前密钥加密，每次使用随机nonce，相同明文的密文也不同
+func (k *Keyring) Encrypt(plaintext string) string {
+	aead := k.aeads[k.current]
+	header := prefix + k.current + ":"
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(fmt.Sprintf("secret: 读取随机数失败: %v", err))
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(header))
+	return header + base64.RawStdEncoding.EncodeToString(sealed)
+}
+
+// EncryptedLen 返回长度为n的明文加密后的长度，用于估算列宽和SQL包大小
+func (k *Keyring) EncryptedLen(n int) int {
+	aead := k.aeads[k.current]
+	return len(prefix) + len(k.current) + 1 + base64.RawStdEncoding.EncodedLen(aead.NonceSize()+n+aead.Overhead())
+}
+
+// Decrypt 按密文中的密钥ID选择密钥解密
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	id, payload, ok := parse(ciphertext)
+	if !ok {
+		return "", fmt.Errorf("%w: 格式错误", ErrCorrupted)
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return "", fmt.Errorf("%w: 密钥 %s", ErrCorrupted, id)
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(prefix+id+":"))
+	if err != nil {
+		return "", fmt.Errorf("%w: 密钥 %s", ErrCorrupted, id)
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted 判断数据库中的值是否是密文
+func IsEncrypted(stored string) bool {
+	return strings.HasPrefix(stored, prefix)
+}
+
+// KeyID 返回密文使用的密钥ID，明文返回空字符串
+func KeyID(stored string) string {
+	id, _, _ := parse(stored)
+	return id
+}
+
+func parse(stored string) (id, payload string, ok bool) {
+	rest, ok := strings.CutPrefix(stored, prefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
+
+// ============================= 3. 全局密钥环 ====================
+// sql.Scanner 和 driver.Valuer 无法传入参数，String 使用进程级的密钥环
+var global atomic.Pointer[Keyring]
+
+// SetKeyring 设置全局密钥环，nil表示关闭加密: 新数据按明文写入，读到密文时返回 ErrNoKeyring
+func SetKeyring(k *Keyring) {
+	global.Store(k)
+}
+
+// Default 返回全局密钥环，未配置时为nil
+func Default() *Keyring {
+	return global.Load()
+}
@@ -0,0 +1,68 @@
+package secret
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// ============================= 加密字段类型 ====================
+// String 在数据库中加密存储的字符串，在Go代码和JSON中与普通字符串相同
+//
+// 写入: 配置了全局密钥环时使用当前密钥加密，未配置时写入明文；空字符串不加密，保持列的默认值语义
+// 未配置密钥环时，以密文前缀开头的明文读取时会被当作密文，写入返回ErrReserved
+// 读取: 密文按其中的密钥ID解密，明文(加密上线前写入的旧数据)原样返回，由重新加密任务逐步迁移
+//
+// 密文无法在SQL中比较或使用LIKE匹配，按该字段筛选需要在应用内完成
+type String string
+
+// Value 实现 driver.Valuer
+func (s String) Value() (driver.Value, error) {
+	k := Default()
+	if k == nil && IsEncrypted(string(s)) {
+		return nil, ErrReserved
+	}
+	if k == nil || s == "" {
+		return string(s), nil
+	}
+	return k.Encrypt(string(s)), nil
+}
+
+// Scan 实现 sql.Scanner
+func (s *String) Scan(src any) error {
+	var stored string
+	switch v := src.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("secret: 无法将 %T 转换为 String", src)
+	}
+
+	if !IsEncrypted(stored) {
+		*s = String(stored)
+		return nil
+	}
+	k := Default()
+	if k == nil {
+		return fmt.Errorf("%w: 无法解密密钥 %s 加密的数据", ErrNoKeyring, KeyID(stored))
+	}
+	plaintext, err := k.Decrypt(stored)
+	if err != nil {
+		return err
+	}
+	*s = String(plaintext)
+	return nil
+}
+
+// StoredLen 返回写入数据库的值的字节数
+func (s String) StoredLen() int {
+	k := Default()
+	if k == nil || s == "" {
+		return len(s)
+	}
+	return k.EncryptedLen(len(s))
+}
@@ -0,0 +1,59 @@
+package secret_test
+
+import (
+	"errors"
+	"testing"
+
+	"Gocommunity/database/mysql/secret"
+)
+
+// 密钥环是进程级的，这个包的测试都不并行
+
+func TestStringPlaintext(t *testing.T) {
+	secret.SetKeyring(nil)
+
+	for _, plain := range []string{"", "北京市", "enc:v2:北京市"} {
+		v, err := secret.String(plain).Value()
+		if err != nil || v != plain {
+			t.Errorf("Value(%q) = %v, %v, want 明文", plain, v, err)
+		}
+		var got secret.String
+		if err := got.Scan(v); err != nil || string(got) != plain {
+			t.Errorf("Scan(%q) = %q, %v", plain, got, err)
+		}
+	}
+
+	// 以密文前缀开头的明文写入后无法读回
+	if _, err := secret.String("enc:v1:test:abc").Value(); !errors.Is(err, secret.ErrReserved) {
+		t.Errorf("Value(enc:v1:...) err = %v, want ErrReserved", err)
+	}
+}
+
+func TestStringEncrypted(t *testing.T) {
+	keyring, err := secret.NewKeyring("test", map[string][]byte{"test": make([]byte, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.SetKeyring(keyring)
+	t.Cleanup(func() { secret.SetKeyring(nil) })
+
+	// 配置密钥后任何明文都加密，包括以密文前缀开头的
+	for _, plain := range []string{"北京市", "enc:v1:test:abc"} {
+		v, err := secret.String(plain).Value()
+		if err != nil {
+			t.Fatalf("Value(%q): %v", plain, err)
+		}
+		if stored := v.(string); !secret.IsEncrypted(stored) || secret.KeyID(stored) != "test" {
+			t.Errorf("Value(%q) = %q, want 密钥test的密文", plain, stored)
+		}
+		var got secret.String
+		if err := got.Scan(v); err != nil || string(got) != plain {
+			t.Errorf("Scan = %q, %v, want %q", got, err, plain)
+		}
+	}
+
+	var got secret.String
+	if err := got.Scan("enc:v1:test:abc"); !errors.Is(err, secret.ErrCorrupted) {
+		t.Errorf("Scan(损坏的密文) err = %v, want ErrCorrupted", err)
+	}
+}
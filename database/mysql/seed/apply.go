@@ -0,0 +1,94 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"Gocommunity/database/mysql/store"
+)
+
+// ============================= 写入数据库 ====================
+// Result 本次写入中每种处理方式的记录数
+type Result struct {
+	Created   int // 不存在，新建
+	Updated   int // 存在但内容不同，更新为种子数据
+	Restored  int // 已被软删除，恢复后与种子数据一致
+	Unchanged int // 已存在且内容相同
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("新建%d 更新%d 恢复%d 未变化%d", r.Created, r.Updated, r.Restored, r.Unchanged)
+}
+
+// Apply 按id把persons写入仓储，重复执行的结果相同
+// 只比较和写入业务字段(name/age/address)，审计字段和版本号由仓储维护；
+// 记录已被软删除时先恢复(需要仓储实现 store.SoftDeleter)，否则返回 store.ErrDuplicateKey
+// 需要全部成功或全部不写入时，使用基于事务的仓储调用
+//
+// 先查询回收站再决定新建还是恢复，不用插入失败来判断记录是否存在:
+// PostgreSQL中失败的语句会使整个事务进入aborted状态，之后的语句都会失败
+func Apply(ctx context.Context, repo store.PersonRepository, persons []store.Person) (Result, error) {
+	var result Result
+	for _, want := range persons {
+		restored := false
+		current, err := repo.Get(ctx, want.UserId)
+		if errors.Is(err, store.ErrNotFound) {
+			var deleted bool
+			if deleted, err = inTrash(ctx, repo, want.UserId); err != nil {
+				return result, fmt.Errorf("写入 %s 失败: %w", want.UserId, err)
+			}
+			if !deleted {
+				p := want
+				if err := repo.Create(ctx, &p); err != nil {
+					return result, fmt.Errorf("写入 %s 失败: %w", want.UserId, err)
+				}
+				result.Created++
+				continue
+			}
+			current, err = restore(ctx, repo, want.UserId)
+			restored = true
+		}
+		if err != nil {
+			return result, fmt.Errorf("写入 %s 失败: %w", want.UserId, err)
+		}
+
+		changed := current.Username != want.Username || current.Age != want.Age || current.Address != want.Address
+		if changed {
+			current.Username, current.Age, current.Address = want.Username, want.Age, want.Address
+			if err := repo.Update(ctx, current); err != nil {
+				return result, fmt.Errorf("更新 %s 失败: %w", want.UserId, err)
+			}
+		}
+		switch {
+		case restored:
+			result.Restored++
+		case changed:
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+	}
+	return result, nil
+}
+
+// inTrash 记录是否已被软删除
+func inTrash(ctx context.Context, repo store.PersonRepository, id string) (bool, error) {
+	page, err := repo.List(ctx, store.ListOptions{ID: id, Deleted: store.OnlyDeleted, Limit: 1})
+	if err != nil {
+		return false, err
+	}
+	return page.Total > 0, nil
+}
+
+// restore 恢复已软删除的记录并返回恢复后的数据
+func restore(ctx context.Context, repo store.PersonRepository, id string) (*store.Person, error) {
+	deleter, ok := repo.(store.SoftDeleter)
+	if !ok {
+		return nil, fmt.Errorf("%w: 记录已被删除且仓储不支持恢复", store.ErrDuplicateKey)
+	}
+	if err := deleter.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+	return repo.Get(ctx, id)
+}
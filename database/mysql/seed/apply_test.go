@@ -0,0 +1,86 @@
+package seed_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Gocommunity/database/mysql/dbtest"
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/seed"
+	"Gocommunity/database/mysql/store"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// failedWrites 统计失败的Create，Apply不能依靠插入失败判断记录是否存在
+type failedWrites struct {
+	*store.SQLPersonRepository
+	creates int
+}
+
+func (r *failedWrites) Create(ctx context.Context, p *store.Person) error {
+	err := r.SQLPersonRepository.Create(ctx, p)
+	if err != nil {
+		r.creates++
+	}
+	return err
+}
+
+// TestApplyInTx 与 cmd/seed 一样在一个事务中写入: 新建、更新、恢复和未变化的记录都能正确处理
+// DBTEST_DRIVER=postgres 时，任何一条失败的语句都会使事务中之后的语句失败
+func TestApplyInTx(t *testing.T) {
+	deletedAt := time.Now().UTC().Truncate(time.Millisecond)
+	db := dbtest.New(t, dbtest.WithPersons(
+		store.Person{UserId: "same", Username: "不变", Age: 20},
+		store.Person{UserId: "changed", Username: "旧名字", Age: 20},
+		store.Person{UserId: "trashed", Username: "回收站", Age: 20, DeletedAt: &deletedAt},
+	))
+	ctx := t.Context()
+
+	persons := []store.Person{
+		{UserId: "same", Username: "不变", Age: 20},
+		{UserId: "changed", Username: "新名字", Age: 21},
+		{UserId: "trashed", Username: "回收站", Age: 22},
+		{UserId: "new", Username: "新建", Age: 23},
+	}
+	var (
+		result seed.Result
+		repo   *failedWrites
+	)
+	err := dbx.WithTx(ctx, db, dbx.DefaultTxOptions(), func(tx *sqlx.Tx) (err error) {
+		repo = &failedWrites{SQLPersonRepository: store.NewSQLPersonRepository(tx)}
+		result, err = seed.Apply(ctx, repo, persons)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if want := (seed.Result{Created: 1, Updated: 1, Restored: 1, Unchanged: 1}); result != want {
+		t.Errorf("Apply = %v, want %v", result, want)
+	}
+	if repo.creates != 0 {
+		t.Errorf("失败的Create %d 次, want 0", repo.creates)
+	}
+
+	check := store.NewSQLPersonRepository(db)
+	for _, want := range persons {
+		got, err := check.Get(ctx, want.UserId)
+		if err != nil {
+			t.Errorf("Get(%s): %v", want.UserId, err)
+			continue
+		}
+		if got.Username != want.Username || got.Age != want.Age {
+			t.Errorf("Get(%s) = %+v, want %+v", want.UserId, *got, want)
+		}
+	}
+
+	// 再次执行结果相同
+	result, err = seed.Apply(ctx, check, persons)
+	if err != nil {
+		t.Fatalf("再次Apply: %v", err)
+	}
+	if want := (seed.Result{Unchanged: 4}); result != want {
+		t.Errorf("再次Apply = %v, want %v", result, want)
+	}
+}
@@ -0,0 +1,177 @@
+// Package seed 从YAML/JSON/CSV文件加载用户数据并幂等地写入数据库
+package seed
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"Gocommunity/database/mysql/secret"
+	"Gocommunity/database/mysql/store"
+
+	"github.com/goccy/go-yaml"
+)
+
+// CommonSet 所有环境都会加载的数据集目录
+const CommonSet = "common"
+
+// ErrInvalidFixture 数据文件格式错误或内容不合法
+var ErrInvalidFixture = errors.New("种子数据不合法")
+
+// ============================= 1. 加载数据集 ====================
+// Load 依次加载 common 和 env 目录下的数据文件，返回按id去重后的记录
+// 同一目录内的文件按文件名顺序加载，同一id只能出现一次；env中的记录覆盖common中的同id记录
+// env为空时只加载common，目录不存在视为空数据集
+func Load(fsys fs.FS, env string) ([]store.Person, error) {
+	sets := []string{CommonSet}
+	if env != "" && env != CommonSet {
+		sets = append(sets, env)
+	}
+
+	var (
+		persons []store.Person
+		index   = make(map[string]int) // id -> persons中的下标
+	)
+	for _, set := range sets {
+		loaded, err := loadSet(fsys, set)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range loaded {
+			if i, ok := index[p.UserId]; ok {
+				persons[i] = p
+				continue
+			}
+			index[p.UserId] = len(persons)
+			persons = append(persons, p)
+		}
+	}
+	return persons, nil
+}
+
+// loadSet 加载一个目录，同一数据集内id重复视为错误
+func loadSet(fsys fs.FS, dir string) ([]store.Person, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取数据集 %s 失败: %w", dir, err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var (
+		persons []store.Person
+		seen    = make(map[string]string) // id -> 文件名
+	)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := path.Join(dir, entry.Name())
+		parse, ok := parsers[path.Ext(name)]
+		if !ok {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", name, err)
+		}
+		loaded, err := parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w: %w", name, ErrInvalidFixture, err)
+		}
+		for i, p := range loaded {
+			if err := p.Validate(); err != nil {
+				return nil, fmt.Errorf("%s 第%d条: %w: %w", name, i+1, ErrInvalidFixture, err)
+			}
+			if prev, ok := seen[p.UserId]; ok {
+				return nil, fmt.Errorf("%s: %w: id %q 已在 %s 中定义", name, ErrInvalidFixture, p.UserId, prev)
+			}
+			seen[p.UserId] = name
+			persons = append(persons, p)
+		}
+	}
+	return persons, nil
+}
+
+// ============================= 2. 文件格式 ====================
+// parsers 按扩展名选择解析函数，其它扩展名的文件(例如README)被忽略
+var parsers = map[string]func([]byte) ([]store.Person, error){
+	".yaml": parseYAML,
+	".yml":  parseYAML,
+	".json": parseJSON,
+	".csv":  parseCSV,
+}
+
+// parseYAML 顶层是记录列表，字段名使用Person的json标签，未知字段视为错误
+func parseYAML(data []byte) ([]store.Person, error) {
+	var persons []store.Person
+	if err := yaml.UnmarshalWithOptions(data, &persons, yaml.Strict()); err != nil {
+		return nil, err
+	}
+	return persons, nil
+}
+
+// parseJSON 顶层是记录数组，未知字段视为错误
+func parseJSON(data []byte) ([]store.Person, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var persons []store.Person
+	if err := dec.Decode(&persons); err != nil {
+		return nil, err
+	}
+	return persons, nil
+}
+
+// parseCSV 第一行是表头，列名使用Person的db标签，列的顺序任意，address可以省略
+func parseCSV(data []byte) ([]store.Person, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取表头失败: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "id", "name", "age", "address":
+		default:
+			return nil, fmt.Errorf("不支持的列 %q", name)
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"id", "name", "age"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("缺少列 %q", required)
+		}
+	}
+
+	var persons []store.Person
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return persons, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		age, err := strconv.Atoi(strings.TrimSpace(record[columns["age"]]))
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: age不是整数", line)
+		}
+		p := store.Person{UserId: record[columns["id"]], Username: record[columns["name"]], Age: age}
+		if i, ok := columns["address"]; ok {
+			p.Address = secret.String(record[i])
+		}
+		persons = append(persons, p)
+	}
+}
@@ -0,0 +1,273 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/outbox"
+	"Gocommunity/database/mysql/query"
+	"Gocommunity/database/mysql/secret"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	DefaultBatchSize = 500     // 每条INSERT语句默认包含的行数
+	DefaultMaxPacket = 4 << 20 // 默认按4MB估算单条语句大小(MySQL 5.7 max_allowed_packet的默认值)
+
+	// maxPlaceholders 单条语句的占位符上限: MySQL和PostgreSQL为65535，SQLite默认为32766，取较小值
+	maxPlaceholders = 32766
+	// personColumns 每行Person占用的占位符个数
+	personColumns = 7
+	// rowOverhead 每行除字段内容外的估算开销: 括号、逗号、数值字段等
+	rowOverhead = 64
+)
+
+// ============================= 1. 批量写入参数 ====================
+// BatchOptions 批量插入的分批和冲突处理方式
+type BatchOptions struct {
+	// BatchSize 每批最多多少行，<=0时使用DefaultBatchSize
+	BatchSize int
+	// MaxPacketBytes 单条语句的估算大小上限，应小于服务端max_allowed_packet，<=0时使用DefaultMaxPacket
+	MaxPacketBytes int
+	// Upsert 为true时主键已存在则更新其余字段
+	// MySQL使用 ON DUPLICATE KEY UPDATE，PostgreSQL和SQLite使用 ON CONFLICT DO UPDATE
+	Upsert bool
+}
+
+// BatchResult 每一批的影响行数
+// 注意upsert时MySQL的计数规则: 新插入计1，更新计2，值未变化计1(clientFoundRows=true时)
+// PostgreSQL和SQLite: 新插入和更新都计1，值未变化计0
+type BatchResult struct {
+	Affected []int64 // 第i个元素是第i批的影响行数
+	Total    int64
+}
+
+// BatchWriter 支持批量写入的仓储
+type BatchWriter interface {
+	BatchInsert(ctx context.Context, persons []Person, opts BatchOptions) (*BatchResult, error)
+}
+
+var (
+	_ BatchWriter = (*SQLPersonRepository)(nil)
+	_ BatchWriter = (*MemoryPersonRepository)(nil)
+)
+
+// ============================= 2. 分批 ====================
+// chunkPersons 同时按行数和估算的语句大小切分，保证每批都不超过max_allowed_packet
+func chunkPersons(persons []Person, opts BatchOptions) [][]Person {
+	size := opts.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	size = min(size, maxPlaceholders/personColumns)
+	maxBytes := opts.MaxPacketBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxPacket
+	}
+
+	var (
+		chunks [][]Person
+		start  int
+		bytes  int
+	)
+	for i, p := range persons {
+		rowBytes := len(p.UserId) + len(p.Username) + p.Address.StoredLen() + rowOverhead
+		if i > start && (i-start >= size || bytes+rowBytes > maxBytes) {
+			chunks = append(chunks, persons[start:i])
+			start, bytes = i, 0
+		}
+		bytes += rowBytes
+	}
+	if start < len(persons) {
+		chunks = append(chunks, persons[start:])
+	}
+	return chunks
+}
+
+// validatePersons 写入前校验所有记录，有一条不满足约束时整体不写入
+func validatePersons(persons []Person) error {
+	for i := range persons {
+		if err := persons[i].Validate(); err != nil {
+			return fmt.Errorf("批量写入失败: 第%d条记录(%q): %w", i+1, persons[i].UserId, err)
+		}
+	}
+	return nil
+}
+
+// stampPersons 为批量写入的记录设置审计字段
+func stampPersons(persons []Person) {
+	t := now()
+	for i := range persons {
+		persons[i].CreatedAt = t
+		persons[i].UpdatedAt = t
+		persons[i].DeletedAt = nil
+		persons[i].Version = 1
+	}
+}
+
+// ============================= 3. SQL 批量写入 ====================
+// batchInsertSQL 按方言生成批量插入语句，upsert时追加方言的主键冲突处理子句
+func (r *SQLPersonRepository) batchInsertSQL(upsert bool) string {
+	query := r.insertSQL()
+	if upsert {
+		query += r.dialect.upsert
+	}
+	return query
+}
+
+// BatchInsert 使用sqlx命名参数批量插入，传入切片时sqlx会展开为多行VALUES
+// persons中每条记录的审计字段会被设置为本次写入的时间，upsert时不检查版本号
+// 每批是一条独立的语句，出错时返回已完成批次的结果；需要整体原子性时请基于事务创建仓储
+func (r *SQLPersonRepository) BatchInsert(ctx context.Context, persons []Person, opts BatchOptions) (*BatchResult, error) {
+	if err := validatePersons(persons); err != nil {
+		return nil, err
+	}
+	tenantID, err := r.tenant(ctx)
+	if err != nil {
+		return nil, translateError("批量写入失败", err)
+	}
+	query := r.batchInsertSQL(opts.Upsert)
+	stampPersons(persons)
+
+	// upsert时无法区分每一行是新建还是更新，事件类型为 person.upserted
+	topic := TopicPersonCreated
+	if opts.Upsert {
+		topic = TopicPersonUpserted
+	}
+	// 地址加密存储时每次加密的密文都不同，upsert子句在SQL中比较地址总是"已变化"，
+	// 改为先读出已有的记录，在Go中比较解密后的值，跳过未变化的行
+	skipUnchanged := opts.Upsert && secret.Default() != nil
+
+	result := &BatchResult{}
+	for i, chunk := range chunkPersons(persons, opts) {
+		affected, err := r.execBatch(ctx, tenantID, query, topic, chunk, skipUnchanged)
+		if err != nil {
+			return result, translateError(fmt.Sprintf("第%d批(%d行)写入失败", i+1, len(chunk)), err)
+		}
+		result.Affected = append(result.Affected, affected)
+		result.Total += affected
+	}
+	return result, nil
+}
+
+// execBatch 执行一批，每批单独计算超时，启用outbox时每批和它的事件在一个事务中写入
+// skipUnchanged为true时不写入与已有记录相同的行，这些行也不产生事件
+func (r *SQLPersonRepository) execBatch(ctx context.Context, tenantID, query, topic string, chunk []Person, skipUnchanged bool) (int64, error) {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var affected int64
+	err := r.write(ctx, func(db sqlx.ExtContext) ([]outbox.Event, error) {
+		// fn可能因死锁被重新执行，每次都重新计算
+		rows := chunk
+		affected = 0
+		if skipUnchanged {
+			var err error
+			if rows, err = r.changedRows(ctx, db, chunk); err != nil {
+				return nil, err
+			}
+			// 与SQL中比较的计数规则一致: MySQL未变化的行计1，PostgreSQL和SQLite计0
+			if r.dialect.Name == dbx.DriverMySQL {
+				affected = int64(len(chunk) - len(rows))
+			}
+			if len(rows) == 0 {
+				return nil, nil
+			}
+		}
+		res, err := sqlx.NamedExecContext(ctx, db, query, r.rows(tenantID, rows))
+		if err != nil {
+			return nil, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		affected += n
+		return r.events(tenantID, topic, rows...)
+	})
+	return affected, err
+}
+
+// changedRows 返回chunk中需要写入的行: 新记录，以及业务字段与已有记录不同的行
+// 在写入的同一个连接或事务中读取已有记录，比较的是解密后的地址
+// db可能是读写分离的 dbx.Cluster，必须读主库: 从库的旧数据会让变化的行被当作未变化而丢失写入
+func (r *SQLPersonRepository) changedRows(ctx context.Context, db sqlx.ExtContext, chunk []Person) ([]Person, error) {
+	ids := make([]string, len(chunk))
+	for i := range chunk {
+		ids[i] = chunk[i].UserId
+	}
+	q := r.Query("id", "name", "age", "address").Where(query.In("id", ids...))
+	if err := r.scope(ctx, q); err != nil {
+		return nil, err
+	}
+	sqlStr, args, err := q.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+	}
+	var current []Person
+	if err := sqlx.SelectContext(dbx.WithPrimary(ctx), db, &current, r.rebind(sqlStr), args...); err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]Person, len(current))
+	for _, p := range current {
+		existing[p.UserId] = p
+	}
+	changed := make([]Person, 0, len(chunk))
+	for _, p := range chunk {
+		if old, ok := existing[p.UserId]; ok && old.Username == p.Username && old.Age == p.Age && old.Address == p.Address {
+			continue
+		}
+		changed = append(changed, p)
+	}
+	return changed, nil
+}
+
+// ============================= 4. 内存批量写入 ====================
+// BatchInsert 分批规则和影响行数计算方式与MySQL实现一致
+// 非upsert模式下遇到重复主键时，该批之前的批次已经写入，该批不写入
+func (r *MemoryPersonRepository) BatchInsert(ctx context.Context, persons []Person, opts BatchOptions) (*BatchResult, error) {
+	if err := validatePersons(persons); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stampPersons(persons)
+	result := &BatchResult{}
+	for i, chunk := range chunkPersons(persons, opts) {
+		if !opts.Upsert {
+			seen := make(map[string]bool, len(chunk))
+			for _, p := range chunk {
+				if _, ok := r.persons[p.UserId]; ok || seen[p.UserId] {
+					return result, fmt.Errorf("第%d批(%d行)写入失败: %w: %q", i+1, len(chunk), ErrDuplicateKey, p.UserId)
+				}
+				seen[p.UserId] = true
+			}
+		}
+
+		var affected int64
+		for _, p := range chunk {
+			old, ok := r.persons[p.UserId]
+			if !ok {
+				r.persons[p.UserId] = p
+				affected++
+				continue
+			}
+			// 与ON DUPLICATE KEY UPDATE一致: 保留创建时间和删除标记，值未变化时不修改updated_at
+			if old.Username == p.Username && old.Age == p.Age && old.Address == p.Address {
+				affected++
+				continue
+			}
+			old.Username, old.Age, old.Address, old.UpdatedAt = p.Username, p.Age, p.Address, p.UpdatedAt
+			old.Version++
+			r.persons[p.UserId] = old
+			affected += 2
+		}
+		result.Affected = append(result.Affected, affected)
+		result.Total += affected
+	}
+	return result, nil
+}
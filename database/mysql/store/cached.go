@@ -0,0 +1,357 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Gocommunity/database/mysql/cache"
+	"Gocommunity/database/mysql/secret"
+	"Gocommunity/database/mysql/tenant"
+)
+
+// ============================= 1. 缓存配置 ====================
+const (
+	DefaultCacheCapacity = 10000            // 本地缓存默认最多保存的用户数
+	DefaultCacheTTL      = time.Minute      // 本地缓存默认有效期
+	DefaultNegativeTTL   = 10 * time.Second // 不存在的ID默认缓存时长
+	DefaultStoreTTL      = 10 * time.Minute // 外部缓存默认有效期
+)
+
+// CacheOption 创建CachedPersonRepository时的可选配置
+type CacheOption func(*CachedPersonRepository)
+
+// WithCacheCapacity 本地LRU最多保存的条目数
+func WithCacheCapacity(n int) CacheOption {
+	return func(r *CachedPersonRepository) { r.capacity = n }
+}
+
+// WithCacheTTL 本地缓存的有效期
+// 其它进程修改数据后，本进程最多在ttl之后看到新数据，多实例部署时应设置得较短
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(r *CachedPersonRepository) { r.ttl = ttl }
+}
+
+// WithNegativeTTL 不存在的ID的缓存时长，0表示不缓存不存在的结果
+func WithNegativeTTL(ttl time.Duration) CacheOption {
+	return func(r *CachedPersonRepository) { r.negativeTTL = ttl }
+}
+
+// WithExternalCache 在本地缓存和数据库之间增加一层外部缓存，键为 person:<id>，context中有租户时为 person:<租户>/<id>
+// 配置了字段加密密钥时，写入外部缓存的内容也会加密
+func WithExternalCache(store cache.Store, ttl time.Duration) CacheOption {
+	return func(r *CachedPersonRepository) { r.store, r.storeTTL = store, ttl }
+}
+
+// CacheStats 缓存命中情况
+type CacheStats struct {
+	Hits         uint64 `json:"hits"`          // 本地缓存命中(包括不存在的ID)
+	NegativeHits uint64 `json:"negative_hits"` // 命中的是"不存在"的结果
+	StoreHits    uint64 `json:"store_hits"`    // 外部缓存命中
+	Misses       uint64 `json:"misses"`        // 查询了数据库
+	Shared       uint64 `json:"shared"`        // 与其它并发请求合并，没有单独查询
+	StoreErrors  uint64 `json:"store_errors"`  // 外部缓存读写失败，已降级为查询数据库
+}
+
+// ============================= 2. 读穿透缓存 ====================
+// CachedPersonRepository 在PersonRepository前面增加读穿透缓存，只缓存Get
+//
+//   - 本地LRU(带TTL) -> 外部缓存(可选) -> 数据库，逐级查找并回填
+//   - 同一ID的并发未命中只查询一次数据库(singleflight)
+//   - 不存在的ID在短时间内也被缓存，避免反复查询不存在的数据
+//   - 通过本仓储写入时使对应ID的缓存失效；绕过本仓储的写入(事务中的仓储、其它进程)需要调用 Invalidate，
+//     例如订阅 outbox 的 person.* 事件
+//   - context中有租户时缓存按租户区分，不同租户中相同的ID互不影响
+//
+// List 和 Search 不缓存，直接查询底层仓储
+type CachedPersonRepository struct {
+	inner PersonRepository
+
+	capacity    int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	store       cache.Store
+	storeTTL    time.Duration
+
+	local  *cache.LRU[string, cacheEntry]
+	flight cache.Group[cacheEntry]
+	// generation 每次失效时加1，加载期间发生过失效的结果不回填，避免把旧数据写回缓存
+	// fillMu 保证检查generation和回填本地缓存之间不会插入 Invalidate
+	fillMu     sync.Mutex
+	generation atomic.Uint64
+
+	hits, negativeHits, storeHits, misses, shared, storeErrors atomic.Uint64
+}
+
+var (
+	_ PersonRepository = (*CachedPersonRepository)(nil)
+	_ SoftDeleter      = (*CachedPersonRepository)(nil)
+	_ BatchWriter      = (*CachedPersonRepository)(nil)
+	_ Searcher         = (*CachedPersonRepository)(nil)
+)
+
+// cacheEntry 缓存的查询结果，Person为nil表示ID不存在
+type cacheEntry struct {
+	Person *Person `json:"person,omitempty"`
+}
+
+// NewCachedPersonRepository 为inner增加缓存
+func NewCachedPersonRepository(inner PersonRepository, opts ...CacheOption) *CachedPersonRepository {
+	r := &CachedPersonRepository{
+		inner:       inner,
+		capacity:    DefaultCacheCapacity,
+		ttl:         DefaultCacheTTL,
+		negativeTTL: DefaultNegativeTTL,
+		storeTTL:    DefaultStoreTTL,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.local = cache.NewLRU[string, cacheEntry](r.capacity, r.ttl)
+	return r
+}
+
+// Get 依次查找本地缓存、外部缓存和底层仓储
+// 返回的是缓存内容的副本，调用方可以修改
+func (r *CachedPersonRepository) Get(ctx context.Context, id string) (*Person, error) {
+	key := cacheKey(ctx, id)
+	if e, ok := r.local.Get(key); ok {
+		r.hits.Add(1)
+		if e.Person == nil {
+			r.negativeHits.Add(1)
+		}
+		return e.result()
+	}
+
+	e, err, shared := r.flight.Do(ctx, key, func(ctx context.Context) (cacheEntry, error) {
+		return r.load(ctx, key, id)
+	})
+	if shared {
+		r.shared.Add(1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e.result()
+}
+
+// load 查询外部缓存和底层仓储并回填
+func (r *CachedPersonRepository) load(ctx context.Context, key, id string) (cacheEntry, error) {
+	gen := r.generation.Load()
+
+	if r.store != nil {
+		data, err := r.store.Get(ctx, storeKey(key))
+		if err == nil {
+			if e, ok := decodeEntry(data); ok {
+				r.storeHits.Add(1)
+				r.fill(gen, key, e, false)
+				return e, nil
+			}
+		} else if !errors.Is(err, cache.ErrMiss) {
+			r.storeErrors.Add(1)
+		}
+	}
+
+	r.misses.Add(1)
+	p, err := r.inner.Get(ctx, id)
+	var e cacheEntry
+	switch {
+	case err == nil:
+		e.Person = p
+	case errors.Is(err, ErrNotFound):
+		if r.negativeTTL <= 0 {
+			return e, err
+		}
+	default:
+		// 连接失败、超时等错误不缓存
+		return e, err
+	}
+	r.fill(gen, key, e, true)
+	return e, nil
+}
+
+// fill 回填缓存，toStore为false表示数据来自外部缓存，不需要写回
+func (r *CachedPersonRepository) fill(gen uint64, key string, e cacheEntry, toStore bool) {
+	ttl, storeTTL := r.ttl, r.storeTTL
+	if e.Person == nil {
+		ttl, storeTTL = min(ttl, r.negativeTTL), r.negativeTTL
+	}
+
+	r.fillMu.Lock()
+	if r.generation.Load() != gen {
+		r.fillMu.Unlock()
+		return
+	}
+	r.local.SetWithTTL(key, e, ttl)
+	r.fillMu.Unlock()
+
+	if toStore && r.store != nil {
+		// 外部缓存失败不影响查询结果
+		data, err := encodeEntry(e)
+		if err == nil {
+			err = r.store.Set(context.Background(), storeKey(key), data, storeTTL)
+		}
+		// 写入外部缓存是网络调用，不能持有锁；期间发生的失效可能先于Set删除了这个键，
+		// 写入后再检查一次，删除可能已经过期的内容
+		if err == nil && r.generation.Load() != gen {
+			err = r.store.Delete(context.Background(), storeKey(key))
+		}
+		if err != nil {
+			r.storeErrors.Add(1)
+		}
+	}
+}
+
+func (e cacheEntry) result() (*Person, error) {
+	if e.Person == nil {
+		return nil, fmt.Errorf("查询用户失败: %w", ErrNotFound)
+	}
+	p := *e.Person
+	return &p, nil
+}
+
+// Invalidate 删除这些ID的本地和外部缓存，context中有租户时只删除该租户的缓存
+func (r *CachedPersonRepository) Invalidate(ctx context.Context, ids ...string) {
+	keys := make([]string, len(ids))
+	r.fillMu.Lock()
+	r.generation.Add(1)
+	for i, id := range ids {
+		key := cacheKey(ctx, id)
+		r.local.Delete(key)
+		keys[i] = storeKey(key)
+	}
+	r.fillMu.Unlock()
+	if r.store != nil && len(keys) > 0 {
+		if err := r.store.Delete(context.WithoutCancel(ctx), keys...); err != nil {
+			r.storeErrors.Add(1)
+		}
+	}
+}
+
+// Stats 返回缓存命中情况
+func (r *CachedPersonRepository) Stats() CacheStats {
+	return CacheStats{
+		Hits:         r.hits.Load(),
+		NegativeHits: r.negativeHits.Load(),
+		StoreHits:    r.storeHits.Load(),
+		Misses:       r.misses.Load(),
+		Shared:       r.shared.Load(),
+		StoreErrors:  r.storeErrors.Load(),
+	}
+}
+
+// ============================= 3. 写操作 ====================
+// 写操作无论成功与否都会使缓存失效: 超时等错误发生时无法确定数据库是否已经修改
+
+// List 不缓存
+func (r *CachedPersonRepository) List(ctx context.Context, opts ListOptions) (*PersonPage, error) {
+	return r.inner.List(ctx, opts)
+}
+
+// Search 不缓存，底层仓储不支持检索时返回 ErrSearchUnavailable
+func (r *CachedPersonRepository) Search(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	searcher, ok := r.inner.(Searcher)
+	if !ok {
+		return nil, fmt.Errorf("检索用户失败: %w", ErrSearchUnavailable)
+	}
+	return searcher.Search(ctx, opts)
+}
+
+// Create 新建后删除该ID"不存在"的缓存
+func (r *CachedPersonRepository) Create(ctx context.Context, p *Person) error {
+	defer r.Invalidate(ctx, p.UserId)
+	return r.inner.Create(ctx, p)
+}
+
+func (r *CachedPersonRepository) Update(ctx context.Context, p *Person) error {
+	defer r.Invalidate(ctx, p.UserId)
+	return r.inner.Update(ctx, p)
+}
+
+func (r *CachedPersonRepository) Delete(ctx context.Context, id string) error {
+	defer r.Invalidate(ctx, id)
+	return r.inner.Delete(ctx, id)
+}
+
+// Restore 底层仓储不支持软删除恢复时返回 errors.ErrUnsupported
+func (r *CachedPersonRepository) Restore(ctx context.Context, id string) error {
+	deleter, ok := r.inner.(SoftDeleter)
+	if !ok {
+		return fmt.Errorf("恢复用户失败: %w", errors.ErrUnsupported)
+	}
+	defer r.Invalidate(ctx, id)
+	return deleter.Restore(ctx, id)
+}
+
+// Purge 回收站中的记录本来就不在缓存中，这里仍然失效以防万一
+func (r *CachedPersonRepository) Purge(ctx context.Context, id string) error {
+	deleter, ok := r.inner.(SoftDeleter)
+	if !ok {
+		return fmt.Errorf("彻底删除用户失败: %w", errors.ErrUnsupported)
+	}
+	defer r.Invalidate(ctx, id)
+	return deleter.Purge(ctx, id)
+}
+
+// BatchInsert 底层仓储不支持批量写入时返回 errors.ErrUnsupported
+func (r *CachedPersonRepository) BatchInsert(ctx context.Context, persons []Person, opts BatchOptions) (*BatchResult, error) {
+	writer, ok := r.inner.(BatchWriter)
+	if !ok {
+		return nil, fmt.Errorf("批量写入失败: %w", errors.ErrUnsupported)
+	}
+	ids := make([]string, len(persons))
+	for i := range persons {
+		ids[i] = persons[i].UserId
+	}
+	defer r.Invalidate(ctx, ids...)
+	return writer.BatchInsert(ctx, persons, opts)
+}
+
+// ============================= 4. 外部缓存序列化 ====================
+// cacheKey 缓存键，context中有租户时加上租户前缀；租户ID不含/，不会与其它键混淆
+func cacheKey(ctx context.Context, id string) string {
+	if t, err := tenant.FromContext(ctx); err == nil {
+		return t + "/" + id
+	}
+	return id
+}
+
+func storeKey(key string) string {
+	return "person:" + key
+}
+
+// encodeEntry 序列化为JSON，配置了加密密钥时整体加密，地址不会以明文出现在外部缓存中
+func encodeEntry(e cacheEntry) ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	if k := secret.Default(); k != nil {
+		return []byte(k.Encrypt(string(data))), nil
+	}
+	return data, nil
+}
+
+// decodeEntry 无法解析或解密的内容按未命中处理，随后会被数据库中的数据覆盖
+func decodeEntry(data []byte) (cacheEntry, bool) {
+	var e cacheEntry
+	if secret.IsEncrypted(string(data)) {
+		k := secret.Default()
+		if k == nil {
+			return e, false
+		}
+		plaintext, err := k.Decrypt(string(data))
+		if err != nil {
+			return e, false
+		}
+		data = []byte(plaintext)
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return e, false
+	}
+	return e, true
+}
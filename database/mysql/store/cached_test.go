@@ -0,0 +1,187 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"Gocommunity/database/mysql/cache"
+	"Gocommunity/database/mysql/store"
+)
+
+// getCounter 统计底层仓储的Get次数；release不为nil时Get在进入后通知entered，等待release关闭后再查询
+type getCounter struct {
+	*store.MemoryPersonRepository
+	gets    atomic.Int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newGetCounter(t *testing.T, persons ...store.Person) *getCounter {
+	r := &getCounter{MemoryPersonRepository: store.NewMemoryPersonRepository()}
+	for i := range persons {
+		if err := r.Create(t.Context(), &persons[i]); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	return r
+}
+
+// block 之后的Get在release关闭前不返回
+func (r *getCounter) block() {
+	r.entered, r.release = make(chan struct{}, 100), make(chan struct{})
+}
+
+func (r *getCounter) Get(ctx context.Context, id string) (*store.Person, error) {
+	r.gets.Add(1)
+	if r.release != nil {
+		r.entered <- struct{}{}
+		<-r.release
+	}
+	return r.MemoryPersonRepository.Get(ctx, id)
+}
+
+var zhang = store.Person{UserId: "12132", Username: "张三", Age: 18, Address: "北京市"}
+
+func TestCachedNegative(t *testing.T) {
+	t.Parallel()
+	inner := newGetCounter(t)
+	repo := store.NewCachedPersonRepository(inner, store.WithNegativeTTL(time.Hour))
+	ctx := t.Context()
+
+	for range 3 {
+		if _, err := repo.Get(ctx, zhang.UserId); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Get err = %v, want ErrNotFound", err)
+		}
+	}
+	if n := inner.gets.Load(); n != 1 {
+		t.Errorf("不存在的ID查询了 %d 次, want 1", n)
+	}
+	if s := repo.Stats(); s.NegativeHits != 2 || s.Misses != 1 {
+		t.Errorf("Stats = %+v", s)
+	}
+
+	// 新建后"不存在"的缓存失效
+	p := zhang
+	if err := repo.Create(ctx, &p); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got, err := repo.Get(ctx, zhang.UserId); err != nil || got.Username != zhang.Username {
+		t.Errorf("新建后 Get = %+v, %v", got, err)
+	}
+}
+
+func TestCachedNegativeTTL(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	// 0表示不缓存不存在的结果
+	inner := newGetCounter(t)
+	repo := store.NewCachedPersonRepository(inner, store.WithNegativeTTL(0))
+	repo.Get(ctx, "missing")
+	repo.Get(ctx, "missing")
+	if n := inner.gets.Load(); n != 2 {
+		t.Errorf("negativeTTL=0: 查询了 %d 次, want 2", n)
+	}
+
+	// 不存在的结果按negativeTTL过期，存在的结果按ttl
+	inner = newGetCounter(t, zhang)
+	repo = store.NewCachedPersonRepository(inner, store.WithCacheTTL(time.Hour), store.WithNegativeTTL(20*time.Millisecond))
+	repo.Get(ctx, "missing")
+	repo.Get(ctx, zhang.UserId)
+	time.Sleep(30 * time.Millisecond)
+	repo.Get(ctx, "missing")
+	repo.Get(ctx, zhang.UserId)
+	if n := inner.gets.Load(); n != 3 {
+		t.Errorf("查询了 %d 次, want 3(不存在的ID过期后重新查询)", n)
+	}
+}
+
+// TestCachedConcurrentMisses 同一ID的并发未命中只查询一次底层仓储
+func TestCachedConcurrentMisses(t *testing.T) {
+	t.Parallel()
+	inner := newGetCounter(t, zhang)
+	inner.block()
+	repo := store.NewCachedPersonRepository(inner)
+
+	const callers = 20
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := repo.Get(t.Context(), zhang.UserId); err != nil || got.Username != zhang.Username {
+				t.Errorf("Get = %+v, %v", got, err)
+			}
+		}()
+	}
+	<-inner.entered
+	// 等待其它调用方进入后再完成查询
+	time.Sleep(50 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	if n := inner.gets.Load(); n != 1 {
+		t.Errorf("查询了 %d 次, want 1", n)
+	}
+	if s := repo.Stats(); s.Misses != 1 || s.Shared != callers-1 {
+		t.Errorf("Stats = %+v, want 1次未命中 %d次合并", s, callers-1)
+	}
+}
+
+// TestCachedInvalidateDuringLoad 查询期间发生的失效不能被查询结果覆盖
+func TestCachedInvalidateDuringLoad(t *testing.T) {
+	t.Parallel()
+	inner := newGetCounter(t, zhang)
+	inner.block()
+	repo := store.NewCachedPersonRepository(inner, store.WithExternalCache(cache.NewLocal(0), time.Hour))
+	ctx := t.Context()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		repo.Get(ctx, zhang.UserId)
+	}()
+	<-inner.entered
+	repo.Invalidate(ctx, zhang.UserId)
+	close(inner.release)
+	<-done
+
+	repo.Get(ctx, zhang.UserId)
+	if n := inner.gets.Load(); n != 2 {
+		t.Errorf("查询了 %d 次, want 2(失效前读到的结果不回填)", n)
+	}
+	if s := repo.Stats(); s.StoreHits != 0 {
+		t.Errorf("外部缓存被回填了失效前的结果: %+v", s)
+	}
+}
+
+// TestCachedExternalStore 本地缓存未命中时从外部缓存读取，写操作同时删除外部缓存
+func TestCachedExternalStore(t *testing.T) {
+	t.Parallel()
+	inner := newGetCounter(t, zhang)
+	shared := cache.NewLocal(0)
+	a := store.NewCachedPersonRepository(inner, store.WithExternalCache(shared, time.Hour))
+	b := store.NewCachedPersonRepository(inner, store.WithExternalCache(shared, time.Hour))
+	ctx := t.Context()
+
+	a.Get(ctx, zhang.UserId)
+	if got, err := b.Get(ctx, zhang.UserId); err != nil || got.Username != zhang.Username {
+		t.Fatalf("b.Get = %+v, %v", got, err)
+	}
+	if n, s := inner.gets.Load(), b.Stats(); n != 1 || s.StoreHits != 1 {
+		t.Errorf("查询了 %d 次, b.Stats = %+v, want 1次查询 1次外部缓存命中", n, s)
+	}
+
+	p, _ := a.Get(ctx, zhang.UserId)
+	p.Age = 19
+	if err := a.Update(ctx, p); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := shared.Get(ctx, "person:"+zhang.UserId); !errors.Is(err, cache.ErrMiss) {
+		t.Errorf("Update后外部缓存 err = %v, want ErrMiss", err)
+	}
+}
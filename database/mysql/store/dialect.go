@@ -0,0 +1,106 @@
+package store
+
+import (
+	"fmt"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/query"
+)
+
+// ============================= 1. SQL方言 ====================
+// Dialect 描述不同数据库在SQL写法上的差异
+// 仓储中的SQL统一使用?占位符，执行前通过 sqlx.Rebind 转换为数据库的格式(PostgreSQL为$1、$2...)
+type Dialect struct {
+	Name string
+
+	// table 表名，user在PostgreSQL中是保留字，需要加双引号
+	table string
+	// persons 查询构造器使用的表定义，列白名单来自Person的db标签
+	// LIKE的转义: MySQL和PostgreSQL默认使用反斜杠转义，SQLite没有默认转义字符，需要声明ESCAPE
+	persons *query.Table
+	// upsert 批量写入时主键冲突的处理子句
+	upsert string
+}
+
+var (
+	// MySQL 使用 ON DUPLICATE KEY UPDATE
+	MySQL = Dialect{
+		Name:    dbx.DriverMySQL,
+		table:   "user",
+		persons: query.NewTable("user", Person{}),
+		upsert:  mysqlUpsert,
+	}
+	// PostgreSQL 使用 ON CONFLICT ... DO UPDATE
+	PostgreSQL = Dialect{
+		Name:    dbx.DriverPostgres,
+		table:   `"user"`,
+		persons: query.NewTable(`"user"`, Person{}),
+		upsert:  onConflictUpsert(`"user"`),
+	}
+	// SQLite 语法与PostgreSQL接近(3.24起支持ON CONFLICT)，适合本地开发和测试
+	SQLite = Dialect{
+		Name:    dbx.DriverSQLite,
+		table:   "user",
+		persons: query.NewTable("user", Person{}, query.WithLikeEscape(` ESCAPE '\'`)),
+		upsert:  onConflictUpsert("user"),
+	}
+)
+
+// Table 返回SQL中使用的表名，PostgreSQL下带双引号
+func (d Dialect) Table() string {
+	return d.table
+}
+
+// Persons 返回user表的查询构造器定义
+func (d Dialect) Persons() *query.Table {
+	return d.persons
+}
+
+// InSchema 返回表名带schema前缀的方言，例如 tenant_a.user，用于每个租户一个schema的部署
+// schema会原样拼接进SQL，只能包含字母、数字和下划线
+// upsert子句中引用的是不带schema的表名，PostgreSQL和SQLite允许这样引用插入的目标表
+func (d Dialect) InSchema(schema string) Dialect {
+	d.table = schema + "." + d.table
+	d.persons = d.persons.WithName(d.table)
+	return d
+}
+
+// DialectFor 按 sqlx 驱动名返回方言，pgx 等兼容驱动按对应数据库处理
+func DialectFor(driverName string) (Dialect, error) {
+	switch driverName {
+	case "mysql", "nrmysql":
+		return MySQL, nil
+	case "postgres", "pgx", "nrpostgres":
+		return PostgreSQL, nil
+	case "sqlite", "sqlite3", "nrsqlite3":
+		return SQLite, nil
+	}
+	return Dialect{}, fmt.Errorf("%w: 不支持的数据库驱动 %q", ErrInvalidQuery, driverName)
+}
+
+// ============================= 2. upsert子句 ====================
+const (
+	// VALUES(col) 引用本行要插入的值，兼容MySQL 5.7和8.0
+	// 主键是(tenant_id, id)，只会更新同一租户中的记录
+	// 赋值按从左到右执行，version和updated_at必须放在最前面，用旧值判断业务字段是否变化，
+	// 未变化时保持version和updated_at不变，影响行数也按"未修改"计算
+	// 不修改created_at和deleted_at: 已软删除的记录被更新后仍在回收站中
+	// 地址加密存储时密文每次都不同，这里的比较总是"已变化"，由 BatchInsert 事先在Go中排除未变化的行
+	mysqlUpsert = ` ON DUPLICATE KEY UPDATE
+		version = IF(name <=> VALUES(name) AND age <=> VALUES(age) AND address <=> VALUES(address),
+			version, version + 1),
+		updated_at = IF(name <=> VALUES(name) AND age <=> VALUES(age) AND address <=> VALUES(address),
+			updated_at, VALUES(updated_at)),
+		name = VALUES(name), age = VALUES(age), address = VALUES(address)`
+)
+
+// onConflictUpsert PostgreSQL和SQLite的upsert子句，excluded引用本行要插入的值
+// 冲突目标是主键(tenant_id, id)，未启用租户隔离时tenant_id为默认值
+// WHERE条件让值未变化的行不被更新，这些行不计入影响行数，version和updated_at也保持不变
+// 与MySQL一样，地址加密存储时未变化的行由 BatchInsert 事先排除
+func onConflictUpsert(table string) string {
+	return fmt.Sprintf(` ON CONFLICT (tenant_id, id) DO UPDATE SET
+		name = excluded.name, age = excluded.age, address = excluded.address,
+		updated_at = excluded.updated_at, version = %[1]s.version + 1
+		WHERE %[1]s.name <> excluded.name OR %[1]s.age <> excluded.age OR %[1]s.address <> excluded.address`, table)
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/tenant"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ============================= 1. 领域错误定义 ====================
+// 数据层返回的错误都会归类到下面的哨兵错误之一，调用方使用 errors.Is 判断
+// 原始的驱动错误仍保留在错误链中，日志里可以看到具体的MySQL错误码
+var (
+	ErrNotFound     = errors.New("记录不存在")    // sql.ErrNoRows 或影响行数为0
+	ErrDuplicateKey = errors.New("主键或唯一键冲突") // MySQL 1062，PostgreSQL 23505
+	ErrConstraint   = errors.New("违反数据约束")   // 外键、非空、CHECK、长度等约束
+	ErrConnection   = errors.New("数据库连接不可用") // 连接断开、拒绝连接、连接数耗尽
+
+	// ErrVersionConflict 乐观锁冲突: 记录在读取之后被其他请求修改过
+	ErrVersionConflict = errors.New("数据已被其他请求修改")
+)
+
+// MySQL 错误码
+const (
+	errCodeTooManyConnections = 1040
+	errCodeNoDefault          = 1364
+	errCodeBadNull            = 1048
+	errCodeDuplicateEntry     = 1062
+	errCodeDataTooLong        = 1406
+	errCodeRowIsReferenced    = 1451
+	errCodeNoReferencedRow    = 1452
+	errCodeCheckViolated      = 3819
+	errCodeServerShutdown     = 1053
+)
+
+// PostgreSQL SQLSTATE 错误码
+const (
+	pgUniqueViolation     = "23505"
+	pgNotNullViolation    = "23502"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+	pgStringTooLong       = "22001"
+	pgTooManyConnections  = "53300"
+	pgAdminShutdown       = "57P01"
+)
+
+// SQLite 错误码，约束错误使用扩展错误码区分类型
+const (
+	sqliteConstraint           = 19
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+)
+
+// ============================= 2. 错误归类 ====================
+// translateError 将驱动错误归类为领域错误，op描述正在执行的操作
+func translateError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if kind := classify(err); kind != nil {
+		return fmt.Errorf("%s: %w: %w", op, kind, err)
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// TranslateError 与仓储内部使用相同的规则归类驱动错误，供 store 包之外的数据访问代码使用，
+// 例如 sqlxgen 生成的仓储
+func TranslateError(op string, err error) error {
+	return translateError(op, err)
+}
+
+// classify 返回错误对应的哨兵错误，无法归类时返回nil
+func classify(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	if code, ok := dbx.MySQLErrorNumber(err); ok {
+		switch code {
+		case errCodeDuplicateEntry:
+			return ErrDuplicateKey
+		case errCodeBadNull, errCodeNoDefault, errCodeDataTooLong,
+			errCodeRowIsReferenced, errCodeNoReferencedRow, errCodeCheckViolated:
+			return ErrConstraint
+		case errCodeTooManyConnections, errCodeServerShutdown:
+			return ErrConnection
+		}
+		return nil
+	}
+
+	if code, ok := dbx.PostgresErrorCode(err); ok {
+		switch code {
+		case pgUniqueViolation:
+			return ErrDuplicateKey
+		case pgNotNullViolation, pgForeignKeyViolation, pgCheckViolation, pgStringTooLong:
+			return ErrConstraint
+		case pgTooManyConnections, pgAdminShutdown:
+			return ErrConnection
+		}
+		return nil
+	}
+
+	if code, ok := dbx.SQLiteExtendedErrorCode(err); ok {
+		switch {
+		case code == sqliteConstraintPrimaryKey || code == sqliteConstraintUnique:
+			return ErrDuplicateKey
+		case code&0xff == sqliteConstraint:
+			return ErrConstraint
+		}
+		return nil
+	}
+
+	// 客户端侧的连接错误: 连接失效、拨号失败、读写超时
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) {
+		return ErrConnection
+	}
+	return nil
+}
+
+// ============================= 3. HTTP状态码映射 ====================
+// HTTPStatus 将数据层错误映射为HTTP状态码，保证各个HTTP服务的处理方式一致
+//
+//	ErrNotFound -> 404  ErrDuplicateKey -> 409  ErrConstraint/ErrInvalidQuery/租户错误 -> 400  tenant.ErrForbidden -> 403
+//	ErrVersionConflict -> 412(版本号来自If-Match)  ErrConnection -> 503  超时 -> 504
+//	不支持的操作(errors.ErrUnsupported，例如 ErrSearchUnavailable) -> 501  其它 -> 500
+func HTTPStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrDuplicateKey):
+		return http.StatusConflict
+	case errors.Is(err, ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrConstraint), errors.Is(err, ErrInvalidQuery),
+		errors.Is(err, tenant.ErrMissing), errors.Is(err, tenant.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, tenant.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrConnection):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, errors.ErrUnsupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
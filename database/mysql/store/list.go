@@ -0,0 +1,194 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultPageSize = 20  // 未指定Limit时每页条数
+	MaxPageSize     = 100 // 每页最多条数，防止一次拉取整张表
+)
+
+// ErrInvalidQuery 列表查询参数不合法(排序字段不在白名单、游标损坏等)
+var ErrInvalidQuery = errors.New("查询参数不合法")
+
+// sortColumns 允许排序的字段白名单: 接口字段名 -> 数据库列名
+// 排序字段会拼接进SQL，必须经过白名单校验，不能直接使用用户输入
+var sortColumns = map[string]string{
+	"id":   "id",
+	"name": "name",
+	"age":  "age",
+}
+
+// DeletedScope 列表查询对软删除记录的处理方式
+type DeletedScope int
+
+const (
+	ExcludeDeleted DeletedScope = iota // 默认: 只返回未删除的记录
+	IncludeDeleted                     // 返回全部记录
+	OnlyDeleted                        // 只返回已删除的记录(回收站)
+)
+
+// ============================= 1. 列表查询参数 ====================
+// ListOptions 用户列表的过滤、排序和分页参数
+// 同时支持offset分页和基于游标(keyset)的分页，Cursor非空时忽略Offset
+type ListOptions struct {
+	// 过滤条件，零值表示不过滤
+	ID              string // 只返回该ID的记录，配合Deleted可以查询回收站中的某条记录
+	NamePrefix      string // 用户名前缀
+	MinAge          *int   // 最小年龄(包含)
+	MaxAge          *int   // 最大年龄(包含)
+	AddressContains string // 地址包含的关键字
+	Deleted         DeletedScope
+
+	// 排序，SortBy为空时按id排序，ID总是作为第二排序字段保证顺序稳定
+	SortBy string
+	Desc   bool
+
+	// 分页
+	Limit  int
+	Offset int
+	Cursor string // 上一页返回的NextCursor
+}
+
+// PersonPage 一页查询结果
+type PersonPage struct {
+	Items      []Person `json:"items"`
+	NextCursor string   `json:"next_cursor,omitempty"` // 为空表示没有下一页
+	Total      int      `json:"total"`                 // 满足过滤条件的总数，与分页无关
+}
+
+// normalize 填充默认值并校验参数，返回解析后的游标(可能为nil)
+func (o *ListOptions) normalize() (*cursor, error) {
+	if o.SortBy == "" {
+		o.SortBy = "id"
+	}
+	if _, ok := sortColumns[o.SortBy]; !ok {
+		return nil, fmt.Errorf("%w: 不支持按 %q 排序", ErrInvalidQuery, o.SortBy)
+	}
+	if o.Limit <= 0 {
+		o.Limit = DefaultPageSize
+	}
+	if o.Limit > MaxPageSize {
+		o.Limit = MaxPageSize
+	}
+	if o.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset不能为负数", ErrInvalidQuery)
+	}
+	if o.MinAge != nil && o.MaxAge != nil && *o.MinAge > *o.MaxAge {
+		return nil, fmt.Errorf("%w: 最小年龄大于最大年龄", ErrInvalidQuery)
+	}
+	if o.Cursor == "" {
+		return nil, nil
+	}
+
+	c, err := decodeCursor(o.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	// 游标只对生成它的排序方式有效
+	if c.SortBy != o.SortBy || c.Desc != o.Desc {
+		return nil, fmt.Errorf("%w: 游标与排序方式不匹配", ErrInvalidQuery)
+	}
+	if o.SortBy == "age" {
+		if _, err := strconv.Atoi(c.Value); err != nil {
+			return nil, fmt.Errorf("%w: 游标已损坏", ErrInvalidQuery)
+		}
+	}
+	return c, nil
+}
+
+// ============================= 2. 游标编码 ====================
+// cursor 记录上一页最后一条记录的排序字段值和ID
+type cursor struct {
+	SortBy string `json:"s"`
+	Desc   bool   `json:"d,omitempty"`
+	Value  string `json:"v"`
+	ID     string `json:"i"`
+}
+
+func newCursor(o ListOptions, last Person) string {
+	c := cursor{SortBy: o.SortBy, Desc: o.Desc, ID: last.UserId}
+	switch o.SortBy {
+	case "name":
+		c.Value = last.Username
+	case "age":
+		c.Value = strconv.Itoa(last.Age)
+	default:
+		c.Value = last.UserId
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 游标已损坏", ErrInvalidQuery)
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: 游标已损坏", ErrInvalidQuery)
+	}
+	return &c, nil
+}
+
+// ============================= 3. 解析HTTP查询参数 ====================
+// ParseListOptions 从URL查询参数解析列表参数，供HTTP服务使用
+//
+//	?name_prefix=张&min_age=18&max_age=30&address=广州
+//	&sort=age&order=desc&limit=20&offset=0&cursor=xxx&deleted=include|only
+func ParseListOptions(q url.Values) (ListOptions, error) {
+	opts := ListOptions{
+		NamePrefix:      q.Get("name_prefix"),
+		AddressContains: q.Get("address"),
+		SortBy:          q.Get("sort"),
+		Cursor:          q.Get("cursor"),
+	}
+
+	switch order := strings.ToLower(q.Get("order")); order {
+	case "", "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, fmt.Errorf("%w: order只能是asc或desc", ErrInvalidQuery)
+	}
+
+	switch deleted := q.Get("deleted"); deleted {
+	case "":
+	case "include":
+		opts.Deleted = IncludeDeleted
+	case "only":
+		opts.Deleted = OnlyDeleted
+	default:
+		return opts, fmt.Errorf("%w: deleted只能是include或only", ErrInvalidQuery)
+	}
+
+	ints := []struct {
+		key string
+		dst func(int)
+	}{
+		{"min_age", func(v int) { opts.MinAge = &v }},
+		{"max_age", func(v int) { opts.MaxAge = &v }},
+		{"limit", func(v int) { opts.Limit = v }},
+		{"offset", func(v int) { opts.Offset = v }},
+	}
+	for _, item := range ints {
+		raw := q.Get(item.key)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return opts, fmt.Errorf("%w: %s必须是整数", ErrInvalidQuery, item.key)
+		}
+		item.dst(v)
+	}
+	return opts, nil
+}
@@ -0,0 +1,233 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ============================= 内存仓储实现 ====================
+// MemoryPersonRepository 基于map的PersonRepository实现
+// 不依赖MySQL服务，适合单元测试和本地演示，可并发使用
+type MemoryPersonRepository struct {
+	mu      sync.RWMutex
+	persons map[string]Person
+}
+
+var (
+	_ PersonRepository = (*MemoryPersonRepository)(nil)
+	_ SoftDeleter      = (*MemoryPersonRepository)(nil)
+)
+
+// NewMemoryPersonRepository 创建空的内存仓储
+func NewMemoryPersonRepository() *MemoryPersonRepository {
+	return &MemoryPersonRepository{persons: make(map[string]Person)}
+}
+
+// Get 返回记录的副本，调用方修改不会影响仓储内数据
+func (r *MemoryPersonRepository) Get(ctx context.Context, id string) (*Person, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.persons[id]
+	if !ok || p.Deleted() {
+		return nil, ErrNotFound
+	}
+	return &p, nil
+}
+
+// List 过滤、排序和分页规则与MySQL实现保持一致
+func (r *MemoryPersonRepository) List(ctx context.Context, opts ListOptions) (*PersonPage, error) {
+	cur, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	matched := make([]Person, 0, len(r.persons))
+	for _, p := range r.persons {
+		if matchListOptions(p, opts) {
+			matched = append(matched, p)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		c := comparePersons(matched[i], matched[j], opts.SortBy)
+		if opts.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	page := &PersonPage{Total: len(matched)}
+	start := 0
+	if cur != nil {
+		// 找到第一条排在游标之后的记录
+		last := cursorPerson(cur)
+		start = sort.Search(len(matched), func(i int) bool {
+			c := comparePersons(matched[i], last, opts.SortBy)
+			if opts.Desc {
+				return c < 0
+			}
+			return c > 0
+		})
+	} else {
+		start = min(opts.Offset, len(matched))
+	}
+
+	end := min(start+opts.Limit, len(matched))
+	page.Items = matched[start:end]
+	if end < len(matched) && end > start {
+		page.NextCursor = newCursor(opts, matched[end-1])
+	}
+	return page, nil
+}
+
+func matchListOptions(p Person, opts ListOptions) bool {
+	switch opts.Deleted {
+	case ExcludeDeleted:
+		if p.Deleted() {
+			return false
+		}
+	case OnlyDeleted:
+		if !p.Deleted() {
+			return false
+		}
+	}
+	if opts.ID != "" && p.UserId != opts.ID {
+		return false
+	}
+	if opts.NamePrefix != "" && !strings.HasPrefix(p.Username, opts.NamePrefix) {
+		return false
+	}
+	if opts.MinAge != nil && p.Age < *opts.MinAge {
+		return false
+	}
+	if opts.MaxAge != nil && p.Age > *opts.MaxAge {
+		return false
+	}
+	if opts.AddressContains != "" && !strings.Contains(string(p.Address), opts.AddressContains) {
+		return false
+	}
+	return true
+}
+
+// comparePersons 先按排序字段比较，相等时按ID比较
+func comparePersons(a, b Person, sortBy string) int {
+	var c int
+	switch sortBy {
+	case "name":
+		c = strings.Compare(a.Username, b.Username)
+	case "age":
+		c = a.Age - b.Age
+	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(a.UserId, b.UserId)
+}
+
+// cursorPerson 将游标还原成只包含排序字段的Person，便于复用comparePersons
+func cursorPerson(c *cursor) Person {
+	p := Person{UserId: c.ID}
+	switch c.SortBy {
+	case "name":
+		p.Username = c.Value
+	case "age":
+		p.Age, _ = strconv.Atoi(c.Value)
+	}
+	return p
+}
+
+// Create ID已存在时返回ErrDuplicateKey，与MySQL主键冲突一致(包括已软删除的记录)
+// 与SQL实现一样先校验字段，不满足表约束时返回ErrConstraint
+func (r *MemoryPersonRepository) Create(ctx context.Context, p *Person) error {
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("插入用户失败: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.persons[p.UserId]; ok {
+		return fmt.Errorf("插入用户失败: %w: %q", ErrDuplicateKey, p.UserId)
+	}
+	p.CreatedAt = now()
+	p.UpdatedAt = p.CreatedAt
+	p.DeletedAt = nil
+	p.Version = 1
+	r.persons[p.UserId] = *p
+	return nil
+}
+
+// Update 记录不存在或已删除时返回ErrNotFound，版本号不一致时返回ErrVersionConflict
+func (r *MemoryPersonRepository) Update(ctx context.Context, p *Person) error {
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("更新用户失败: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok := r.persons[p.UserId]
+	if !ok || old.Deleted() {
+		return ErrNotFound
+	}
+	if old.Version != p.Version {
+		return fmt.Errorf("更新用户失败: %w: 当前版本为%d", ErrVersionConflict, old.Version)
+	}
+	p.CreatedAt = old.CreatedAt
+	p.UpdatedAt = now()
+	p.DeletedAt = nil
+	p.Version++
+	r.persons[p.UserId] = *p
+	return nil
+}
+
+// Delete 软删除，记录不存在或已删除时返回ErrNotFound
+func (r *MemoryPersonRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.persons[id]
+	if !ok || p.Deleted() {
+		return ErrNotFound
+	}
+	t := now()
+	p.DeletedAt = &t
+	p.UpdatedAt = t
+	p.Version++
+	r.persons[id] = p
+	return nil
+}
+
+// Restore 只能恢复已软删除的记录
+func (r *MemoryPersonRepository) Restore(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.persons[id]
+	if !ok || !p.Deleted() {
+		return ErrNotFound
+	}
+	p.DeletedAt = nil
+	p.UpdatedAt = now()
+	p.Version++
+	r.persons[id] = p
+	return nil
+}
+
+// Purge 只能彻底删除已软删除的记录
+func (r *MemoryPersonRepository) Purge(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.persons[id]
+	if !ok || !p.Deleted() {
+		return ErrNotFound
+	}
+	delete(r.persons, id)
+	return nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/outbox"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ============================= 变更事件 ====================
+// 事件类型，事件内容是写入后的Person(JSON)，Key是用户ID
+const (
+	TopicPersonCreated  = "person.created"
+	TopicPersonUpdated  = "person.updated"
+	TopicPersonUpserted = "person.upserted" // BatchInsert upsert，内容与写入的数据相同，可能并未变化
+	TopicPersonDeleted  = "person.deleted"
+	TopicPersonRestored = "person.restored"
+	TopicPersonPurged   = "person.purged" // 物理删除，内容只有id
+)
+
+// WithOutbox 每次写入时在同一事务中向outbox表写入变更事件，由 outbox.Relay 投递给下游
+// 仓储基于*sqlx.Tx(或 dbx.InstrumentTx 包装的事务)创建时使用该事务，否则每次写入自动开启事务(db需要实现 dbx.TxBeginner)
+// 需要先执行迁移创建outbox表
+func WithOutbox() SQLOption {
+	return func(r *SQLPersonRepository) { r.outbox = true }
+}
+
+// write 执行一次写操作，fn返回需要发布的事件
+// 启用outbox时fn和事件写入在同一个事务中，任一失败都会回滚；fn可能因死锁被重新执行
+func (r *SQLPersonRepository) write(ctx context.Context, fn func(db sqlx.ExtContext) ([]outbox.Event, error)) error {
+	if !r.outbox {
+		_, err := fn(r.db)
+		return err
+	}
+
+	run := func(tx sqlx.ExtContext) error {
+		events, err := fn(tx)
+		if err != nil {
+			return err
+		}
+		if err := outbox.Insert(ctx, tx, events...); err != nil {
+			return translateError("写入变更事件失败", err)
+		}
+		return nil
+	}
+	if dbx.InTx(r.db) {
+		return run(r.db)
+	}
+	beginner, ok := r.db.(dbx.TxBeginner)
+	if !ok {
+		return fmt.Errorf("启用outbox需要支持事务的连接，%T 不支持", r.db)
+	}
+	return dbx.WithTx(ctx, beginner, dbx.DefaultTxOptions(), func(tx *sqlx.Tx) error {
+		return run(dbx.InstrumentTx(beginner, tx))
+	})
+}
+
+// events 为写入的记录生成事件，未启用outbox时返回nil
+// 启用租户隔离时事件内容带有tenant_id，下游据此区分不同租户中相同的用户ID
+func (r *SQLPersonRepository) events(tenantID, topic string, persons ...Person) ([]outbox.Event, error) {
+	if !r.outbox {
+		return nil, nil
+	}
+	events := make([]outbox.Event, len(persons))
+	for i := range persons {
+		var payload any = &persons[i]
+		if r.tenantScoped {
+			payload = tenantPerson{Person: persons[i], TenantID: tenantID}
+		}
+		ev, err := outbox.NewEvent(topic, persons[i].UserId, payload)
+		if err != nil {
+			return nil, err
+		}
+		events[i] = ev
+	}
+	return events, nil
+}
+
+// currentEvents 在同一事务中读取写入后的记录(包括已软删除的)并生成事件
+func (r *SQLPersonRepository) currentEvents(ctx context.Context, db sqlx.ExtContext, tenantID, topic, id string) ([]outbox.Event, error) {
+	if !r.outbox {
+		return nil, nil
+	}
+	where, args := r.whereID(tenantID, id)
+	var p Person
+	if err := sqlx.GetContext(ctx, db, &p, r.rebind("SELECT "+selectColumns+" FROM "+r.dialect.table+" WHERE "+where), args...); err != nil {
+		return nil, translateError("读取变更后的用户失败", err)
+	}
+	return r.events(tenantID, topic, p)
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"Gocommunity/database/mysql/secret"
+)
+
+// ============================= 数据模型定义 ====================
+// Person 用户结构体，使用db标签映射数据库user表字段，json标签用于HTTP接口
+type Person struct {
+	UserId   string        `db:"id" json:"id"`           // 用户ID，对应数据库id字段
+	Username string        `db:"name" json:"name"`       // 用户名，对应数据库name字段
+	Age      int           `db:"age" json:"age"`         // 年龄，对应数据库age字段
+	Address  secret.String `db:"address" json:"address"` // 地址，对应数据库address字段，配置密钥后加密存储
+
+	// 审计字段由仓储自动维护，调用方设置的值会被覆盖
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`           // 创建时间
+	UpdatedAt time.Time  `db:"updated_at" json:"updated_at"`           // 最后修改时间
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"` // 软删除时间，nil表示未删除
+
+	// Version 乐观锁版本号，创建时为1，每次修改加1
+	// Update 只有在数据库中的版本号等于Version时才会成功
+	Version int64 `db:"version" json:"version"`
+}
+
+// Deleted 记录是否已被软删除
+func (p *Person) Deleted() bool {
+	return p.DeletedAt != nil
+}
+
+// 字段长度限制，与user表的列定义和 api 包的binding标签一致
+const (
+	MaxIDLength      = 64
+	MaxNameLength    = 64
+	MaxAddressLength = 255
+	MaxAge           = 150
+)
+
+// Validate 检查业务字段是否满足表约束
+// 各仓储的Create、Update和BatchInsert在写入前都会调用，种子数据和导入在解析时调用以便报告行号
+// 长度按字符计算，与utf8mb4的VARCHAR(n)一致
+func (p *Person) Validate() error {
+	switch {
+	case p.UserId == "" || len([]rune(p.UserId)) > MaxIDLength:
+		return fmt.Errorf("%w: id不能为空且不超过%d个字符", ErrConstraint, MaxIDLength)
+	case p.Username == "" || len([]rune(p.Username)) > MaxNameLength:
+		return fmt.Errorf("%w: name不能为空且不超过%d个字符", ErrConstraint, MaxNameLength)
+	case p.Age < 0 || p.Age > MaxAge:
+		return fmt.Errorf("%w: age必须在0到%d之间", ErrConstraint, MaxAge)
+	case len([]rune(p.Address)) > MaxAddressLength:
+		return fmt.Errorf("%w: address不超过%d个字符", ErrConstraint, MaxAddressLength)
+	case secret.IsEncrypted(string(p.Address)):
+		// 未配置密钥时地址按明文写入，读取时会被当作密文而无法解密
+		return fmt.Errorf("%w: address不能以 enc:v1: 开头", ErrConstraint)
+	}
+	return nil
+}
+
+// now 审计字段使用的当前时间，截断到毫秒与 DATETIME(3) 的精度一致
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// ============================= 乐观锁与ETag ====================
+// ETag 用版本号生成HTTP强校验ETag，例如 "3"
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// ParseETag 解析If-Match请求头中的版本号，弱校验(W/前缀)和 * 不能用于乐观锁
+func ParseETag(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if strings.HasPrefix(header, "W/") || header == "*" {
+		return 0, fmt.Errorf("%w: If-Match必须是强校验ETag", ErrInvalidQuery)
+	}
+	raw, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, fmt.Errorf("%w: If-Match格式错误", ErrInvalidQuery)
+	}
+	version, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("%w: If-Match格式错误", ErrInvalidQuery)
+	}
+	return version, nil
+}
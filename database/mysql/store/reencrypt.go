@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/secret"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ============================= 重新加密 ====================
+// ReencryptOptions 重新加密任务的参数
+type ReencryptOptions struct {
+	// BatchSize 每次读取的行数，<=0时使用DefaultBatchSize
+	BatchSize int
+	// DryRun 为true时只统计需要改写的行数，不写入
+	DryRun bool
+	// OnBatch 每处理完一批后调用，用于输出进度
+	OnBatch func(ReencryptResult)
+}
+
+// ReencryptResult 重新加密的统计结果
+type ReencryptResult struct {
+	Scanned   int // 读取的行数
+	Rewritten int // 明文或旧密钥加密的行，已使用当前密钥重新加密
+	Current   int // 已经使用当前密钥加密(或地址为空)，无需处理
+	Conflicts int // 读取之后被其他请求修改过，跳过(新写入的值已使用当前密钥)
+}
+
+func (r ReencryptResult) String() string {
+	return fmt.Sprintf("读取%d 重新加密%d 无需处理%d 冲突跳过%d", r.Scanned, r.Rewritten, r.Current, r.Conflicts)
+}
+
+// ReencryptAddresses 将address列中的明文和旧密钥密文改写为当前密钥加密的密文
+// 用于启用加密后迁移已有数据，以及轮换密钥后淘汰旧密钥；全部完成后才能从密钥列表中删除旧密钥
+// 包括回收站中的记录；只改写address列，不修改version和updated_at，不影响乐观锁
+// 按主键(tenant_id, id)分批读取，每行单独更新并以读取时的值作为条件，可以在服务运行时执行，中断后重新执行即可继续
+// 这是运维任务，不受 WithTenantScope 限制，处理表中所有租户的数据
+func (r *SQLPersonRepository) ReencryptAddresses(ctx context.Context, opts ReencryptOptions) (ReencryptResult, error) {
+	var result ReencryptResult
+	keyring := secret.Default()
+	if keyring == nil {
+		return result, secret.ErrNoKeyring
+	}
+	size := opts.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	// 读取原始值，不经过 secret.String 解密
+	type storedRow struct {
+		TenantID string `db:"tenant_id"`
+		ID       string `db:"id"`
+		Address  string `db:"address"`
+	}
+	selectSQL := r.rebind("SELECT tenant_id, id, address FROM " + r.dialect.table +
+		" WHERE (tenant_id, id) > (?, ?) ORDER BY tenant_id, id LIMIT ?")
+	updateSQL := r.rebind("UPDATE " + r.dialect.table + " SET address = ? WHERE tenant_id = ? AND id = ? AND address = ?")
+
+	var last storedRow
+	for {
+		var rows []storedRow
+		qctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+		err := sqlx.SelectContext(qctx, r.db, &rows, selectSQL, last.TenantID, last.ID, size)
+		cancel()
+		if err != nil {
+			return result, translateError("读取用户地址失败", err)
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		for _, row := range rows {
+			result.Scanned++
+			if row.Address == "" || secret.KeyID(row.Address) == keyring.Current() {
+				result.Current++
+				continue
+			}
+			plaintext := row.Address
+			if secret.IsEncrypted(row.Address) {
+				if plaintext, err = keyring.Decrypt(row.Address); err != nil {
+					return result, fmt.Errorf("解密用户 %s 的地址失败: %w", row.ID, err)
+				}
+			}
+			if opts.DryRun {
+				result.Rewritten++
+				continue
+			}
+
+			qctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+			res, err := r.db.ExecContext(qctx, updateSQL, keyring.Encrypt(plaintext), row.TenantID, row.ID, row.Address)
+			cancel()
+			if err != nil {
+				return result, translateError("更新用户 "+row.ID+" 的地址失败", err)
+			}
+			if n, err := res.RowsAffected(); err == nil && n == 0 {
+				result.Conflicts++
+				continue
+			}
+			result.Rewritten++
+		}
+
+		last = rows[len(rows)-1]
+		if opts.OnBatch != nil {
+			opts.OnBatch(result)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package store
+
+import "context"
+
+// ============================= 仓储接口定义 ====================
+// PersonRepository 定义了Person的数据访问操作
+// 业务代码只依赖该接口，底层可以是MySQL，也可以是内存实现(用于测试)
+// 默认只操作未软删除的记录，已删除的记录可以通过 SoftDeleter 恢复
+// 返回的错误可以用 errors.Is 与 ErrNotFound、ErrDuplicateKey 等哨兵错误比较
+type PersonRepository interface {
+	// Get 根据ID查询单个用户，不存在时返回ErrNotFound
+	Get(ctx context.Context, id string) (*Person, error)
+	// List 按过滤条件分页查询用户，排序字段只能是白名单中的字段
+	List(ctx context.Context, opts ListOptions) (*PersonPage, error)
+	// Create 新增用户，ID由调用方指定
+	Create(ctx context.Context, p *Person) error
+	// Update 根据ID和p.Version更新用户，成功后p.Version加1
+	// 不存在或已删除时返回ErrNotFound，版本号不一致时返回ErrVersionConflict
+	Update(ctx context.Context, p *Person) error
+	// Delete 软删除用户(设置deleted_at)，不存在或已删除时返回ErrNotFound
+	Delete(ctx context.Context, id string) error
+}
+
+// SoftDeleter 软删除记录的恢复和彻底删除
+type SoftDeleter interface {
+	// Restore 恢复已软删除的用户，用户不存在或未被删除时返回ErrNotFound
+	Restore(ctx context.Context, id string) error
+	// Purge 从数据库中彻底删除已软删除的用户，只能删除回收站中的记录，未软删除时返回ErrNotFound
+	Purge(ctx context.Context, id string) error
+}
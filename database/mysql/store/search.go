@@ -0,0 +1,275 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/secret"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ============================= 1. 检索参数 ====================
+const (
+	MaxSearchLength = 100 // 关键字最多字符数
+	MaxSearchTerms  = 8   // 最多几个词
+)
+
+// ErrSearchUnavailable 仓储不能在数据库中全文检索: 不是MySQL、没有FULLTEXT索引或地址已加密存储
+// NewSearchablePersonRepository 遇到该错误时改用进程内的倒排索引
+var ErrSearchUnavailable = fmt.Errorf("数据库不支持全文检索: %w", errors.ErrUnsupported)
+
+// Searcher 按用户名和地址全文检索，实现了该接口的仓储可以直接检索
+type Searcher interface {
+	// Search 返回按相关度从高到低排列的结果，已软删除的记录不会出现在结果中
+	Search(ctx context.Context, opts SearchOptions) (*SearchResult, error)
+}
+
+// SearchOptions 检索参数
+// Query按空白和标点拆分为多个词，每个词都必须出现在用户名或地址中(不区分大小写)，
+// 词可以是名字或地址的一部分，例如 "张" 能找到 "张三"，"朝阳" 能找到 "北京市朝阳区"
+type SearchOptions struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+// SearchHit 一条检索结果
+// Score只用于排序，MySQL和进程内索引的分数不能相互比较
+// Highlights的键是匹配到的字段(name、address)，值是用<em>标记关键字的HTML片段，其余文本已转义
+type SearchHit struct {
+	Person
+	Score      float64           `db:"score" json:"score"`
+	Highlights map[string]string `db:"-" json:"highlights,omitempty"`
+}
+
+// SearchResult 一页检索结果
+type SearchResult struct {
+	Items []SearchHit `json:"items"`
+	Total int         `json:"total"` // 匹配的总数，与分页无关
+}
+
+// ParseSearchOptions 从URL查询参数解析检索参数: q、limit、offset
+func ParseSearchOptions(values url.Values) (SearchOptions, error) {
+	opts := SearchOptions{Query: values.Get("q")}
+	for name, dst := range map[string]*int{"limit": &opts.Limit, "offset": &opts.Offset} {
+		if v := values.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return opts, fmt.Errorf("%w: %s必须是整数", ErrInvalidQuery, name)
+			}
+			*dst = n
+		}
+	}
+	return opts, nil
+}
+
+// normalize 填充默认值并校验参数，返回拆分后的词
+func (o *SearchOptions) normalize() ([]string, error) {
+	if len([]rune(o.Query)) > MaxSearchLength {
+		return nil, fmt.Errorf("%w: 关键字不能超过%d个字符", ErrInvalidQuery, MaxSearchLength)
+	}
+	terms := searchTerms(o.Query)
+	switch {
+	case len(terms) == 0:
+		return nil, fmt.Errorf("%w: 关键字不能为空", ErrInvalidQuery)
+	case len(terms) > MaxSearchTerms:
+		return nil, fmt.Errorf("%w: 关键字最多%d个词", ErrInvalidQuery, MaxSearchTerms)
+	case o.Offset < 0:
+		return nil, fmt.Errorf("%w: offset不能为负数", ErrInvalidQuery)
+	}
+	if o.Limit <= 0 {
+		o.Limit = DefaultPageSize
+	}
+	if o.Limit > MaxPageSize {
+		o.Limit = MaxPageSize
+	}
+	return terms, nil
+}
+
+// ============================= 2. 分词与高亮 ====================
+// searchTerms 按字母和数字以外的字符拆分关键字，转为小写并去重
+// 只保留字母和数字后，词中不会出现MySQL布尔模式的运算符
+func searchTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(query, isSeparator) {
+		if term := lower(word); !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// lower 逐个字符转为小写，字符数不变，下标与原文一一对应
+func lower(s string) string {
+	return strings.Map(unicode.ToLower, s)
+}
+
+// occurrences 返回term在text中每次出现的起始位置，text和term都已转为小写
+func occurrences(text, term []rune) []int {
+	var at []int
+	for i := 0; i+len(term) <= len(text); i++ {
+		if slices.Equal(text[i:i+len(term)], term) {
+			at = append(at, i)
+		}
+	}
+	return at
+}
+
+// highlight 用<em>标记text中出现的所有词，没有匹配时返回空字符串
+func highlight(text string, terms []string) string {
+	runes := []rune(text)
+	lowered := []rune(lower(text))
+	marked := make([]bool, len(runes))
+	found := false
+	for _, term := range terms {
+		t := []rune(term)
+		for _, i := range occurrences(lowered, t) {
+			for j := range t {
+				marked[i+j] = true
+			}
+			found = true
+		}
+	}
+	if !found {
+		return ""
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			segment = "<em>" + segment + "</em>"
+		}
+		b.WriteString(segment)
+		i = j
+	}
+	return b.String()
+}
+
+// highlight 为匹配到的字段生成高亮片段
+func (h *SearchHit) highlight(terms []string) {
+	h.Highlights = nil
+	for field, text := range map[string]string{"name": h.Username, "address": string(h.Address)} {
+		if s := highlight(text, terms); s != "" {
+			if h.Highlights == nil {
+				h.Highlights = make(map[string]string, 2)
+			}
+			h.Highlights[field] = s
+		}
+	}
+}
+
+// ============================= 3. MySQL全文检索 ====================
+// fullTextIndex 迁移0008在user表上创建的FULLTEXT索引，使用ngram分词以支持中文
+const fullTextIndex = "ft_user_name_address"
+
+// Search 使用MySQL的FULLTEXT索引(WITH PARSER ngram)检索用户名和地址，按相关度排序
+//
+// ngram把文本切成长度为ngram_token_size(默认2)的片段，每个词按短语匹配，相当于子串匹配；
+// 只有一个字的词按前缀匹配，能找到以该字开头的片段，位于文本末尾的单字匹配不到
+//
+// 以下情况返回 ErrSearchUnavailable，需要配合 NewSearchablePersonRepository 使用进程内索引:
+// 不是MySQL、没有执行迁移0008、配置了地址加密(索引中是密文)
+func (r *SQLPersonRepository) Search(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	terms, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := r.tenant(ctx)
+	if err != nil {
+		return nil, translateError("检索用户失败", err)
+	}
+	ok, err := r.fullTextAvailable(ctx)
+	if err != nil {
+		return nil, translateError("检查全文索引失败", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("检索用户失败: %w", ErrSearchUnavailable)
+	}
+
+	const match = "MATCH(name, address) AGAINST (? IN BOOLEAN MODE)"
+	against := booleanQuery(terms)
+	where, args := "deleted_at IS NULL AND "+match, []any{against}
+	if r.tenantScoped {
+		where, args = tenantColumn+" = ? AND "+where, []any{tenantID, against}
+	}
+
+	result := &SearchResult{Items: []SearchHit{}}
+	if err := sqlx.GetContext(ctx, r.db, &result.Total, r.rebind("SELECT COUNT(*) FROM "+r.dialect.table+" WHERE "+where), args...); err != nil {
+		return nil, translateError("检索用户失败", err)
+	}
+	if result.Total <= opts.Offset {
+		return result, nil
+	}
+
+	query := "SELECT " + selectColumns + ", " + match + " AS score FROM " + r.dialect.table +
+		" WHERE " + where + " ORDER BY score DESC, id LIMIT ? OFFSET ?"
+	args = append(append([]any{against}, args...), opts.Limit, opts.Offset)
+	if err := sqlx.SelectContext(ctx, r.db, &result.Items, r.rebind(query), args...); err != nil {
+		return nil, translateError("检索用户失败", err)
+	}
+	for i := range result.Items {
+		result.Items[i].highlight(terms)
+	}
+	return result, nil
+}
+
+// booleanQuery 生成布尔模式的检索式: 每个词都必须出现(+)，多个字的词按短语匹配，单字按前缀匹配
+func booleanQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		if len([]rune(term)) == 1 {
+			parts[i] = "+" + term + "*"
+		} else {
+			parts[i] = `+"` + term + `"`
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// fullTextAvailable 是否可以使用FULLTEXT索引，查询成功后缓存结果；加密开关随时可能变化，每次都检查
+func (r *SQLPersonRepository) fullTextAvailable(ctx context.Context) (bool, error) {
+	if r.dialect.Name != dbx.DriverMySQL || secret.Default() != nil {
+		return false, nil
+	}
+	r.fullTextMu.Lock()
+	defer r.fullTextMu.Unlock()
+	if r.fullTextChecked {
+		return r.fullText, nil
+	}
+
+	// schema为空时使用连接的默认数据库
+	var schema any
+	if r.schema != "" {
+		schema = r.schema
+	}
+	var n int
+	err := sqlx.GetContext(ctx, r.db, &n, `SELECT COUNT(*) FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = COALESCE(?, DATABASE()) AND TABLE_NAME = 'user' AND INDEX_NAME = ?`, schema, fullTextIndex)
+	if err != nil {
+		return false, err
+	}
+	r.fullTextChecked, r.fullText = true, n > 0
+	return r.fullText, nil
+}
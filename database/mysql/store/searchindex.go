@@ -0,0 +1,422 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Gocommunity/database/mysql/cache"
+	"Gocommunity/database/mysql/tenant"
+)
+
+// ============================= 1. 进程内倒排索引 ====================
+// searchIndex 用户名和地址的倒排索引，词条是单字和相邻两个字(与MySQL的ngram分词类似)，
+// 中文不需要分词词典也能按任意片段检索
+// 查询时先用词条的倒排列表求交集得到候选，再逐条确认关键字确实是子串，避免两个字的片段拼出误匹配
+type searchIndex struct {
+	mu       sync.RWMutex
+	docs     map[string]*indexedPerson
+	postings map[string]map[string]struct{} // 词条 -> 用户ID集合
+	built    time.Time                      // 为零值时下次检索重建
+}
+
+// indexedPerson 转为小写的字段，与检索词使用相同的规则
+type indexedPerson struct {
+	person        Person
+	name, address []rune
+	grams         []string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{docs: make(map[string]*indexedPerson), postings: make(map[string]map[string]struct{})}
+}
+
+// grams 文本按字母和数字以外的字符拆分后，每一段的单字和相邻两个字，去重
+func grams(text []rune) []string {
+	var out []string
+	add := func(g string) {
+		if !slices.Contains(out, g) {
+			out = append(out, g)
+		}
+	}
+	start := -1
+	for i := 0; i <= len(text); i++ {
+		if i < len(text) && !isSeparator(text[i]) {
+			if start < 0 {
+				start = i
+			}
+			add(string(text[i]))
+			if i > start {
+				add(string(text[i-1 : i+1]))
+			}
+			continue
+		}
+		start = -1
+	}
+	return out
+}
+
+// termGrams 检索一个词需要的词条: 单字本身，或者所有相邻两个字
+func termGrams(term []rune) []string {
+	if len(term) == 1 {
+		return []string{string(term)}
+	}
+	out := make([]string, 0, len(term)-1)
+	for i := 1; i < len(term); i++ {
+		out = append(out, string(term[i-1:i+1]))
+	}
+	return out
+}
+
+// put 新增或替换一个用户
+func (x *searchIndex) put(p Person) {
+	doc := &indexedPerson{person: p, name: []rune(lower(p.Username)), address: []rune(lower(string(p.Address)))}
+	doc.grams = grams(append(append(slices.Clone(doc.name), ' '), doc.address...))
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.removeLocked(p.UserId)
+	x.docs[p.UserId] = doc
+	for _, g := range doc.grams {
+		ids := x.postings[g]
+		if ids == nil {
+			ids = make(map[string]struct{})
+			x.postings[g] = ids
+		}
+		ids[p.UserId] = struct{}{}
+	}
+}
+
+// update 替换一个已更新的用户，保留索引中的创建时间
+// Update只回写版本号和更新时间，调用方的Person不一定带有数据库中的created_at
+func (x *searchIndex) update(p Person) {
+	x.mu.RLock()
+	if doc, ok := x.docs[p.UserId]; ok {
+		p.CreatedAt = doc.person.CreatedAt
+	}
+	x.mu.RUnlock()
+	x.put(p)
+}
+
+func (x *searchIndex) remove(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.removeLocked(id)
+}
+
+func (x *searchIndex) removeLocked(id string) {
+	doc, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	delete(x.docs, id)
+	for _, g := range doc.grams {
+		delete(x.postings[g], id)
+		if len(x.postings[g]) == 0 {
+			delete(x.postings, g)
+		}
+	}
+}
+
+// lookup 包含所有词条的用户ID，从最短的倒排列表开始求交集
+func (x *searchIndex) lookup(gs []string) map[string]struct{} {
+	lists := make([]map[string]struct{}, len(gs))
+	for i, g := range gs {
+		lists[i] = x.postings[g]
+	}
+	slices.SortFunc(lists, func(a, b map[string]struct{}) int { return cmp.Compare(len(a), len(b)) })
+
+	ids := make(map[string]struct{}, len(lists[0]))
+	for id := range lists[0] {
+		if !slices.ContainsFunc(lists[1:], func(l map[string]struct{}) bool { _, ok := l[id]; return !ok }) {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids
+}
+
+// search 相关度: 每个词在用户名中出现一次计2分、在地址中出现一次计1分，用户名以该词开头再加1分，
+// 乘以该词的逆文档频率 ln(1+N/df)，少见的词权重更高
+func (x *searchIndex) search(terms []string, opts SearchOptions) *SearchResult {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	var candidates map[string]struct{}
+	idf := make([]float64, len(terms))
+	for i, term := range terms {
+		ids := x.lookup(termGrams([]rune(term)))
+		idf[i] = math.Log(1 + float64(len(x.docs))/float64(max(len(ids), 1)))
+		if candidates == nil {
+			candidates = ids
+		} else {
+			maps.DeleteFunc(candidates, func(id string, _ struct{}) bool { _, ok := ids[id]; return !ok })
+		}
+	}
+
+	hits := []SearchHit{}
+	for id := range candidates {
+		doc := x.docs[id]
+		score := 0.0
+		for i, term := range terms {
+			t := []rune(term)
+			inName, inAddress := len(occurrences(doc.name, t)), len(occurrences(doc.address, t))
+			if inName+inAddress == 0 {
+				score = -1
+				break
+			}
+			weight := float64(2*inName + inAddress)
+			if len(doc.name) >= len(t) && slices.Equal(doc.name[:len(t)], t) {
+				weight++
+			}
+			score += weight * idf[i]
+		}
+		if score >= 0 {
+			hits = append(hits, SearchHit{Person: doc.person, Score: math.Round(score*1e4) / 1e4})
+		}
+	}
+	slices.SortFunc(hits, func(a, b SearchHit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.UserId, b.UserId)
+	})
+
+	result := &SearchResult{Items: []SearchHit{}, Total: len(hits)}
+	if opts.Offset < len(hits) {
+		result.Items = hits[opts.Offset:min(opts.Offset+opts.Limit, len(hits))]
+	}
+	for i := range result.Items {
+		result.Items[i].highlight(terms)
+	}
+	return result
+}
+
+// ============================= 2. 检索包装 ====================
+// DefaultIndexTTL 进程内索引默认多久重建一次
+const DefaultIndexTTL = 5 * time.Minute
+
+// SearchOption 创建SearchablePersonRepository时的可选配置
+type SearchOption func(*SearchablePersonRepository)
+
+// WithIndexTTL 进程内索引的有效期，过期后下一次检索时从底层仓储重建，<=0表示不重建
+// 其它进程和绕过本仓储的写入最多在ttl之后才能被检索到
+func WithIndexTTL(ttl time.Duration) SearchOption {
+	return func(r *SearchablePersonRepository) { r.ttl = ttl }
+}
+
+// SearchablePersonRepository 为任意仓储提供全文检索(Searcher)
+//
+//   - 底层仓储能在数据库中检索时(MySQL FULLTEXT ngram索引)直接使用数据库
+//   - 否则返回 ErrSearchUnavailable，改用进程内的倒排索引: 第一次检索时通过List读取全部未删除的用户建立索引，
+//     之后通过本仓储的写入同步更新索引，超过有效期后重建
+//   - context中有租户时每个租户一个索引
+//
+// 进程内索引保存了所有用户，适合PostgreSQL、SQLite和内存实现等数据量不大的场景
+type SearchablePersonRepository struct {
+	inner PersonRepository
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	indexes map[string]*searchIndex // 租户 -> 索引，没有租户时键为空字符串
+	flight  cache.Group[*searchIndex]
+	// generation 每次写入时加1，建立索引期间发生过写入时，索引在下一次检索时重建
+	generation atomic.Uint64
+}
+
+var (
+	_ PersonRepository = (*SearchablePersonRepository)(nil)
+	_ SoftDeleter      = (*SearchablePersonRepository)(nil)
+	_ BatchWriter      = (*SearchablePersonRepository)(nil)
+	_ Searcher         = (*SearchablePersonRepository)(nil)
+)
+
+// NewSearchablePersonRepository 为inner增加全文检索
+func NewSearchablePersonRepository(inner PersonRepository, opts ...SearchOption) *SearchablePersonRepository {
+	r := &SearchablePersonRepository{inner: inner, ttl: DefaultIndexTTL, indexes: make(map[string]*searchIndex)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Search 优先使用底层仓储的检索，不可用时使用进程内索引
+func (r *SearchablePersonRepository) Search(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	if s, ok := r.inner.(Searcher); ok {
+		result, err := s.Search(ctx, opts)
+		if !errors.Is(err, ErrSearchUnavailable) {
+			return result, err
+		}
+	}
+
+	terms, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := r.index(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("建立检索索引失败: %w", err)
+	}
+	return idx.search(terms, opts), nil
+}
+
+// index 返回context中租户的索引，不存在或已过期时重建，同一租户并发的重建只执行一次
+func (r *SearchablePersonRepository) index(ctx context.Context) (*searchIndex, error) {
+	key := indexKey(ctx)
+	r.mu.Lock()
+	idx := r.indexes[key]
+	r.mu.Unlock()
+	if idx != nil && !idx.built.IsZero() && (r.ttl <= 0 || time.Since(idx.built) < r.ttl) {
+		return idx, nil
+	}
+
+	idx, err, _ := r.flight.Do(ctx, key, func(ctx context.Context) (*searchIndex, error) {
+		return r.build(ctx, key)
+	})
+	return idx, err
+}
+
+// build 按ID顺序分页读取全部未删除的用户
+func (r *SearchablePersonRepository) build(ctx context.Context, key string) (*searchIndex, error) {
+	gen := r.generation.Load()
+	idx := newSearchIndex()
+	opts := ListOptions{Limit: MaxPageSize}
+	for {
+		page, err := r.inner.List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.Items {
+			idx.put(p)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	// 读取期间的写入可能已经更新了旧索引而没有反映在新索引中，本次仍然使用，下一次检索重建
+	if r.generation.Load() == gen {
+		idx.built = time.Now()
+	}
+	r.mu.Lock()
+	r.indexes[key] = idx
+	r.mu.Unlock()
+	return idx, nil
+}
+
+// indexKey 索引按context中的租户区分
+func indexKey(ctx context.Context) string {
+	id, _ := tenant.FromContext(ctx)
+	return id
+}
+
+// ============================= 3. 写操作 ====================
+// 写入成功后同步更新已经建立的索引；连接断开、超时等错误无法确定数据库是否已经修改，丢弃索引等下一次检索重建
+
+// apply 写入成功时用fn更新context中租户的索引，结果未知时丢弃该索引，写入被拒绝时不做处理
+// 被拒绝的请求(不存在、版本冲突、校验失败等)不能让索引失效，否则每个失败的请求都会引发一次全量重建
+func (r *SearchablePersonRepository) apply(ctx context.Context, err error, fn func(*searchIndex)) {
+	if rejected(err) {
+		return
+	}
+	r.generation.Add(1)
+	key := indexKey(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	idx := r.indexes[key]
+	switch {
+	case idx == nil:
+	case err != nil:
+		delete(r.indexes, key)
+	default:
+		fn(idx)
+	}
+}
+
+// rejected 写入是否被确定地拒绝，数据库没有任何修改
+func rejected(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrDuplicateKey) || errors.Is(err, ErrConstraint) ||
+		errors.Is(err, ErrVersionConflict) || errors.Is(err, ErrInvalidQuery) || errors.Is(err, errors.ErrUnsupported) ||
+		errors.Is(err, tenant.ErrMissing) || errors.Is(err, tenant.ErrInvalid) || errors.Is(err, tenant.ErrForbidden)
+}
+
+// drop 丢弃context中租户的索引
+func (r *SearchablePersonRepository) drop(ctx context.Context) {
+	r.generation.Add(1)
+	r.mu.Lock()
+	delete(r.indexes, indexKey(ctx))
+	r.mu.Unlock()
+}
+
+func (r *SearchablePersonRepository) Get(ctx context.Context, id string) (*Person, error) {
+	return r.inner.Get(ctx, id)
+}
+
+func (r *SearchablePersonRepository) List(ctx context.Context, opts ListOptions) (*PersonPage, error) {
+	return r.inner.List(ctx, opts)
+}
+
+func (r *SearchablePersonRepository) Create(ctx context.Context, p *Person) error {
+	err := r.inner.Create(ctx, p)
+	r.apply(ctx, err, func(idx *searchIndex) { idx.put(*p) })
+	return err
+}
+
+func (r *SearchablePersonRepository) Update(ctx context.Context, p *Person) error {
+	err := r.inner.Update(ctx, p)
+	r.apply(ctx, err, func(idx *searchIndex) { idx.update(*p) })
+	return err
+}
+
+func (r *SearchablePersonRepository) Delete(ctx context.Context, id string) error {
+	err := r.inner.Delete(ctx, id)
+	r.apply(ctx, err, func(idx *searchIndex) { idx.remove(id) })
+	return err
+}
+
+// Restore 恢复后重新读取记录加入索引；底层仓储不支持软删除恢复时返回 errors.ErrUnsupported
+func (r *SearchablePersonRepository) Restore(ctx context.Context, id string) error {
+	deleter, ok := r.inner.(SoftDeleter)
+	if !ok {
+		return fmt.Errorf("恢复用户失败: %w", errors.ErrUnsupported)
+	}
+	if err := deleter.Restore(ctx, id); err != nil {
+		r.apply(ctx, err, nil)
+		return err
+	}
+	p, err := r.inner.Get(ctx, id)
+	r.apply(ctx, err, func(idx *searchIndex) { idx.put(*p) })
+	return nil
+}
+
+func (r *SearchablePersonRepository) Purge(ctx context.Context, id string) error {
+	deleter, ok := r.inner.(SoftDeleter)
+	if !ok {
+		return fmt.Errorf("彻底删除用户失败: %w", errors.ErrUnsupported)
+	}
+	err := deleter.Purge(ctx, id)
+	r.apply(ctx, err, func(idx *searchIndex) { idx.remove(id) })
+	return err
+}
+
+// BatchInsert upsert后传入的Person没有数据库中的版本号和时间，写入后丢弃索引，下一次检索重建
+// 底层仓储不支持批量写入时返回 errors.ErrUnsupported
+func (r *SearchablePersonRepository) BatchInsert(ctx context.Context, persons []Person, opts BatchOptions) (*BatchResult, error) {
+	writer, ok := r.inner.(BatchWriter)
+	if !ok {
+		return nil, fmt.Errorf("批量写入失败: %w", errors.ErrUnsupported)
+	}
+	result, err := writer.BatchInsert(ctx, persons, opts)
+	// 之前的批次已经写入时，后面的批次被拒绝也需要重建
+	if !rejected(err) || (result != nil && len(result.Affected) > 0) {
+		r.drop(ctx)
+	}
+	return result, err
+}
@@ -0,0 +1,106 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"Gocommunity/database/mysql/dbtest"
+	"Gocommunity/database/mysql/store"
+)
+
+// listCounter 统计List的调用次数，即进程内索引的重建次数；fail不为nil时Delete不执行并返回它
+type listCounter struct {
+	*store.MemoryPersonRepository
+	lists int
+	fail  error
+}
+
+func (r *listCounter) List(ctx context.Context, opts store.ListOptions) (*store.PersonPage, error) {
+	r.lists++
+	return r.MemoryPersonRepository.List(ctx, opts)
+}
+
+func (r *listCounter) Delete(ctx context.Context, id string) error {
+	if r.fail != nil {
+		return r.fail
+	}
+	return r.MemoryPersonRepository.Delete(ctx, id)
+}
+
+// TestSearchIndexRejectedWrites 被拒绝的写入没有修改数据，不能让索引失效；结果未知的错误丢弃索引
+func TestSearchIndexRejectedWrites(t *testing.T) {
+	t.Parallel()
+	inner := &listCounter{MemoryPersonRepository: store.NewMemoryPersonRepository()}
+	repo := store.NewSearchablePersonRepository(inner)
+	ctx := t.Context()
+
+	zhang := &store.Person{UserId: "12132", Username: "张三", Age: 18, Address: "北京市"}
+	if err := repo.Create(ctx, zhang); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	search := func() {
+		t.Helper()
+		if result, err := repo.Search(ctx, store.SearchOptions{Query: "张"}); err != nil || result.Total != 1 {
+			t.Fatalf("Search = %+v, %v", result, err)
+		}
+	}
+	search()
+
+	stale := *zhang
+	stale.Version--
+	rejected := map[string]error{
+		"更新不存在的记录": repo.Update(ctx, &store.Person{UserId: "missing", Username: "x", Version: 1}),
+		"版本冲突":     repo.Update(ctx, &stale),
+		"字段校验":     repo.Update(ctx, &store.Person{UserId: "12132", Version: zhang.Version}),
+		"主键冲突":     repo.Create(ctx, &store.Person{UserId: "12132", Username: "张三"}),
+		"删除不存在的记录": repo.Delete(ctx, "missing"),
+		"恢复未删除的记录": repo.Restore(ctx, "12132"),
+	}
+	for name, err := range rejected {
+		if err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+	search()
+	if inner.lists != 1 {
+		t.Errorf("被拒绝的写入之后重建了索引: List %d 次, want 1", inner.lists)
+	}
+
+	inner.fail = context.DeadlineExceeded
+	if err := repo.Delete(ctx, "12132"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Delete err = %v", err)
+	}
+	search()
+	if inner.lists != 2 {
+		t.Errorf("结果未知的写入之后应重建索引: List %d 次, want 2", inner.lists)
+	}
+}
+
+// TestSearchIndexUpdate 按请求体更新(没有created_at)后，检索结果仍是数据库中的创建时间
+// SQL实现的Update不回写created_at；SQLite不支持全文检索，使用进程内索引
+func TestSearchIndexUpdate(t *testing.T) {
+	t.Parallel()
+	repo := store.NewSearchablePersonRepository(store.NewSQLPersonRepository(dbtest.New(t)))
+	ctx := t.Context()
+
+	created := &store.Person{UserId: "12132", Username: "张三", Age: 18, Address: "北京市"}
+	if err := repo.Create(ctx, created); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := repo.Search(ctx, store.SearchOptions{Query: "张三"}); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	update := &store.Person{UserId: "12132", Username: "张三丰", Age: 19, Address: "武当山", Version: created.Version}
+	if err := repo.Update(ctx, update); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	result, err := repo.Search(ctx, store.SearchOptions{Query: "武当"})
+	if err != nil || result.Total != 1 {
+		t.Fatalf("Search = %+v, %v", result, err)
+	}
+	if hit := result.Items[0]; !hit.CreatedAt.Equal(created.CreatedAt) || hit.Version != update.Version {
+		t.Errorf("检索结果 = %+v, want created_at %v version %d", hit.Person, created.CreatedAt, update.Version)
+	}
+}
@@ -0,0 +1,451 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/outbox"
+	"Gocommunity/database/mysql/query"
+	"Gocommunity/database/mysql/secret"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// selectColumns 查询Person时选择的列，与Person的db标签对应
+const selectColumns = "id, name, age, address, created_at, updated_at, deleted_at, version"
+
+// ============================= SQL 仓储实现 ====================
+// SQLPersonRepository 基于sqlx的PersonRepository实现，支持MySQL、PostgreSQL和SQLite
+// db可以是*sqlx.DB、读写分离的*dbx.Cluster，也可以是*sqlx.Tx，后者用于在事务中操作
+//
+// 注意: MySQL 默认返回的是"实际改变的行数"，更新为相同值时RowsAffected为0，
+// DSN中需要加上 clientFoundRows=true 才能正确判断记录是否存在
+type SQLPersonRepository struct {
+	db      sqlx.ExtContext
+	dialect Dialect
+	timeout time.Duration
+	outbox  bool
+
+	// tenantScoped 按context中的租户隔离，persons是对应的查询构造器定义
+	tenantScoped bool
+	schema       string
+	persons      *query.Table
+
+	// fullText user表上是否有FULLTEXT索引，第一次检索时查询，见 Search
+	fullTextMu      sync.Mutex
+	fullTextChecked bool
+	fullText        bool
+}
+
+var (
+	_ PersonRepository = (*SQLPersonRepository)(nil)
+	_ SoftDeleter      = (*SQLPersonRepository)(nil)
+)
+
+// SQLOption 创建SQLPersonRepository时的可选配置
+type SQLOption func(*SQLPersonRepository)
+
+// WithQueryTimeout 设置单条SQL的超时时间，d<=0表示只依赖调用方的context
+func WithQueryTimeout(d time.Duration) SQLOption {
+	return func(r *SQLPersonRepository) { r.timeout = d }
+}
+
+// WithDialect 指定SQL方言，用于驱动名无法识别的场景
+func WithDialect(d Dialect) SQLOption {
+	return func(r *SQLPersonRepository) { r.dialect = d }
+}
+
+// ConfigOptions 按数据库配置生成仓储选项: 单条SQL超时，配置了outbox时写入变更事件，配置了multi_tenant时按租户隔离
+func ConfigOptions(cfg dbx.Config) []SQLOption {
+	opts := []SQLOption{WithQueryTimeout(time.Duration(cfg.QueryTimeout))}
+	if cfg.Outbox {
+		opts = append(opts, WithOutbox())
+	}
+	if cfg.MultiTenant {
+		opts = append(opts, WithTenantScope())
+	}
+	return opts
+}
+
+// NewSQLPersonRepository 使用已经建立好的连接或事务创建仓储
+// 方言按 db.DriverName() 选择，无法识别的驱动按MySQL处理
+// 默认每条SQL最多执行 dbx.DefaultQueryTimeout
+func NewSQLPersonRepository(db sqlx.ExtContext, opts ...SQLOption) *SQLPersonRepository {
+	dialect, err := DialectFor(db.DriverName())
+	if err != nil {
+		dialect = MySQL
+	}
+	r := &SQLPersonRepository{db: db, dialect: dialect, timeout: dbx.DefaultQueryTimeout}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.schema != "" {
+		r.dialect = r.dialect.InSchema(r.schema)
+	}
+	r.persons = r.dialect.persons
+	if r.tenantScoped {
+		r.persons = r.persons.WithScope(tenantColumn)
+	}
+	return r
+}
+
+// rebind 将?占位符转换为当前数据库的格式
+func (r *SQLPersonRepository) rebind(query string) string {
+	return r.db.Rebind(query)
+}
+
+// Get 查询单条未删除的记录，sql.ErrNoRows 归类为 ErrNotFound
+func (r *SQLPersonRepository) Get(ctx context.Context, id string) (*Person, error) {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := r.tenant(ctx)
+	if err != nil {
+		return nil, translateError("查询用户失败", err)
+	}
+	where, args := r.whereID(tenantID, id)
+	var p Person
+	if err := sqlx.GetContext(ctx, r.db, &p, r.rebind("SELECT "+selectColumns+" FROM "+r.dialect.table+" WHERE "+where+" AND deleted_at IS NULL"), args...); err != nil {
+		return nil, translateError("查询用户失败", err)
+	}
+	return &p, nil
+}
+
+// List 按过滤条件分页查询，同时返回满足条件的总数
+func (r *SQLPersonRepository) List(ctx context.Context, opts ListOptions) (*PersonPage, error) {
+	cur, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// 过滤条件，同时用于统计总数
+	q := r.Query()
+	switch opts.Deleted {
+	case ExcludeDeleted:
+		q.Where(query.IsNull("deleted_at"))
+	case OnlyDeleted:
+		q.Where(query.NotNull("deleted_at"))
+	}
+	if opts.ID != "" {
+		q.Where(query.Eq("id", opts.ID))
+	}
+	if opts.NamePrefix != "" {
+		q.Where(query.Prefix("name", opts.NamePrefix))
+	}
+	if opts.MinAge != nil {
+		q.Where(query.Gte("age", *opts.MinAge))
+	}
+	if opts.MaxAge != nil {
+		q.Where(query.Lte("age", *opts.MaxAge))
+	}
+	if opts.AddressContains != "" {
+		// 地址加密存储后LIKE只能匹配到密文
+		if secret.Default() != nil {
+			return nil, fmt.Errorf("%w: 地址已加密存储，不支持按地址筛选", ErrInvalidQuery)
+		}
+		q.Where(query.Contains("address", opts.AddressContains))
+	}
+
+	page := &PersonPage{}
+	if page.Total, err = r.count(ctx, q); err != nil {
+		return nil, translateError("统计用户数量失败", err)
+	}
+
+	// 排序列来自白名单，ID总是作为第二排序字段
+	column := sortColumns[opts.SortBy]
+	orderBy, after := q.OrderBy, query.Gt
+	if opts.Desc {
+		orderBy, after = q.OrderByDesc, query.Lt
+	}
+	orderBy(column)
+	if column != "id" {
+		orderBy("id")
+	}
+
+	// 游标分页: 取排在上一页最后一条记录之后的数据，不需要扫描并丢弃前面的行
+	if cur != nil {
+		if column == "id" {
+			q.Where(after("id", cur.ID))
+		} else {
+			q.Where(query.Or(
+				after(column, cur.Value),
+				query.And(query.Eq(column, cur.Value), after("id", cur.ID)),
+			))
+		}
+	}
+
+	// 多取一条用于判断是否还有下一页
+	q.Limit(opts.Limit + 1)
+	if cur == nil {
+		q.Offset(opts.Offset)
+	}
+
+	persons, err := r.find(ctx, q)
+	if err != nil {
+		return nil, translateError("查询用户列表失败", err)
+	}
+	if len(persons) > opts.Limit {
+		persons = persons[:opts.Limit]
+		page.NextCursor = newCursor(opts, persons[len(persons)-1])
+	}
+	page.Items = persons
+	return page, nil
+}
+
+// ============================= 自定义查询 ====================
+// Query 返回user表的查询构造器，列名按Person的db标签校验，不指定列时选择所有列
+// 生成的SQL由 Find 和 Count 执行；与List不同，不会自动排除已软删除的记录
+// 启用租户隔离时由 Find 和 Count 填入context中的租户
+//
+//	repo.Find(ctx, repo.Query().Where(query.IsNull("deleted_at"), query.In("id", ids...)).OrderBy("age"))
+func (r *SQLPersonRepository) Query(columns ...string) *query.Builder {
+	return r.persons.Select(columns...)
+}
+
+// Find 执行查询并返回结果，未选择的列保持零值
+func (r *SQLPersonRepository) Find(ctx context.Context, q *query.Builder) ([]Person, error) {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	persons, err := r.find(ctx, q)
+	if err != nil {
+		return nil, translateError("查询用户失败", err)
+	}
+	return persons, nil
+}
+
+// Count 返回满足查询条件的行数，忽略排序和分页
+func (r *SQLPersonRepository) Count(ctx context.Context, q *query.Builder) (int, error) {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	n, err := r.count(ctx, q)
+	if err != nil {
+		return 0, translateError("统计用户数量失败", err)
+	}
+	return n, nil
+}
+
+func (r *SQLPersonRepository) find(ctx context.Context, q *query.Builder) ([]Person, error) {
+	if err := r.scope(ctx, q); err != nil {
+		return nil, err
+	}
+	sqlStr, args, err := q.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+	}
+	var persons []Person
+	if err := sqlx.SelectContext(ctx, r.db, &persons, r.rebind(sqlStr), args...); err != nil {
+		return nil, err
+	}
+	return persons, nil
+}
+
+func (r *SQLPersonRepository) count(ctx context.Context, q *query.Builder) (int, error) {
+	if err := r.scope(ctx, q); err != nil {
+		return 0, err
+	}
+	sqlStr, args, err := q.CountSQL()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+	}
+	var n int
+	if err := sqlx.GetContext(ctx, r.db, &n, r.rebind(sqlStr), args...); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// scope 启用租户隔离时将查询限定在context中的租户
+func (r *SQLPersonRepository) scope(ctx context.Context, q *query.Builder) error {
+	tenantID, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	if r.tenantScoped {
+		q.Scope(tenantID)
+	}
+	return nil
+}
+
+// insertSQL 插入一行的语句，启用租户隔离时同时写入tenant_id
+func (r *SQLPersonRepository) insertSQL() string {
+	if r.tenantScoped {
+		return `INSERT INTO ` + r.dialect.table + ` (tenant_id, id, name, age, address, created_at, updated_at, version)
+		VALUES (:tenant_id, :id, :name, :age, :address, :created_at, :updated_at, :version)`
+	}
+	return `INSERT INTO ` + r.dialect.table + ` (id, name, age, address, created_at, updated_at, version)
+		VALUES (:id, :name, :age, :address, :created_at, :updated_at, :version)`
+}
+
+// Create 使用命名参数插入记录，显式列出字段避免依赖表字段顺序
+// created_at/updated_at 由仓储设置并回写到p，业务字段不满足表约束时返回ErrConstraint
+func (r *SQLPersonRepository) Create(ctx context.Context, p *Person) error {
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("插入用户失败: %w", err)
+	}
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := r.tenant(ctx)
+	if err != nil {
+		return translateError("插入用户失败", err)
+	}
+	p.CreatedAt = now()
+	p.UpdatedAt = p.CreatedAt
+	p.DeletedAt = nil
+	p.Version = 1
+	return r.write(ctx, func(db sqlx.ExtContext) ([]outbox.Event, error) {
+		if _, err := sqlx.NamedExecContext(ctx, db, r.insertSQL(), r.row(tenantID, p)); err != nil {
+			return nil, translateError("插入用户失败", err)
+		}
+		return r.events(tenantID, TopicPersonCreated, *p)
+	})
+}
+
+// Update 更新除ID和创建时间以外的业务字段，已软删除的记录不能更新
+// WHERE条件带上调用方读取时的版本号，影响行数为0时再区分记录不存在还是版本冲突
+func (r *SQLPersonRepository) Update(ctx context.Context, p *Person) error {
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("更新用户失败: %w", err)
+	}
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := r.tenant(ctx)
+	if err != nil {
+		return translateError("更新用户失败", err)
+	}
+	where, whereArgs := r.whereID(tenantID, p.UserId)
+
+	// 写入成功后才修改p，事务因死锁重试时仍使用调用方传入的版本号
+	updated := *p
+	updated.UpdatedAt = now()
+	updated.Version++
+	args := append([]any{p.Username, p.Age, p.Address, updated.UpdatedAt}, whereArgs...)
+	args = append(args, p.Version)
+	err = r.write(ctx, func(db sqlx.ExtContext) ([]outbox.Event, error) {
+		result, err := db.ExecContext(ctx, r.rebind(
+			`UPDATE `+r.dialect.table+` SET name = ?, age = ?, address = ?, updated_at = ?, version = version + 1
+			WHERE `+where+` AND version = ? AND deleted_at IS NULL`), args...)
+		if err != nil {
+			return nil, translateError("更新用户失败", err)
+		}
+		if err := checkAffected(result); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return nil, r.versionConflictOrNotFound(ctx, db, tenantID, p.UserId)
+			}
+			return nil, err
+		}
+		return r.events(tenantID, TopicPersonUpdated, updated)
+	})
+	if err != nil {
+		return err
+	}
+	p.UpdatedAt, p.Version = updated.UpdatedAt, updated.Version
+	return nil
+}
+
+// versionConflictOrNotFound 记录仍存在说明版本号已变化
+// 刚刚执行过写操作，必须从主库读取，避免从库复制延迟导致误判
+func (r *SQLPersonRepository) versionConflictOrNotFound(ctx context.Context, db sqlx.ExtContext, tenantID, id string) error {
+	where, args := r.whereID(tenantID, id)
+	var current int64
+	err := sqlx.GetContext(dbx.WithPrimary(ctx), db, &current, r.rebind("SELECT version FROM "+r.dialect.table+" WHERE "+where+" AND deleted_at IS NULL"), args...)
+	if err != nil {
+		return translateError("更新用户失败", err)
+	}
+	return fmt.Errorf("更新用户失败: %w: 当前版本为%d", ErrVersionConflict, current)
+}
+
+// Delete 软删除: 只设置deleted_at，数据仍保留在表中
+func (r *SQLPersonRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := r.tenant(ctx)
+	if err != nil {
+		return translateError("删除用户失败", err)
+	}
+	where, whereArgs := r.whereID(tenantID, id)
+	t := now()
+	return r.write(ctx, func(db sqlx.ExtContext) ([]outbox.Event, error) {
+		result, err := db.ExecContext(ctx, r.rebind(
+			"UPDATE "+r.dialect.table+" SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE "+where+" AND deleted_at IS NULL"),
+			append([]any{t, t}, whereArgs...)...)
+		if err != nil {
+			return nil, translateError("删除用户失败", err)
+		}
+		if err := checkAffected(result); err != nil {
+			return nil, err
+		}
+		return r.currentEvents(ctx, db, tenantID, TopicPersonDeleted, id)
+	})
+}
+
+// Restore 清除deleted_at，使记录重新可见
+func (r *SQLPersonRepository) Restore(ctx context.Context, id string) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := r.tenant(ctx)
+	if err != nil {
+		return translateError("恢复用户失败", err)
+	}
+	where, whereArgs := r.whereID(tenantID, id)
+	return r.write(ctx, func(db sqlx.ExtContext) ([]outbox.Event, error) {
+		result, err := db.ExecContext(ctx, r.rebind(
+			"UPDATE "+r.dialect.table+" SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE "+where+" AND deleted_at IS NOT NULL"),
+			append([]any{now()}, whereArgs...)...)
+		if err != nil {
+			return nil, translateError("恢复用户失败", err)
+		}
+		if err := checkAffected(result); err != nil {
+			return nil, err
+		}
+		return r.currentEvents(ctx, db, tenantID, TopicPersonRestored, id)
+	})
+}
+
+// Purge 物理删除，条件中限定deleted_at非空，避免误删未经软删除的记录
+func (r *SQLPersonRepository) Purge(ctx context.Context, id string) error {
+	ctx, cancel := dbx.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := r.tenant(ctx)
+	if err != nil {
+		return translateError("彻底删除用户失败", err)
+	}
+	where, args := r.whereID(tenantID, id)
+	return r.write(ctx, func(db sqlx.ExtContext) ([]outbox.Event, error) {
+		result, err := db.ExecContext(ctx, r.rebind("DELETE FROM "+r.dialect.table+" WHERE "+where+" AND deleted_at IS NOT NULL"), args...)
+		if err != nil {
+			return nil, translateError("彻底删除用户失败", err)
+		}
+		if err := checkAffected(result); err != nil {
+			return nil, err
+		}
+		// 记录已不存在，事件只包含id
+		return r.events(tenantID, TopicPersonPurged, Person{UserId: id})
+	})
+}
+
+// checkAffected 影响行数为0说明记录不存在
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return translateError("获取影响行数失败", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"Gocommunity/database/mysql/dbtest"
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/secret"
+	"Gocommunity/database/mysql/store"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// 每个测试使用 dbtest 创建的独立库，默认是SQLite，DBTEST_DRIVER 可以切换到MySQL或PostgreSQL
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+	repo := store.NewSQLPersonRepository(dbtest.New(t, dbtest.WithPersons(dbtest.DemoPersons...)))
+	ctx := t.Context()
+
+	p, err := repo.Get(ctx, "12132")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if p.Username != "张三" || p.Age != 18 || p.Address != "北京市" || p.Version != 1 {
+		t.Errorf("Get = %+v", *p)
+	}
+
+	if _, err := repo.Get(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get(missing) err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	t.Parallel()
+	repo := store.NewSQLPersonRepository(dbtest.New(t))
+	ctx := t.Context()
+
+	p := &store.Person{UserId: "120230", Username: "李四", Age: 12, Address: "广州市"}
+	if err := repo.Create(ctx, p); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if p.Version != 1 || p.CreatedAt.IsZero() {
+		t.Errorf("Create未设置审计字段: %+v", *p)
+	}
+	got, err := repo.Get(ctx, "120230")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Username != "李四" || !got.CreatedAt.Equal(p.CreatedAt) {
+		t.Errorf("Get = %+v, want %+v", *got, *p)
+	}
+
+	cases := []struct {
+		name   string
+		person store.Person
+		want   error
+	}{
+		{"主键冲突", store.Person{UserId: "120230", Username: "王五"}, store.ErrDuplicateKey},
+		{"空用户名", store.Person{UserId: "120231"}, store.ErrConstraint},
+		{"年龄超出范围", store.Person{UserId: "120232", Username: "王五", Age: store.MaxAge + 1}, store.ErrConstraint},
+		// 未配置密钥时写入明文，读取时会被当作密文
+		{"地址以密文前缀开头", store.Person{UserId: "120233", Username: "王五", Address: "enc:v1:test:abc"}, store.ErrConstraint},
+	}
+	for _, tc := range cases {
+		if err := repo.Create(ctx, &tc.person); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+	repo := store.NewSQLPersonRepository(dbtest.New(t, dbtest.WithPersons(dbtest.DemoPersons...)))
+	ctx := t.Context()
+
+	p, err := repo.Get(ctx, "12133")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	stale := *p
+
+	p.Username = "赵六"
+	if err := repo.Update(ctx, p); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if p.Version != 2 {
+		t.Errorf("Update后version = %d, want 2", p.Version)
+	}
+	if got, err := repo.Get(ctx, "12133"); err != nil || got.Username != "赵六" || got.Version != 2 {
+		t.Errorf("Get = %+v, %v", got, err)
+	}
+
+	stale.Age = 99
+	if err := repo.Update(ctx, &stale); !errors.Is(err, store.ErrVersionConflict) {
+		t.Errorf("使用旧版本更新: err = %v, want ErrVersionConflict", err)
+	}
+	missing := store.Person{UserId: "missing", Username: "x", Version: 1}
+	if err := repo.Update(ctx, &missing); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("更新不存在的记录: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeleteRestorePurge(t *testing.T) {
+	t.Parallel()
+	deletedAt := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+	trashed := store.Person{UserId: "trashed", Username: "回收站", Age: 40, DeletedAt: &deletedAt, Version: 5}
+	repo := store.NewSQLPersonRepository(dbtest.New(t, dbtest.WithPersons(dbtest.DemoPersons...), dbtest.WithPersons(trashed)))
+	ctx := t.Context()
+
+	// 测试数据中已软删除的记录
+	if _, err := repo.Get(ctx, "trashed"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get(trashed) err = %v, want ErrNotFound", err)
+	}
+	if err := repo.Restore(ctx, "trashed"); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got, err := repo.Get(ctx, "trashed"); err != nil || got.Version != 6 || got.Deleted() {
+		t.Errorf("恢复后 Get = %+v, %v", got, err)
+	}
+
+	if err := repo.Purge(ctx, "12134"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Purge未删除的记录: err = %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(ctx, "12134"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Delete(ctx, "12134"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("重复Delete: err = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.Get(ctx, "12134"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("删除后 Get err = %v, want ErrNotFound", err)
+	}
+	if err := repo.Purge(ctx, "12134"); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if err := repo.Restore(ctx, "12134"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("彻底删除后 Restore err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestBatchInsert(t *testing.T) {
+	t.Parallel()
+	repo := store.NewSQLPersonRepository(dbtest.New(t, dbtest.WithPersons(dbtest.DemoPersons...)))
+	ctx := t.Context()
+
+	persons := []store.Person{
+		{UserId: "200001", Username: "王五", Age: 20, Address: "深圳市"},
+		{UserId: "200002", Username: "孙七", Age: 22, Address: "杭州市"},
+		{UserId: "200003", Username: "周八", Age: 24, Address: "成都市"},
+	}
+	result, err := repo.BatchInsert(ctx, persons, store.BatchOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("BatchInsert: %v", err)
+	}
+	if len(result.Affected) != 2 || result.Total != 3 {
+		t.Errorf("BatchInsert = %+v, want 2批共3行", *result)
+	}
+
+	// 已存在的主键: 普通插入失败，upsert更新
+	if _, err := repo.BatchInsert(ctx, []store.Person{{UserId: "12132", Username: "张三", Age: 19}}, store.BatchOptions{}); !errors.Is(err, store.ErrDuplicateKey) {
+		t.Errorf("插入已存在的主键: err = %v, want ErrDuplicateKey", err)
+	}
+	upsert := []store.Person{{UserId: "12132", Username: "张三", Age: 19, Address: "北京市"}, {UserId: "200004", Username: "吴九", Age: 26}}
+	if _, err := repo.BatchInsert(ctx, upsert, store.BatchOptions{Upsert: true}); err != nil {
+		t.Fatalf("BatchInsert(upsert): %v", err)
+	}
+	if got, err := repo.Get(ctx, "12132"); err != nil || got.Age != 19 || got.Version != 2 {
+		t.Errorf("upsert后 Get = %+v, %v", got, err)
+	}
+	if _, err := repo.Get(ctx, "200004"); err != nil {
+		t.Errorf("upsert插入的记录: %v", err)
+	}
+
+	invalid := []store.Person{{UserId: "200005", Username: "郑十"}, {UserId: "200006"}}
+	if _, err := repo.BatchInsert(ctx, invalid, store.BatchOptions{}); !errors.Is(err, store.ErrConstraint) {
+		t.Errorf("包含非法记录: err = %v, want ErrConstraint", err)
+	}
+	if _, err := repo.Get(ctx, "200005"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("包含非法记录时不应写入任何记录: %v", err)
+	}
+}
+
+// TestBatchUpsertReadsPrimary 地址加密时upsert在Go中比较已有记录，读取必须发往主库:
+// 从库还是旧数据时，主库上已经变化的行不能被当作未变化而跳过
+// 密钥环是进程级的，这个测试不能与其它测试并行
+func TestBatchUpsertReadsPrimary(t *testing.T) {
+	keyring, err := secret.NewKeyring("test", map[string][]byte{"test": make([]byte, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.SetKeyring(keyring)
+	t.Cleanup(func() { secret.SetKeyring(nil) })
+
+	person := store.Person{UserId: "12132", Username: "张三", Age: 18, Address: "北京市"}
+	primary := dbtest.New(t, dbtest.WithPersons(person))
+	replica := dbtest.New(t, dbtest.WithPersons(person))
+	cluster := dbx.NewCluster(primary, map[string]*sqlx.DB{"replica": replica}, 0)
+	ctx := t.Context()
+
+	// 只写入主库，从库仍是旧数据
+	moved := person
+	moved.Address = "上海市"
+	if _, err := store.NewSQLPersonRepository(primary).BatchInsert(ctx, []store.Person{moved}, store.BatchOptions{Upsert: true}); err != nil {
+		t.Fatalf("BatchInsert(primary): %v", err)
+	}
+
+	if _, err := store.NewSQLPersonRepository(cluster).BatchInsert(ctx, []store.Person{person}, store.BatchOptions{Upsert: true}); err != nil {
+		t.Fatalf("BatchInsert(cluster): %v", err)
+	}
+	got, err := store.NewSQLPersonRepository(primary).Get(ctx, person.UserId)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Address != person.Address || got.Version != 3 {
+		t.Errorf("Get = %+v, want address %s version 3", *got, person.Address)
+	}
+}
+
+// TestWithTxRetry 第一次执行返回死锁错误，WithTx回滚后重新执行，最终只写入一次
+func TestWithTxRetry(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	ctx := t.Context()
+
+	opts := dbx.DefaultTxOptions()
+	opts.BaseBackoff = time.Millisecond
+	attempts := 0
+	err := dbx.WithTx(ctx, db, opts, func(tx *sqlx.Tx) error {
+		attempts++
+		txRepo := store.NewSQLPersonRepository(tx)
+		person := &store.Person{UserId: "99999", Username: "事务测试", Age: 30, Address: "事务地址"}
+		if err := txRepo.Create(ctx, person); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			return &mysql.MySQLError{Number: dbx.ErrCodeDeadlock, Message: "Deadlock found when trying to get lock"}
+		}
+		person.Age = 99
+		return txRepo.Update(ctx, person)
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+	got, err := store.NewSQLPersonRepository(db).Get(ctx, "99999")
+	if err != nil || got.Age != 99 || got.Version != 2 {
+		t.Errorf("Get = %+v, %v", got, err)
+	}
+}
+
+// TestWithTxRollback 不可重试的错误直接返回，事务内的写入全部回滚
+func TestWithTxRollback(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	ctx := t.Context()
+
+	errAbort := errors.New("abort")
+	attempts := 0
+	err := dbx.WithTx(ctx, db, dbx.DefaultTxOptions(), func(tx *sqlx.Tx) error {
+		attempts++
+		if err := store.NewSQLPersonRepository(tx).Create(ctx, &store.Person{UserId: "99999", Username: "事务测试"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) || attempts != 1 {
+		t.Fatalf("WithTx err = %v attempts = %d, want abort and 1", err, attempts)
+	}
+	if _, err := store.NewSQLPersonRepository(db).Get(context.Background(), "99999"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("回滚后 Get err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,152 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"Gocommunity/database/mysql/store"
+)
+
+// ============================= 全文检索 ====================
+// TestSearch 检查repo的全文检索，repo必须实现 store.Searcher(例如 store.SearchablePersonRepository)
+// 写入的记录用户名带随机前缀，每次检索都把前缀作为一个词，库中已有的数据不影响结果
+// 只检查匹配的记录和高亮，相关度的具体顺序各实现不同，不做检查
+func TestSearch(ctx context.Context, repo store.PersonRepository) error {
+	searcher, ok := repo.(store.Searcher)
+	if !ok {
+		return fmt.Errorf("%T 没有实现 store.Searcher", repo)
+	}
+	c := &checker{repo: repo, prefix: fmt.Sprintf("sr%x", time.Now().UnixNano()%0xffffff)}
+	defer c.cleanup(ctx)
+
+	persons := []struct{ key, name, address string }{
+		{"a", "张三丰", "北京市朝阳区"},
+		{"b", "李四", "上海市张江镇"},
+		{"c", "王五", "北京市海淀区"},
+		{"d", "Alice", "Hangzhou <West> Lake"},
+	}
+	for _, p := range persons {
+		if _, err := c.createNamed(ctx, p.key, p.name, 30, p.address); err != nil {
+			return err
+		}
+	}
+	search := func(query string, limit int) (*store.SearchResult, error) {
+		return searcher.Search(ctx, store.SearchOptions{Query: c.prefix + " " + query, Limit: limit})
+	}
+
+	cases := []struct {
+		name string
+		run  func() error
+	}{
+		{"用户名和地址", func() error {
+			result, err := search("张", 0)
+			if err != nil {
+				return err
+			}
+			return c.expectHits(result, "a", "b")
+		}},
+		{"多个词同时匹配", func() error {
+			result, err := search("北京 海淀", 0)
+			if err != nil {
+				return err
+			}
+			return c.expectHits(result, "c")
+		}},
+		{"不区分大小写", func() error {
+			result, err := search("ALICE", 0)
+			if err != nil {
+				return err
+			}
+			return c.expectHits(result, "d")
+		}},
+		{"高亮", func() error {
+			result, err := search("朝阳 三丰", 0)
+			if err != nil {
+				return err
+			}
+			if err := c.expectHits(result, "a"); err != nil {
+				return err
+			}
+			hit := result.Items[0].Highlights
+			if hit["name"] != "<em>"+c.prefix+"</em>张<em>三丰</em>" || hit["address"] != "北京市<em>朝阳</em>区" {
+				return fmt.Errorf("高亮为 %v", hit)
+			}
+			result, err = search("west", 0)
+			if err != nil {
+				return err
+			}
+			if err := c.expectHits(result, "d"); err != nil {
+				return err
+			}
+			if got := result.Items[0].Highlights["address"]; got != "Hangzhou &lt;<em>West</em>&gt; Lake" {
+				return fmt.Errorf("高亮没有转义HTML: %s", got)
+			}
+			return nil
+		}},
+		{"分页", func() error {
+			result, err := search("市", 1)
+			if err != nil {
+				return err
+			}
+			if result.Total != 3 || len(result.Items) != 1 {
+				return fmt.Errorf("total=%d items=%d，期望3和1", result.Total, len(result.Items))
+			}
+			return nil
+		}},
+		{"排除已删除", func() error {
+			if err := repo.Delete(ctx, c.id("c")); err != nil {
+				return err
+			}
+			result, err := search("北京", 0)
+			if err != nil {
+				return err
+			}
+			return c.expectHits(result, "a")
+		}},
+		{"更新后检索", func() error {
+			p, err := repo.Get(ctx, c.id("b"))
+			if err != nil {
+				return err
+			}
+			p.Address = "广州市天河区"
+			if err := repo.Update(ctx, p); err != nil {
+				return err
+			}
+			result, err := search("天河", 0)
+			if err != nil {
+				return err
+			}
+			return c.expectHits(result, "b")
+		}},
+		{"空关键字", func() error {
+			_, err := searcher.Search(ctx, store.SearchOptions{Query: " ,. "})
+			return expectErr("Search", err, store.ErrInvalidQuery)
+		}},
+	}
+
+	var errs []error
+	for _, tc := range cases {
+		if err := tc.run(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tc.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expectHits 检查检索结果的id集合，不检查顺序
+func (c *checker) expectHits(result *store.SearchResult, keys ...string) error {
+	var got []string
+	for _, hit := range result.Items {
+		got = append(got, hit.UserId)
+	}
+	slices.Sort(got)
+	want := c.ids(keys)
+	if !slices.Equal(got, want) || result.Total != len(want) {
+		return fmt.Errorf("结果为 %s(total=%d)，期望 %s", strings.Join(got, ","), result.Total, strings.Join(want, ","))
+	}
+	return nil
+}
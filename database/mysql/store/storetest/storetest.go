@@ -0,0 +1,392 @@
+// Package storetest 是 store.PersonRepository 的一致性检查
+//
+// 内存、MySQL、PostgreSQL、SQLite 等实现对同样的操作必须得到同样的结果，
+// 新增实现或修改SQL后，用 TestPersonRepository 检查行为是否与其它实现一致
+// go test ./database/mysql/store/storetest 对内存实现和 dbtest 创建的库(默认SQLite，
+// DBTEST_DRIVER=mysql/postgres 时使用对应的服务器)执行全部检查
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"Gocommunity/database/mysql/secret"
+	"Gocommunity/database/mysql/store"
+)
+
+// ============================= 1. 入口 ====================
+// TestPersonRepository 在repo上执行一致性检查，返回所有不符合预期的结果，全部通过时返回nil
+// 与 testing/fstest.TestFS 类似，既可以在测试中调用，也可以在命令中调用(见 cmd/conformance)
+//
+// 写入的记录id和用户名都带有本次运行的随机前缀，列表查询按前缀过滤，
+// 所以可以在已有数据的库上运行；实现了SoftDeleter时结束后会彻底删除写入的记录
+func TestPersonRepository(ctx context.Context, repo store.PersonRepository) error {
+	c := &checker{repo: repo, prefix: fmt.Sprintf("st%x", time.Now().UnixNano()%0xffffff)}
+	defer c.cleanup(ctx)
+
+	cases := []struct {
+		name string
+		run  func(context.Context) error
+	}{
+		{"创建和查询", c.createAndGet},
+		{"主键冲突", c.duplicateKey},
+		{"乐观锁更新", c.updateVersion},
+		{"字段校验", c.validation},
+		{"过滤和计数", c.listFilters},
+		{"游标分页", c.listCursor},
+		{"软删除", c.softDelete},
+		{"批量写入", c.batchInsert},
+	}
+	var errs []error
+	for _, tc := range cases {
+		if err := tc.run(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tc.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checker 保存一次检查过程中写入的记录，用于结束时清理
+type checker struct {
+	repo    store.PersonRepository
+	prefix  string
+	created []string
+}
+
+// id 生成带本次运行前缀的id
+func (c *checker) id(name string) string {
+	return c.prefix + "-" + name
+}
+
+// create 写入一条记录并登记，用户名与id使用相同的key
+func (c *checker) create(ctx context.Context, key string, age int, address string) (*store.Person, error) {
+	return c.createNamed(ctx, key, key, age, address)
+}
+
+// createNamed 用户名同样带前缀以便按前缀过滤
+// id只使用字母和数字，避免不同数据库对标点符号的排序规则不同
+func (c *checker) createNamed(ctx context.Context, key, name string, age int, address string) (*store.Person, error) {
+	p := &store.Person{UserId: c.id(key), Username: c.prefix + name, Age: age, Address: secret.String(address)}
+	if err := c.repo.Create(ctx, p); err != nil {
+		return nil, fmt.Errorf("创建 %s 失败: %w", p.UserId, err)
+	}
+	c.created = append(c.created, p.UserId)
+	return p, nil
+}
+
+// cleanup 软删除并彻底删除写入的记录，不支持SoftDeleter时只做软删除
+func (c *checker) cleanup(ctx context.Context) {
+	deleter, _ := c.repo.(store.SoftDeleter)
+	for _, id := range c.created {
+		c.repo.Delete(ctx, id)
+		if deleter != nil {
+			deleter.Purge(ctx, id)
+		}
+	}
+}
+
+// expectErr 检查err是否归类为want
+func expectErr(op string, err, want error) error {
+	if !errors.Is(err, want) {
+		return fmt.Errorf("%s: 期望错误 %v，实际为 %v", op, want, err)
+	}
+	return nil
+}
+
+// ============================= 2. 增删改查 ====================
+func (c *checker) createAndGet(ctx context.Context) error {
+	before := time.Now().Add(-time.Second)
+	created, err := c.create(ctx, "get", 30, "北京市海淀区")
+	if err != nil {
+		return err
+	}
+	if created.Version != 1 || created.CreatedAt.IsZero() || !created.CreatedAt.Equal(created.UpdatedAt) {
+		return fmt.Errorf("Create未设置审计字段: version=%d created_at=%v updated_at=%v",
+			created.Version, created.CreatedAt, created.UpdatedAt)
+	}
+
+	got, err := c.repo.Get(ctx, created.UserId)
+	if err != nil {
+		return fmt.Errorf("查询刚创建的记录失败: %w", err)
+	}
+	if got.UserId != created.UserId || got.Username != created.Username || got.Age != created.Age || got.Address != created.Address {
+		return fmt.Errorf("查询结果与写入不一致: %+v != %+v", *got, *created)
+	}
+	if got.Version != 1 || got.DeletedAt != nil {
+		return fmt.Errorf("查询结果的version=%d deleted_at=%v，期望1和nil", got.Version, got.DeletedAt)
+	}
+	if !got.CreatedAt.Equal(created.CreatedAt) || got.CreatedAt.Before(before) {
+		return fmt.Errorf("created_at读写不一致: 写入%v，读取%v", created.CreatedAt, got.CreatedAt)
+	}
+
+	_, err = c.repo.Get(ctx, c.id("missing"))
+	return expectErr("查询不存在的记录", err, store.ErrNotFound)
+}
+
+func (c *checker) duplicateKey(ctx context.Context) error {
+	if _, err := c.create(ctx, "dup", 20, ""); err != nil {
+		return err
+	}
+	err := c.repo.Create(ctx, &store.Person{UserId: c.id("dup"), Username: c.prefix + "dup2", Age: 21})
+	return expectErr("重复创建", err, store.ErrDuplicateKey)
+}
+
+func (c *checker) updateVersion(ctx context.Context) error {
+	p, err := c.create(ctx, "upd", 40, "上海市")
+	if err != nil {
+		return err
+	}
+	stale := *p
+
+	p.Age, p.Address = 41, "上海市浦东新区"
+	if err := c.repo.Update(ctx, p); err != nil {
+		return fmt.Errorf("按当前版本更新失败: %w", err)
+	}
+	if p.Version != 2 {
+		return fmt.Errorf("更新后version=%d，期望2", p.Version)
+	}
+	got, err := c.repo.Get(ctx, p.UserId)
+	if err != nil {
+		return err
+	}
+	if got.Age != 41 || got.Address != "上海市浦东新区" || got.Version != 2 || !got.CreatedAt.Equal(stale.CreatedAt) {
+		return fmt.Errorf("更新结果不正确: %+v", *got)
+	}
+
+	stale.Age = 99
+	if err := expectErr("使用旧版本更新", c.repo.Update(ctx, &stale), store.ErrVersionConflict); err != nil {
+		return err
+	}
+	missing := store.Person{UserId: c.id("missing"), Username: "x", Version: 1}
+	return expectErr("更新不存在的记录", c.repo.Update(ctx, &missing), store.ErrNotFound)
+}
+
+// validation 不满足表约束的记录在写入前被拒绝，数据库中的记录保持不变
+func (c *checker) validation(ctx context.Context) error {
+	invalid := &store.Person{UserId: c.id("inv"), Username: "", Age: 20}
+	if err := expectErr("创建空用户名", c.repo.Create(ctx, invalid), store.ErrConstraint); err != nil {
+		return err
+	}
+
+	p, err := c.create(ctx, "val", 20, "")
+	if err != nil {
+		return err
+	}
+	for _, bad := range []store.Person{
+		{Username: p.Username, Age: -1},
+		{Username: p.Username, Age: store.MaxAge + 1},
+		{Username: p.Username, Age: 20, Address: secret.String(strings.Repeat("址", store.MaxAddressLength+1))},
+	} {
+		bad.UserId, bad.Version = p.UserId, p.Version
+		if err := expectErr("更新为非法值", c.repo.Update(ctx, &bad), store.ErrConstraint); err != nil {
+			return err
+		}
+	}
+	got, err := c.repo.Get(ctx, p.UserId)
+	if err != nil {
+		return err
+	}
+	if got.Age != 20 || got.Version != 1 {
+		return fmt.Errorf("非法更新修改了记录: %+v", *got)
+	}
+	return nil
+}
+
+// ============================= 3. 列表查询 ====================
+// listFilters 名称前缀中的%和_按普通字符匹配，年龄范围包含边界
+func (c *checker) listFilters(ctx context.Context) error {
+	for _, p := range []struct {
+		key, name string
+		age       int
+		address   string
+	}{
+		{"fa", "f_a", 18, "杭州市西湖区"},
+		{"fb", "f_b", 25, "杭州市滨江区"},
+		{"fc", "fxc", 32, "南京市"},
+		{"fd", "f%d", 60, "杭州市"},
+	} {
+		if _, err := c.createNamed(ctx, p.key, p.name, p.age, p.address); err != nil {
+			return err
+		}
+	}
+
+	minAge, maxAge := 18, 32
+	cases := []struct {
+		name string
+		opts store.ListOptions
+		want []string
+	}{
+		{"前缀含下划线", store.ListOptions{NamePrefix: c.prefix + "f_"}, []string{"fa", "fb"}},
+		{"前缀含百分号", store.ListOptions{NamePrefix: c.prefix + "f%"}, []string{"fd"}},
+		{"年龄范围", store.ListOptions{NamePrefix: c.prefix + "f", MinAge: &minAge, MaxAge: &maxAge}, []string{"fa", "fb", "fc"}},
+		{"地址包含", store.ListOptions{NamePrefix: c.prefix + "f", AddressContains: "杭州"}, []string{"fa", "fb", "fd"}},
+		{"按ID", store.ListOptions{ID: c.id("fb"), NamePrefix: c.prefix + "f"}, []string{"fb"}},
+	}
+	for _, tc := range cases {
+		page, err := c.repo.List(ctx, tc.opts)
+		if tc.opts.AddressContains != "" && errors.Is(err, store.ErrInvalidQuery) {
+			continue // 地址加密存储时仓储可以拒绝按地址筛选
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", tc.name, err)
+		}
+		if err := c.expectIDs(tc.name, page, tc.want); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listCursor 按年龄倒序逐页读取，拼起来应与一次读取全部的结果相同，年龄相同时按id排序
+func (c *checker) listCursor(ctx context.Context) error {
+	for i, age := range []int{50, 51, 51, 52, 53} {
+		if _, err := c.create(ctx, fmt.Sprintf("p%d", i), age, ""); err != nil {
+			return err
+		}
+	}
+	want := []string{"p4", "p3", "p2", "p1", "p0"}
+
+	opts := store.ListOptions{NamePrefix: c.prefix + "p", SortBy: "age", Desc: true, Limit: 2}
+	var ids []string
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			return fmt.Errorf("游标分页没有结束")
+		}
+		page, err := c.repo.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if page.Total != len(want) {
+			return fmt.Errorf("第%d页的总数为%d，期望%d", pages+1, page.Total, len(want))
+		}
+		for _, p := range page.Items {
+			ids = append(ids, p.UserId)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	// 年龄同为51的p1和p2按id倒序
+	if !slices.Equal(ids, c.ids(want)) {
+		return fmt.Errorf("分页结果 %v，期望 %v", ids, c.ids(want))
+	}
+	return nil
+}
+
+// expectIDs 比较列表结果(按id升序)和总数
+func (c *checker) expectIDs(op string, page *store.PersonPage, want []string) error {
+	got := make([]string, 0, len(page.Items))
+	for _, p := range page.Items {
+		got = append(got, p.UserId)
+	}
+	if !slices.Equal(got, c.ids(want)) || page.Total != len(want) {
+		return fmt.Errorf("%s: 结果 %v (总数%d)，期望 %v", op, got, page.Total, c.ids(want))
+	}
+	return nil
+}
+
+func (c *checker) ids(names []string) []string {
+	ids := make([]string, len(names))
+	for i, name := range names {
+		ids[i] = c.id(name)
+	}
+	return ids
+}
+
+// ============================= 4. 软删除 ====================
+func (c *checker) softDelete(ctx context.Context) error {
+	p, err := c.create(ctx, "del", 70, "")
+	if err != nil {
+		return err
+	}
+	if err := c.repo.Delete(ctx, p.UserId); err != nil {
+		return fmt.Errorf("删除失败: %w", err)
+	}
+	_, err = c.repo.Get(ctx, p.UserId)
+	if err := expectErr("查询已删除的记录", err, store.ErrNotFound); err != nil {
+		return err
+	}
+	if err := expectErr("重复删除", c.repo.Delete(ctx, p.UserId), store.ErrNotFound); err != nil {
+		return err
+	}
+	page, err := c.repo.List(ctx, store.ListOptions{NamePrefix: c.prefix + "del", Deleted: store.OnlyDeleted})
+	if err != nil {
+		return err
+	}
+	if err := c.expectIDs("查询回收站", page, []string{"del"}); err != nil {
+		return err
+	}
+	if page.Items[0].DeletedAt == nil {
+		return fmt.Errorf("回收站中的记录deleted_at为空")
+	}
+
+	deleter, ok := c.repo.(store.SoftDeleter)
+	if !ok {
+		return nil
+	}
+	if err := deleter.Restore(ctx, p.UserId); err != nil {
+		return fmt.Errorf("恢复失败: %w", err)
+	}
+	if _, err := c.repo.Get(ctx, p.UserId); err != nil {
+		return fmt.Errorf("恢复后查询失败: %w", err)
+	}
+	if err := expectErr("彻底删除未软删除的记录", deleter.Purge(ctx, p.UserId), store.ErrNotFound); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ============================= 5. 批量写入 ====================
+// batchInsert 只比较写入后的数据，不比较影响行数(各数据库的计数规则不同)
+func (c *checker) batchInsert(ctx context.Context) error {
+	writer, ok := c.repo.(store.BatchWriter)
+	if !ok {
+		return nil
+	}
+
+	persons := make([]store.Person, 5)
+	for i := range persons {
+		persons[i] = store.Person{UserId: c.id(fmt.Sprintf("b%d", i)), Username: fmt.Sprintf("%sb%d", c.prefix, i), Age: 10 + i,
+			Address: secret.String(fmt.Sprintf("杭州市%d号", i))}
+		c.created = append(c.created, persons[i].UserId)
+	}
+	if _, err := writer.BatchInsert(ctx, persons, store.BatchOptions{BatchSize: 2}); err != nil {
+		return fmt.Errorf("批量插入失败: %w", err)
+	}
+	_, err := writer.BatchInsert(ctx, persons[:1], store.BatchOptions{})
+	if err := expectErr("批量插入重复主键", err, store.ErrDuplicateKey); err != nil {
+		return err
+	}
+
+	// upsert: b0不变，b1修改年龄，b9为新记录
+	// 地址加密存储时每次加密的密文都不同，b0的版本号仍然不能变化
+	upsert := []store.Person{
+		{UserId: c.id("b0"), Username: c.prefix + "b0", Age: 10, Address: "杭州市0号"},
+		{UserId: c.id("b1"), Username: c.prefix + "b1", Age: 99, Address: "杭州市1号"},
+		{UserId: c.id("b9"), Username: c.prefix + "b9", Age: 19},
+	}
+	c.created = append(c.created, c.id("b9"))
+	if _, err := writer.BatchInsert(ctx, upsert, store.BatchOptions{Upsert: true}); err != nil {
+		return fmt.Errorf("批量upsert失败: %w", err)
+	}
+	for _, want := range []struct {
+		name    string
+		age     int
+		version int64
+	}{{"b0", 10, 1}, {"b1", 99, 2}, {"b9", 19, 1}} {
+		got, err := c.repo.Get(ctx, c.id(want.name))
+		if err != nil {
+			return fmt.Errorf("查询 %s 失败: %w", want.name, err)
+		}
+		if got.Age != want.age || got.Version != want.version {
+			return fmt.Errorf("upsert后 %s 的age=%d version=%d，期望%d和%d", want.name, got.Age, got.Version, want.age, want.version)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package storetest_test
+
+import (
+	"testing"
+
+	"Gocommunity/database/mysql/cache"
+	"Gocommunity/database/mysql/dbtest"
+	"Gocommunity/database/mysql/secret"
+	"Gocommunity/database/mysql/store"
+	"Gocommunity/database/mysql/store/storetest"
+	"Gocommunity/database/mysql/tenant"
+)
+
+// 数据库由 dbtest 创建: 默认是进程内的SQLite，CI中无需任何服务；
+// 设置 DBTEST_DRIVER=mysql 或 postgres 时按 DB_* 连接服务器，在临时库中执行同样的检查
+
+// namedRepository 一个被检查的实现，name用作子测试名
+type namedRepository struct {
+	name string
+	repo store.PersonRepository
+}
+
+func TestPersonRepository(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	sqlRepo := store.NewSQLPersonRepository(db)
+
+	for _, r := range []namedRepository{
+		{"memory", store.NewMemoryPersonRepository()},
+		// 缓存层必须对调用方透明，外部缓存使用本地替身
+		{"cached(memory)", store.NewCachedPersonRepository(store.NewMemoryPersonRepository(),
+			store.WithExternalCache(cache.NewLocal(0), store.DefaultStoreTTL))},
+		{"sql", sqlRepo},
+		{"cached(sql)", store.NewCachedPersonRepository(sqlRepo)},
+	} {
+		t.Run(r.name, func(t *testing.T) {
+			if err := storetest.TestPersonRepository(t.Context(), r.repo); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	scoped := store.NewSQLPersonRepository(db, store.WithTenantScope())
+	router := store.NewTenantRouter(store.MemoryPerTenant())
+	t.Cleanup(func() { router.Close() })
+
+	for _, r := range []namedRepository{
+		{"router(memory)", router},
+		{"scoped(sql)", scoped},
+		{"cached(scoped(sql))", store.NewCachedPersonRepository(scoped)},
+	} {
+		t.Run(r.name, func(t *testing.T) {
+			if err := storetest.TestPersonRepository(tenant.NewContext(t.Context(), "st_a"), r.repo); err != nil {
+				t.Error(err)
+			}
+			if err := storetest.TestTenantIsolation(t.Context(), r.repo, "st_a", "st_b"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+
+	for _, r := range []namedRepository{
+		{"searchable(memory)", store.NewSearchablePersonRepository(store.NewMemoryPersonRepository())},
+		// MySQL上使用迁移0008的FULLTEXT索引，其它数据库使用进程内索引
+		{"searchable(sql)", store.NewSearchablePersonRepository(store.NewSQLPersonRepository(db))},
+	} {
+		t.Run(r.name, func(t *testing.T) {
+			if err := storetest.TestPersonRepository(t.Context(), r.repo); err != nil {
+				t.Error(err)
+			}
+			if err := storetest.TestSearch(t.Context(), r.repo); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestEncrypted 地址加密存储时执行同样的检查，包括upsert相同的数据不修改版本号
+// 密钥环是进程级的，这个测试不能与其它测试并行
+func TestEncrypted(t *testing.T) {
+	keyring, err := secret.NewKeyring("test", map[string][]byte{"test": make([]byte, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := dbtest.New(t)
+	secret.SetKeyring(keyring)
+	t.Cleanup(func() { secret.SetKeyring(nil) })
+
+	for _, r := range []namedRepository{
+		{"memory", store.NewMemoryPersonRepository()},
+		{"sql", store.NewSQLPersonRepository(db)},
+	} {
+		t.Run(r.name, func(t *testing.T) {
+			if err := storetest.TestPersonRepository(t.Context(), r.repo); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
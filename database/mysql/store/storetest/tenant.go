@@ -0,0 +1,127 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Gocommunity/database/mysql/store"
+	"Gocommunity/database/mysql/tenant"
+)
+
+// ============================= 租户隔离 ====================
+// TestTenantIsolation 检查repo按context中的租户隔离数据，a和b是两个不同的租户ID
+// repo是启用了 store.WithTenantScope 的仓储或 store.TenantRouter；
+// ctx中不能带有租户，检查会分别为a和b设置租户
+func TestTenantIsolation(ctx context.Context, repo store.PersonRepository, a, b string) error {
+	ctxA, ctxB := tenant.NewContext(ctx, a), tenant.NewContext(ctx, b)
+	prefix := fmt.Sprintf("tt%x", time.Now().UnixNano()%0xffffff)
+	id := prefix + "-same"
+	defer func() {
+		deleter, _ := repo.(store.SoftDeleter)
+		for _, ctx := range []context.Context{ctxA, ctxB} {
+			repo.Delete(ctx, id)
+			if deleter != nil {
+				deleter.Purge(ctx, id)
+			}
+		}
+	}()
+
+	cases := []struct {
+		name string
+		run  func() error
+	}{
+		{"未指定租户", func() error {
+			_, err := repo.Get(ctx, id)
+			if err := expectErr("Get", err, tenant.ErrMissing); err != nil {
+				return err
+			}
+			_, err = repo.List(ctx, store.ListOptions{NamePrefix: prefix})
+			if err := expectErr("List", err, tenant.ErrMissing); err != nil {
+				return err
+			}
+			return expectErr("Create", repo.Create(ctx, &store.Person{UserId: id, Username: prefix}), tenant.ErrMissing)
+		}},
+		{"相同ID", func() error {
+			if err := repo.Create(ctxA, &store.Person{UserId: id, Username: prefix + "a", Age: 20}); err != nil {
+				return fmt.Errorf("租户%s创建失败: %w", a, err)
+			}
+			if err := repo.Create(ctxB, &store.Person{UserId: id, Username: prefix + "b", Age: 30}); err != nil {
+				return fmt.Errorf("租户%s创建相同ID失败: %w", b, err)
+			}
+			return expectAge(ctxA, repo, id, 20)
+		}},
+		{"更新", func() error {
+			p, err := repo.Get(ctxB, id)
+			if err != nil {
+				return err
+			}
+			p.Age = 31
+			if err := repo.Update(ctxB, p); err != nil {
+				return err
+			}
+			if err := expectAge(ctxA, repo, id, 20); err != nil {
+				return fmt.Errorf("更新租户%s影响了租户%s: %w", b, a, err)
+			}
+			return expectAge(ctxB, repo, id, 31)
+		}},
+		{"列表", func() error {
+			page, err := repo.List(ctxA, store.ListOptions{NamePrefix: prefix})
+			if err != nil {
+				return err
+			}
+			if page.Total != 1 || len(page.Items) != 1 || page.Items[0].Username != prefix+"a" {
+				return fmt.Errorf("租户%s的列表包含其他租户的数据: total=%d items=%v", a, page.Total, page.Items)
+			}
+			return nil
+		}},
+		{"批量upsert", func() error {
+			writer, ok := repo.(store.BatchWriter)
+			if !ok {
+				return nil
+			}
+			_, err := writer.BatchInsert(ctxA, []store.Person{{UserId: id, Username: prefix + "a", Age: 21}}, store.BatchOptions{Upsert: true})
+			if err != nil {
+				return err
+			}
+			if err := expectAge(ctxB, repo, id, 31); err != nil {
+				return fmt.Errorf("租户%s的upsert影响了租户%s: %w", a, b, err)
+			}
+			return expectAge(ctxA, repo, id, 21)
+		}},
+		{"删除", func() error {
+			if err := repo.Delete(ctxA, id); err != nil {
+				return err
+			}
+			_, err := repo.Get(ctxA, id)
+			if err := expectErr("查询已删除的记录", err, store.ErrNotFound); err != nil {
+				return err
+			}
+			if _, err := repo.Get(ctxB, id); err != nil {
+				return fmt.Errorf("删除租户%s的记录影响了租户%s: %w", a, b, err)
+			}
+			return nil
+		}},
+	}
+
+	var errs []error
+	for _, tc := range cases {
+		if err := tc.run(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tc.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expectAge 检查当前租户中记录的年龄
+func expectAge(ctx context.Context, repo store.PersonRepository, id string, age int) error {
+	p, err := repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if p.Age != age {
+		return fmt.Errorf("age为%d，期望%d", p.Age, age)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"Gocommunity/database/mysql/cache"
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/tenant"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ============================= 1. 共享表的租户隔离 ====================
+// tenantColumn user表中区分租户的列
+const tenantColumn = "tenant_id"
+
+// WithTenantScope 所有租户共用user表，按context中的租户隔离数据(见 tenant 包)，需要先执行迁移添加tenant_id列
+//
+//   - 所有读写都带 tenant_id 条件，插入时写入context中的租户，不同租户可以使用相同的用户ID
+//   - context中没有租户时拒绝执行，返回 tenant.ErrMissing
+//   - Query 返回的构造器要求限定租户，Find和Count自动填入context中的租户；
+//     传入 Dialect.Persons 等未限定租户的构造器时返回 query.ErrInvalid
+//   - 变更事件的内容增加tenant_id字段
+//
+// ReencryptAddresses 是运维任务，处理表中所有租户的数据
+func WithTenantScope() SQLOption {
+	return func(r *SQLPersonRepository) { r.tenantScoped = true }
+}
+
+// WithSchema 访问指定schema(MySQL中即数据库)中的user表，用于每个租户一个schema的部署，见 SchemaPerTenant
+// schema只能包含字母、数字和下划线；outbox表仍使用连接默认的schema
+func WithSchema(schema string) SQLOption {
+	return func(r *SQLPersonRepository) { r.schema = schema }
+}
+
+// tenant 启用租户隔离时返回context中的租户，否则返回空字符串
+func (r *SQLPersonRepository) tenant(ctx context.Context) (string, error) {
+	if !r.tenantScoped {
+		return "", nil
+	}
+	return tenant.FromContext(ctx)
+}
+
+// whereID 按ID定位一条记录的条件和参数，启用租户隔离时加上租户条件
+func (r *SQLPersonRepository) whereID(tenantID, id string) (string, []any) {
+	if !r.tenantScoped {
+		return "id = ?", []any{id}
+	}
+	return tenantColumn + " = ? AND id = ?", []any{tenantID, id}
+}
+
+// tenantPerson 带租户ID的Person，作为插入语句的命名参数和变更事件的内容
+type tenantPerson struct {
+	Person
+	TenantID string `db:"tenant_id" json:"tenant_id"`
+}
+
+// row 插入一条记录时的命名参数
+func (r *SQLPersonRepository) row(tenantID string, p *Person) any {
+	if !r.tenantScoped {
+		return p
+	}
+	return tenantPerson{Person: *p, TenantID: tenantID}
+}
+
+// rows 批量插入时的命名参数
+func (r *SQLPersonRepository) rows(tenantID string, persons []Person) any {
+	if !r.tenantScoped {
+		return persons
+	}
+	rows := make([]tenantPerson, len(persons))
+	for i := range persons {
+		rows[i] = tenantPerson{Person: persons[i], TenantID: tenantID}
+	}
+	return rows
+}
+
+// ============================= 2. 按租户路由 ====================
+// TenantOpener 为租户创建仓储，closer在路由关闭时调用，不需要关闭时返回nil
+type TenantOpener func(ctx context.Context, tenantID string) (repo PersonRepository, closer func() error, err error)
+
+// ErrRouterClosed 租户路由已经关闭
+var ErrRouterClosed = errors.New("租户路由已关闭")
+
+// TenantRouter 按context中的租户把请求转发给该租户独立的仓储，是共享表(WithTenantScope)之外的另一种隔离方式
+// 仓储在租户第一次访问时创建并一直保留，创建失败时下次访问会重试
+// context中没有租户时返回 tenant.ErrMissing
+type TenantRouter struct {
+	open   TenantOpener
+	flight cache.Group[PersonRepository]
+
+	mu      sync.RWMutex
+	repos   map[string]PersonRepository
+	closers []func() error
+	closed  bool
+}
+
+var (
+	_ PersonRepository = (*TenantRouter)(nil)
+	_ SoftDeleter      = (*TenantRouter)(nil)
+	_ BatchWriter      = (*TenantRouter)(nil)
+	_ Searcher         = (*TenantRouter)(nil)
+)
+
+// NewTenantRouter 创建路由，open为每个租户创建仓储
+func NewTenantRouter(open TenantOpener) *TenantRouter {
+	return &TenantRouter{open: open, repos: make(map[string]PersonRepository)}
+}
+
+// SchemaPerTenant 所有租户共用db的连接池，每个租户的user表在名为 prefix+租户ID 的schema中
+// schema中的表结构需要单独迁移: MySQL中schema就是数据库，用DB_NAME指向它执行 cmd/migrate；
+// PostgreSQL可以在连接参数中设置search_path
+func SchemaPerTenant(db sqlx.ExtContext, prefix string, opts ...SQLOption) TenantOpener {
+	return func(ctx context.Context, tenantID string) (PersonRepository, func() error, error) {
+		schema := prefix + tenantID
+		for _, c := range schema {
+			if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' {
+				return nil, nil, fmt.Errorf("%w: schema名 %q 只能包含字母、数字和下划线", tenant.ErrInvalid, schema)
+			}
+		}
+		options := append(opts[:len(opts):len(opts)], WithSchema(schema))
+		return NewSQLPersonRepository(db, options...), nil, nil
+	}
+}
+
+// DatabasePerTenant 每个租户使用独立的数据库和连接池，configFor返回租户的连接配置
+// 仓储选项由 ConfigOptions(cfg) 和opts组成，连接在路由关闭时关闭
+// 加密密钥环是进程级的，由主配置的 dbx.Open 设置；租户配置带有 EncryptionKeys 时返回错误，
+// 否则最后打开的租户会替换所有租户使用的密钥
+func DatabasePerTenant(configFor func(tenantID string) (dbx.Config, error), opts ...SQLOption) TenantOpener {
+	return func(ctx context.Context, tenantID string) (PersonRepository, func() error, error) {
+		cfg, err := configFor(tenantID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cfg.EncryptionKeys != "" {
+			return nil, nil, fmt.Errorf("租户 %s 的数据库配置不能设置加密密钥，所有租户共用主配置的密钥环", tenantID)
+		}
+		db, err := dbx.Connect(ctx, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		options := append(ConfigOptions(cfg), opts...)
+		return NewSQLPersonRepository(db, options...), db.Close, nil
+	}
+}
+
+// MemoryPerTenant 每个租户一个内存仓储，用于测试和演示
+func MemoryPerTenant() TenantOpener {
+	return func(ctx context.Context, tenantID string) (PersonRepository, func() error, error) {
+		return NewMemoryPersonRepository(), nil, nil
+	}
+}
+
+// route 返回context中租户的仓储，同一租户并发的首次访问只创建一次
+func (r *TenantRouter) route(ctx context.Context) (PersonRepository, error) {
+	id, err := tenant.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if repo, ok := r.lookup(id); ok {
+		return repo, nil
+	}
+
+	repo, err, _ := r.flight.Do(ctx, id, func(ctx context.Context) (PersonRepository, error) {
+		if repo, ok := r.lookup(id); ok {
+			return repo, nil
+		}
+		repo, closer, err := r.open(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("打开租户 %s 的仓储失败: %w", id, err)
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.closed {
+			if closer != nil {
+				closer()
+			}
+			return nil, ErrRouterClosed
+		}
+		r.repos[id] = repo
+		if closer != nil {
+			r.closers = append(r.closers, closer)
+		}
+		return repo, nil
+	})
+	return repo, err
+}
+
+func (r *TenantRouter) lookup(id string) (PersonRepository, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	repo, ok := r.repos[id]
+	return repo, ok
+}
+
+// Tenants 返回已经创建了仓储的租户数量
+func (r *TenantRouter) Tenants() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.repos)
+}
+
+// Close 关闭所有租户的连接，之后的访问返回ErrRouterClosed
+func (r *TenantRouter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	var errs []error
+	for _, closer := range r.closers {
+		errs = append(errs, closer())
+	}
+	clear(r.repos)
+	r.closers = nil
+	return errors.Join(errs...)
+}
+
+func (r *TenantRouter) Get(ctx context.Context, id string) (*Person, error) {
+	repo, err := r.route(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	return repo.Get(ctx, id)
+}
+
+func (r *TenantRouter) List(ctx context.Context, opts ListOptions) (*PersonPage, error) {
+	repo, err := r.route(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("查询用户列表失败: %w", err)
+	}
+	return repo.List(ctx, opts)
+}
+
+func (r *TenantRouter) Create(ctx context.Context, p *Person) error {
+	repo, err := r.route(ctx)
+	if err != nil {
+		return fmt.Errorf("插入用户失败: %w", err)
+	}
+	return repo.Create(ctx, p)
+}
+
+func (r *TenantRouter) Update(ctx context.Context, p *Person) error {
+	repo, err := r.route(ctx)
+	if err != nil {
+		return fmt.Errorf("更新用户失败: %w", err)
+	}
+	return repo.Update(ctx, p)
+}
+
+func (r *TenantRouter) Delete(ctx context.Context, id string) error {
+	repo, err := r.route(ctx)
+	if err != nil {
+		return fmt.Errorf("删除用户失败: %w", err)
+	}
+	return repo.Delete(ctx, id)
+}
+
+// Restore 租户的仓储不支持软删除恢复时返回 errors.ErrUnsupported
+func (r *TenantRouter) Restore(ctx context.Context, id string) error {
+	repo, err := r.route(ctx)
+	if err != nil {
+		return fmt.Errorf("恢复用户失败: %w", err)
+	}
+	deleter, ok := repo.(SoftDeleter)
+	if !ok {
+		return fmt.Errorf("恢复用户失败: %w", errors.ErrUnsupported)
+	}
+	return deleter.Restore(ctx, id)
+}
+
+func (r *TenantRouter) Purge(ctx context.Context, id string) error {
+	repo, err := r.route(ctx)
+	if err != nil {
+		return fmt.Errorf("彻底删除用户失败: %w", err)
+	}
+	deleter, ok := repo.(SoftDeleter)
+	if !ok {
+		return fmt.Errorf("彻底删除用户失败: %w", errors.ErrUnsupported)
+	}
+	return deleter.Purge(ctx, id)
+}
+
+// BatchInsert 租户的仓储不支持批量写入时返回 errors.ErrUnsupported
+func (r *TenantRouter) BatchInsert(ctx context.Context, persons []Person, opts BatchOptions) (*BatchResult, error) {
+	repo, err := r.route(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("批量写入失败: %w", err)
+	}
+	writer, ok := repo.(BatchWriter)
+	if !ok {
+		return nil, fmt.Errorf("批量写入失败: %w", errors.ErrUnsupported)
+	}
+	return writer.BatchInsert(ctx, persons, opts)
+}
+
+// Search 租户的仓储不支持检索时返回 ErrSearchUnavailable
+func (r *TenantRouter) Search(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	repo, err := r.route(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("检索用户失败: %w", err)
+	}
+	searcher, ok := repo.(Searcher)
+	if !ok {
+		return nil, fmt.Errorf("检索用户失败: %w", ErrSearchUnavailable)
+	}
+	return searcher.Search(ctx, opts)
+}
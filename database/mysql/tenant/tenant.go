@@ -0,0 +1,149 @@
+// Package tenant 在context中传递当前请求所属的租户
+//
+// HTTP层通过 Middleware 确定请求所属的租户并放入context，仓储从context中读取，
+// 业务代码不需要显式传递租户ID，也就不会因为漏传而访问到其他租户的数据
+//
+// 隔离的强度取决于租户ID从哪里来。Middleware 需要调用方提供 Resolver:
+//
+//   - PrincipalResolver 按认证中间件确认过的身份查出所属租户，客户端无法选择租户，生产环境应使用这种方式
+//   - HeaderResolver 直接信任请求头 X-Tenant-ID，任何调用方修改请求头就能读写任意租户的数据，
+//     只能用于本地演示，或部署在会覆盖该请求头的可信网关之后。
+//     演示服务只在环境变量 TENANT_TRUST_HEADER=true 时使用它，见 HeaderTrusted
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// Header 携带租户ID的请求头
+const Header = "X-Tenant-ID"
+
+// TrustHeaderEnv 显式开启 HeaderResolver 的环境变量
+const TrustHeaderEnv = "TENANT_TRUST_HEADER"
+
+// MaxLength 租户ID的最大长度，与user表tenant_id列的定义一致
+const MaxLength = 32
+
+var (
+	// ErrMissing context中没有租户，启用租户隔离后所有读写都必须指定租户
+	ErrMissing = errors.New("未指定租户")
+	// ErrInvalid 租户ID格式不合法
+	ErrInvalid = errors.New("租户ID不合法")
+	// ErrForbidden 已认证的身份不属于任何租户或无权访问请求的租户
+	ErrForbidden = errors.New("无权访问该租户")
+)
+
+type contextKey struct{}
+
+// ============================= 1. 租户ID ====================
+// Validate 租户ID只能包含小写字母、数字和下划线，长度1到MaxLength
+// 限制字符集后租户ID可以直接用作schema名或数据库名的一部分
+func Validate(id string) error {
+	if id == "" || len(id) > MaxLength {
+		return fmt.Errorf("%w: 长度必须在1到%d之间", ErrInvalid, MaxLength)
+	}
+	for _, c := range id {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '_' {
+			return fmt.Errorf("%w: %q 只能包含小写字母、数字和下划线", ErrInvalid, id)
+		}
+	}
+	return nil
+}
+
+// ============================= 2. context传递 ====================
+// NewContext 返回携带租户ID的context，id应已通过 Validate 校验
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext 返回context中的租户ID，没有租户时返回ErrMissing
+// 未经校验放入的ID在这里校验，不合法时返回ErrInvalid
+func FromContext(ctx context.Context) (string, error) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	if !ok {
+		return "", ErrMissing
+	}
+	if err := Validate(id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// ============================= 3. HTTP中间件 ====================
+// Resolver 确定请求所属的租户
+// 返回ErrMissing表示请求与租户无关(例如公开接口)，context中不放入租户，由仓储拒绝需要租户的读写；
+// 返回其它错误时请求被拒绝，见 Middleware
+type Resolver func(r *http.Request) (string, error)
+
+// PrincipalResolver 按已认证的身份确定租户，客户端传入的 X-Tenant-ID 不参与判断
+// principal 取出认证中间件放入请求的身份(用户ID、JWT的subject等)，未认证时返回false；
+// tenantOf 返回该身份所属的租户，身份不属于任何租户时应返回ErrForbidden
+func PrincipalResolver(principal func(r *http.Request) (string, bool), tenantOf func(ctx context.Context, principal string) (string, error)) Resolver {
+	return func(r *http.Request) (string, error) {
+		who, ok := principal(r)
+		if !ok {
+			return "", ErrMissing
+		}
+		id, err := tenantOf(r.Context(), who)
+		if err != nil {
+			return "", err
+		}
+		return id, Validate(id)
+	}
+}
+
+// HeaderResolver 直接使用请求头 X-Tenant-ID 中的租户ID，请求头不存在时返回ErrMissing
+//
+// 警告: 请求头由客户端控制，使用这个Resolver时租户之间没有任何访问控制，
+// 只能用于本地演示，或部署在会删除并重新设置该请求头的可信网关之后
+func HeaderResolver(r *http.Request) (string, error) {
+	id := r.Header.Get(Header)
+	if id == "" {
+		return "", ErrMissing
+	}
+	return id, Validate(id)
+}
+
+// HeaderTrusted 环境变量 TENANT_TRUST_HEADER 是否设置为true，未设置或无法解析时返回false
+// 使用 HeaderResolver 的服务用它作为开关，默认不信任请求头
+func HeaderTrusted() bool {
+	trusted, _ := strconv.ParseBool(os.Getenv(TrustHeaderEnv))
+	return trusted
+}
+
+// Middleware 返回将resolve确定的租户放入请求context的中间件
+// 没有租户(ErrMissing)时不做处理，这样同一个服务中与租户无关的接口不受影响；
+// 租户ID不合法时返回400，ErrForbidden返回403，其它错误返回500
+//
+//	// userID 读取认证中间件设置的用户，tenantOfUser 查询用户所属的租户
+//	handler = tenant.Middleware(tenant.PrincipalResolver(userID, tenantOfUser))(handler)
+func Middleware(resolve Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := resolve(r)
+			switch {
+			case err == nil:
+				r = r.WithContext(NewContext(r.Context(), id))
+			case errors.Is(err, ErrMissing):
+			case errors.Is(err, ErrInvalid):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			case errors.Is(err, ErrForbidden):
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			default:
+				// 查询租户失败的原因可能包含内部信息，只记录日志
+				log.Printf("确定请求的租户失败: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
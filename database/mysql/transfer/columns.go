@@ -0,0 +1,121 @@
+// Package transfer 以CSV和JSON Lines格式流式导出、导入user表，用于在环境之间迁移用户数据
+package transfer
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"Gocommunity/database/mysql/store"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// Format 导入导出的文件格式
+type Format string
+
+const (
+	CSV   Format = "csv"   // 第一行是列名(Person的db标签)，时间使用RFC3339格式
+	JSONL Format = "jsonl" // 每行一个JSON对象，字段名使用Person的json标签
+)
+
+// FormatOf 按文件扩展名推断格式，.ndjson 视为JSON Lines
+func FormatOf(name string) (Format, error) {
+	switch {
+	case strings.HasSuffix(name, ".csv"):
+		return CSV, nil
+	case strings.HasSuffix(name, ".jsonl"), strings.HasSuffix(name, ".ndjson"):
+		return JSONL, nil
+	}
+	return "", fmt.Errorf("无法从文件名 %q 推断格式，请指定csv或jsonl", name)
+}
+
+// ============================= 列映射 ====================
+// column Person的一个字段，列名来自db标签，与sqlx扫描结果时使用的映射一致
+type column struct {
+	name  string
+	index []int
+}
+
+// columns 按结构体字段顺序排列的所有列: id, name, age, address, created_at, updated_at, deleted_at, version
+var columns = personColumns()
+
+// columnIndex 列名 -> columns中的下标
+var columnIndex = func() map[string]int {
+	m := make(map[string]int, len(columns))
+	for i, c := range columns {
+		m[c.name] = i
+	}
+	return m
+}()
+
+func personColumns() []column {
+	mapper := reflectx.NewMapperFunc("db", strings.ToLower)
+	var cols []column
+	for _, fi := range mapper.TypeMap(reflect.TypeOf(store.Person{})).Index {
+		// 只取顶层字段，time.Time等结构体内部的字段不是列
+		if len(fi.Index) == 1 && fi.Name != "" {
+			cols = append(cols, column{name: fi.Name, index: fi.Index})
+		}
+	}
+	return cols
+}
+
+// format 将字段值转换为CSV单元格，nil指针为空字符串
+func (c column) format(p *store.Person) string {
+	v := reflect.ValueOf(p).Elem().FieldByIndex(c.index)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	// 按Kind处理，secret.String 等自定义字符串类型导出为明文
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	}
+	panic(fmt.Sprintf("transfer: 不支持的字段类型 %s", v.Type()))
+}
+
+// parse 将CSV单元格解析后写入字段，指针字段遇到空字符串时设为nil
+func (c column) parse(p *store.Person, s string) error {
+	v := reflect.ValueOf(p).Elem().FieldByIndex(c.index)
+	if v.Kind() == reflect.Pointer {
+		if s == "" {
+			v.SetZero()
+			return nil
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+
+	if _, ok := v.Interface().(time.Time); ok {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return fmt.Errorf("%s不是RFC3339时间: %q", c.name, s)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s不是整数: %q", c.name, s)
+		}
+		v.SetInt(n)
+		return nil
+	}
+	return fmt.Errorf("不支持的字段类型 %s", v.Type())
+}
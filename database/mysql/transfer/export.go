@@ -0,0 +1,154 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"Gocommunity/database/mysql/store"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ExportOptions 导出参数
+type ExportOptions struct {
+	Format Format
+	// IncludeDeleted 为true时同时导出回收站中的记录(导入时会跳过这些记录)
+	IncludeDeleted bool
+	// Tenant 只导出该租户的记录，为空时导出表中所有记录
+	// 导出文件不包含租户，多租户的表应按租户分别导出，导入时同样指定租户
+	Tenant string
+}
+
+// ============================= 导出 ====================
+// Export 按id顺序逐行读取user表并写入w，不会把整张表读入内存，返回导出的行数
+// 导出可能持续较长时间，不使用仓储的单条SQL超时，由调用方通过ctx控制
+// db为*dbx.Cluster时从从库读取
+func Export(ctx context.Context, db sqlx.ExtContext, w io.Writer, opts ExportOptions) (int, error) {
+	dialect, err := store.DialectFor(db.DriverName())
+	if err != nil {
+		return 0, err
+	}
+	enc, err := newEncoder(w, opts.Format)
+	if err != nil {
+		return 0, err
+	}
+
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	var (
+		where []string
+		args  []any
+	)
+	if !opts.IncludeDeleted {
+		where = append(where, "deleted_at IS NULL")
+	}
+	if opts.Tenant != "" {
+		where = append(where, "tenant_id = ?")
+		args = append(args, opts.Tenant)
+	}
+	query := "SELECT " + strings.Join(names, ", ") + " FROM " + dialect.Table()
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id"
+
+	rows, err := db.QueryxContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return 0, fmt.Errorf("查询用户失败: %w", err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var p store.Person
+		if err := rows.StructScan(&p); err != nil {
+			return n, fmt.Errorf("读取第%d行失败: %w", n+1, err)
+		}
+		if err := enc.encode(&p); err != nil {
+			return n, fmt.Errorf("写入第%d行失败: %w", n+1, err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("读取用户失败: %w", err)
+	}
+	return n, enc.flush()
+}
+
+// encoder 按格式逐行写出记录
+type encoder interface {
+	encode(p *store.Person) error
+	flush() error
+}
+
+func newEncoder(w io.Writer, format Format) (encoder, error) {
+	switch format {
+	case CSV:
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	case JSONL:
+		bw := bufio.NewWriter(w)
+		enc := json.NewEncoder(bw)
+		enc.SetEscapeHTML(false)
+		return &jsonlEncoder{w: bw, enc: enc}, nil
+	}
+	return nil, fmt.Errorf("不支持的格式 %q", format)
+}
+
+// csvEncoder 第一次写入时输出表头
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool
+	record []string
+}
+
+func (e *csvEncoder) encode(p *store.Person) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	for i, c := range columns {
+		e.record[i] = c.format(p)
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	e.record = make([]string, len(columns))
+	for i, c := range columns {
+		e.record[i] = c.name
+	}
+	return e.w.Write(e.record)
+}
+
+// flush 空表也输出表头，导入时可以识别列
+func (e *csvEncoder) flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonlEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// encode json.Encoder 每次输出后自带换行
+func (e *jsonlEncoder) encode(p *store.Person) error {
+	return e.enc.Encode(p)
+}
+
+func (e *jsonlEncoder) flush() error {
+	return e.w.Flush()
+}
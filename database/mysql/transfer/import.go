@@ -0,0 +1,360 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/seed"
+	"Gocommunity/database/mysql/store"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultChunkSize 默认每个事务写入的行数
+const DefaultChunkSize = 500
+
+// maxLineSize JSON Lines 单行的最大长度
+const maxLineSize = 1 << 20
+
+// Mode 导入时遇到已存在记录的处理方式
+type Mode string
+
+const (
+	ModeUpsert Mode = "upsert" // 按id新建或更新，已软删除的记录会被恢复，与cmd/seed相同
+	ModeInsert Mode = "insert" // 只新建，id已存在时记为行错误
+)
+
+var (
+	// ErrTooManyErrors 行错误数达到 ImportOptions.MaxErrors，导入提前结束
+	ErrTooManyErrors = errors.New("导入错误过多")
+
+	errDryRun    = errors.New("dry run")
+	errRowFailed = errors.New("row failed")
+)
+
+// ImportOptions 导入参数
+type ImportOptions struct {
+	Format Format
+	Mode   Mode // 默认 ModeUpsert
+	// ChunkSize 每个事务写入的行数，默认 DefaultChunkSize
+	// 每块单独提交，导入中途失败时已提交的块不会回滚
+	ChunkSize int
+	// DryRun 为true时照常校验和写入，但每块都回滚，用于预览导入结果
+	DryRun bool
+	// MaxErrors 行错误达到该数量时停止导入并返回 ErrTooManyErrors，<=0 表示不限制
+	MaxErrors int
+	// RepoOptions 创建仓储时的选项，例如 store.ConfigOptions(cfg) 使导入的记录也写入变更事件
+	RepoOptions []store.SQLOption
+}
+
+// RowError 单行导入失败的原因，Line为文件中的行号(从1开始，CSV表头是第1行)
+type RowError struct {
+	Line int
+	ID   string
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	if e.ID == "" {
+		return fmt.Sprintf("第%d行: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("第%d行(id=%s): %v", e.Line, e.ID, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ImportResult 导入结果，DryRun时为将会产生的结果
+type ImportResult struct {
+	seed.Result
+	Read    int // 读取的数据行数
+	Skipped int // 已软删除的记录(deleted_at不为空)，不导入
+	Chunks  int // 提交的事务数
+	Errors  []*RowError
+}
+
+func (r *ImportResult) String() string {
+	return fmt.Sprintf("读取%d 跳过%d 失败%d，%s", r.Read, r.Skipped, len(r.Errors), r.Result)
+}
+
+// ============================= 导入 ====================
+// Import 从r逐行读取记录，校验后按块写入数据库
+// 只导入业务字段(id/name/age/address)，审计字段和版本号由仓储维护，导出文件可以直接导入
+// 格式错误、校验失败和违反约束的行记录为 RowError 后继续导入其余的行；
+// 连接失败、超时等无法按行处理的错误会终止导入，此时返回的结果中包含已提交的部分
+func Import(ctx context.Context, db dbx.TxBeginner, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeUpsert
+	}
+	if opts.Mode != ModeUpsert && opts.Mode != ModeInsert {
+		return nil, fmt.Errorf("不支持的导入模式 %q", opts.Mode)
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	dec, err := newDecoder(r, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	im := &importer{db: db, opts: opts, result: &ImportResult{}}
+	chunk := make([]row, 0, opts.ChunkSize)
+	for {
+		rw, err := dec.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return im.result, err
+		}
+		im.result.Read++
+
+		if rw.err == nil {
+			rw.err = rw.person.Validate()
+		}
+		switch {
+		case rw.err != nil:
+			if err := im.fail(rw, rw.err); err != nil {
+				return im.result, err
+			}
+		case rw.person.DeletedAt != nil:
+			im.result.Skipped++
+		default:
+			chunk = append(chunk, rw)
+		}
+
+		if len(chunk) == opts.ChunkSize {
+			if err := im.commit(ctx, chunk); err != nil {
+				return im.result, err
+			}
+			chunk = chunk[:0]
+		}
+	}
+	if err := im.commit(ctx, chunk); err != nil {
+		return im.result, err
+	}
+	return im.result, nil
+}
+
+// row 读取到的一行，err为该行的解析错误
+type row struct {
+	line   int
+	person store.Person
+	err    error
+}
+
+type importer struct {
+	db     dbx.TxBeginner
+	opts   ImportOptions
+	result *ImportResult
+}
+
+// fail 记录行错误，达到 MaxErrors 时返回 ErrTooManyErrors
+func (im *importer) fail(rw row, err error) error {
+	im.result.Errors = append(im.result.Errors, &RowError{Line: rw.line, ID: rw.person.UserId, Err: err})
+	if im.opts.MaxErrors > 0 && len(im.result.Errors) >= im.opts.MaxErrors {
+		return fmt.Errorf("%w: 已有%d行失败", ErrTooManyErrors, len(im.result.Errors))
+	}
+	return nil
+}
+
+// commit 在一个事务中写入一块数据
+// 某一行因数据问题失败时回滚整块，记录该行错误后用剩余的行重试，保证提交的块中不含失败的行
+func (im *importer) commit(ctx context.Context, chunk []row) error {
+	for len(chunk) > 0 {
+		var (
+			result  seed.Result
+			failed  int
+			failErr error
+		)
+		err := dbx.WithTx(ctx, im.db, dbx.DefaultTxOptions(), func(tx *sqlx.Tx) error {
+			// 发生死锁等可重试错误时整个函数会重新执行，每次都从头统计
+			result, failed, failErr = seed.Result{}, -1, nil
+			repo := store.NewSQLPersonRepository(tx, im.opts.RepoOptions...)
+			for i := range chunk {
+				if err := im.write(ctx, repo, &chunk[i].person, &result); err != nil {
+					if !isRowError(err) {
+						return err
+					}
+					failed, failErr = i, err
+					return errRowFailed
+				}
+			}
+			if im.opts.DryRun {
+				return errDryRun
+			}
+			return nil
+		})
+
+		if errors.Is(err, errRowFailed) {
+			if err := im.fail(chunk[failed], failErr); err != nil {
+				return err
+			}
+			chunk = append(chunk[:failed:failed], chunk[failed+1:]...)
+			continue
+		}
+		if err != nil && !errors.Is(err, errDryRun) {
+			return fmt.Errorf("写入第%d-%d行失败: %w", chunk[0].line, chunk[len(chunk)-1].line, err)
+		}
+
+		im.result.Created += result.Created
+		im.result.Updated += result.Updated
+		im.result.Restored += result.Restored
+		im.result.Unchanged += result.Unchanged
+		im.result.Chunks++
+		return nil
+	}
+	return nil
+}
+
+// write 按导入模式写入一行
+func (im *importer) write(ctx context.Context, repo *store.SQLPersonRepository, p *store.Person, result *seed.Result) error {
+	if im.opts.Mode == ModeInsert {
+		// Create会回填审计字段，使用副本避免重试时带上旧值
+		created := *p
+		if err := repo.Create(ctx, &created); err != nil {
+			return err
+		}
+		result.Created++
+		return nil
+	}
+
+	r, err := seed.Apply(ctx, repo, []store.Person{*p})
+	if err != nil {
+		return err
+	}
+	result.Created += r.Created
+	result.Updated += r.Updated
+	result.Restored += r.Restored
+	result.Unchanged += r.Unchanged
+	return nil
+}
+
+// isRowError 是否是由这一行的数据引起的错误，其余错误(连接、超时等)重试其它行也不会成功
+func isRowError(err error) bool {
+	return errors.Is(err, store.ErrDuplicateKey) || errors.Is(err, store.ErrConstraint) ||
+		errors.Is(err, store.ErrVersionConflict)
+}
+
+// ============================= 读取 ====================
+// decoder 逐行读取记录，数据结束时返回 io.EOF
+// 单行的格式错误放在 row.err 中，返回的error表示无法继续读取
+type decoder interface {
+	next() (row, error)
+}
+
+func newDecoder(r io.Reader, format Format) (decoder, error) {
+	switch format {
+	case CSV:
+		return newCSVDecoder(r)
+	case JSONL:
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		return &jsonlDecoder{sc: sc}, nil
+	}
+	return nil, fmt.Errorf("不支持的格式 %q", format)
+}
+
+// csvDecoder 按表头中的列名映射字段，列的顺序任意，未出现的列保持零值
+type csvDecoder struct {
+	r    *csv.Reader
+	cols []column
+}
+
+func newCSVDecoder(r io.Reader) (*csvDecoder, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV文件为空，缺少表头")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取CSV表头失败: %w", err)
+	}
+
+	d := &csvDecoder{r: cr}
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // Excel导出的UTF-8 BOM
+		}
+		idx, ok := columnIndex[name]
+		if !ok {
+			return nil, fmt.Errorf("CSV表头第%d列 %q 不是user表的列", i+1, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("CSV表头中 %q 重复", name)
+		}
+		seen[name] = true
+		d.cols = append(d.cols, columns[idx])
+	}
+	for _, required := range []string{"id", "name"} {
+		if !seen[required] {
+			return nil, fmt.Errorf("CSV表头缺少 %q 列", required)
+		}
+	}
+	return d, nil
+}
+
+func (d *csvDecoder) next() (row, error) {
+	record, err := d.r.Read()
+	if err == io.EOF {
+		return row{}, io.EOF
+	}
+	// 列数不对、引号不匹配只影响当前行，csv.Reader可以继续读取下一行
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return row{line: parseErr.StartLine, err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return row{}, fmt.Errorf("读取CSV失败: %w", err)
+	}
+
+	var rw row
+	rw.line, _ = d.r.FieldPos(0)
+
+	for i, c := range d.cols {
+		if err := c.parse(&rw.person, record[i]); err != nil && rw.err == nil {
+			rw.err = err
+		}
+	}
+	return rw, nil
+}
+
+// jsonlDecoder 跳过空行，字段名使用Person的json标签，不认识的字段视为错误
+type jsonlDecoder struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func (d *jsonlDecoder) next() (row, error) {
+	for d.sc.Scan() {
+		d.line++
+		line := bytes.TrimSpace(d.sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		rw := row{line: d.line}
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rw.person); err != nil {
+			rw.err = fmt.Errorf("JSON格式错误: %w", err)
+		} else if dec.More() {
+			rw.err = errors.New("JSON格式错误: 一行中有多个值")
+		}
+		return rw, nil
+	}
+	if err := d.sc.Err(); err != nil {
+		return row{}, fmt.Errorf("读取第%d行失败: %w", d.line+1, err)
+	}
+	return row{}, io.EOF
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
+	"Gocommunity/database/mysql/api"
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/store"
+	"Gocommunity/database/mysql/tenant"
+
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
@@ -29,9 +35,9 @@ func CorsMiddleware() gin.HandlerFunc {
 		origin := c.Request.Header.Get("Origin")
 		if origin != "" {
 			c.Header("Access-Control-Allow-Origin", origin)
-			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-			c.Header("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization")
-			c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers")
+			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE, UPDATE")
+			c.Header("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization, If-Match")
+			c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, ETag")
 			c.Header("Access-Control-Allow-Credentials", "true")
 		}
 
@@ -119,6 +125,43 @@ func DeleteHandler(c *gin.Context) {
 	})
 }
 
+// ============================= 2.1 用户仓储 (MySQL) ====================
+// 用户数据来自 database/mysql 的仓储，数据库不可用时退回内存存储
+var personRepo store.PersonRepository
+
+// dbMetrics SQL耗时、错误数和连接池状态，通过 /metrics 暴露
+var dbMetrics = dbx.NewMetrics(dbx.DefaultSlowQueryThreshold)
+
+// dbHealth 后台定期Ping主库，通过 /healthz 和 /readyz 暴露；使用内存存储时为nil，总是就绪
+var dbHealth *dbx.HealthChecker
+
+// openPersonRepository 按 DB_* 环境变量连接MySQL(配置了DB_REPLICAS时读写分离)，失败时使用内存存储
+// 全文检索优先使用MySQL的FULLTEXT索引，没有索引或使用内存存储时使用进程内索引
+func openPersonRepository() store.PersonRepository {
+	cfg, err := dbx.LoadConfig("")
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		db, openErr := dbx.OpenCluster(ctx, cfg)
+		if openErr == nil {
+			dbMetrics.SlowThreshold = time.Duration(cfg.SlowQueryThreshold)
+			for name, pool := range db.Pools() {
+				dbMetrics.RegisterPool(name, pool)
+			}
+			dbHealth = dbx.NewHealthChecker(db, dbx.HealthConfigOptions(cfg)...)
+			return store.NewSearchablePersonRepository(
+				store.NewSQLPersonRepository(dbx.Instrument(db, dbMetrics), store.ConfigOptions(cfg)...))
+		}
+		err = openErr
+	}
+
+	log.Printf("数据库不可用，用户API使用内存存储: %v", err)
+	repo := store.NewMemoryPersonRepository()
+	repo.Create(context.Background(), &store.Person{UserId: "12132", Username: "张三", Age: 18, Address: "北京市"})
+	return store.NewSearchablePersonRepository(repo)
+}
+
 // ============================= 3. 404和405处理 ====================
 func Handle404(c *gin.Context) {
 	c.JSON(http.StatusNotFound, gin.H{
@@ -190,7 +233,23 @@ func main() {
 		})
 	}
 
-	// 5.4 注册404和405处理器
+	// 5.4 用户资源 - 数据来自MySQL，处理器和参数校验在 database/mysql/api 包中
+	personRepo = openPersonRepository()
+	// 默认不确定租户，数据库配置了multi_tenant时用户接口返回400；
+	// TENANT_TRUST_HEADER=true 时租户取自请求头 X-Tenant-ID，客户端可以访问任意租户，只用于本地演示。
+	// 生产环境改用 tenant.PrincipalResolver，按登录用户(例如session中的username)确定租户
+	var personOpts []api.HandlerOption
+	if tenant.HeaderTrusted() {
+		log.Printf("%s=true: 租户取自请求头 %s，没有租户之间的访问控制", tenant.TrustHeaderEnv, tenant.Header)
+		personOpts = append(personOpts, api.WithTenantResolver(tenant.HeaderResolver))
+	}
+	api.NewPersonHandler(personRepo, personOpts...).Register(router)
+	router.GET("/metrics", gin.WrapH(dbMetrics.Handler()))
+	// 存活和就绪检查: 数据库连续Ping失败时 /readyz 返回503，编排系统停止向本实例转发流量
+	router.GET("/healthz", gin.WrapH(dbHealth.LiveHandler()))
+	router.GET("/readyz", gin.WrapH(dbHealth.ReadyHandler()))
+
+	// 5.5 注册404和405处理器
 	router.NoRoute(Handle404)
 	router.NoMethod(Handle405)
 
@@ -235,6 +294,18 @@ func main() {
 	fmt.Println("    DELETE /api/delete")
 	fmt.Println("    GET  /api/admin/users")
 	fmt.Println("    POST /api/admin/users")
+	fmt.Println("  用户路由:")
+	fmt.Println("    GET    /persons?name_prefix=&min_age=&max_age=&address=&sort=&order=&limit=&cursor=")
+	fmt.Println("    GET    /persons/search?q=&limit=&offset=")
+	fmt.Println("    GET    /persons/:id")
+	fmt.Println("    POST   /persons")
+	fmt.Println("    PUT    /persons/:id (需要 If-Match 请求头)")
+	fmt.Println("    PATCH  /persons/:id (需要 If-Match 请求头)")
+	fmt.Println("    DELETE /persons/:id")
+	fmt.Println("  监控:")
+	fmt.Println("    GET    /metrics (SQL耗时直方图、错误数、连接池状态)")
+	fmt.Println("    GET    /healthz (存活检查，附带数据库状态)")
+	fmt.Println("    GET    /readyz (就绪检查，数据库不可用时返回503)")
 	fmt.Println("  静态文件:")
 	fmt.Println("    GET  /static/*filepath")
 	fmt.Println("    GET  /favicon.ico")
@@ -286,11 +357,14 @@ func main() {
    - OPTIONS预检: 自动处理OPTIONS请求
 
 8. 最佳实践:
+   - 数据库操作传入c.Request.Context()，客户端断开后查询随之取消
    - 使用路由组组织相关功能
    - 中间件按功能划分(认证、日志、跨域等)
    - 生产环境关闭控制台颜色
    - 合理配置静态文件服务路径
    - 使用结构化的错误响应
+   - 资源处理器放在独立的包中(database/mysql/api)，main只负责组装依赖
+   - gin.WrapH() 可以挂载标准库的 http.Handler，如 /metrics
 
 9. 重要提醒:
    - 中间件数量不要超过63个(abortIndex限制)
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"mime"
@@ -10,6 +12,11 @@ import (
 	"path/filepath"
 	"time"
 
+	"Gocommunity/database/mysql/dbx"
+	"Gocommunity/database/mysql/secret"
+	"Gocommunity/database/mysql/store"
+	"Gocommunity/database/mysql/tenant"
+
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -169,11 +176,147 @@ func GlobalOPTIONSHandler(w http.ResponseWriter, r *http.Request) {
 		header := w.Header()
 		header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		header.Set("Access-Control-Allow-Origin", "*")
-		header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match")
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// ============================= 12. 用户 API (MySQL) ====================
+// 用户数据来自 database/mysql 的仓储，数据库不可用时退回内存存储
+var personRepo store.PersonRepository
+
+// dbMetrics SQL耗时、错误数和连接池状态，通过 /metrics 暴露
+var dbMetrics = dbx.NewMetrics(dbx.DefaultSlowQueryThreshold)
+
+// dbHealth 后台定期Ping主库，通过 /healthz 和 /readyz 暴露；使用内存存储时为nil，总是就绪
+var dbHealth *dbx.HealthChecker
+
+// openPersonRepository 按 DB_* 环境变量连接MySQL(配置了DB_REPLICAS时读写分离)，失败时使用内存存储
+func openPersonRepository() store.PersonRepository {
+	cfg, err := dbx.LoadConfig("")
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		db, openErr := dbx.OpenCluster(ctx, cfg)
+		if openErr == nil {
+			dbMetrics.SlowThreshold = time.Duration(cfg.SlowQueryThreshold)
+			for name, pool := range db.Pools() {
+				dbMetrics.RegisterPool(name, pool)
+			}
+			dbHealth = dbx.NewHealthChecker(db, dbx.HealthConfigOptions(cfg)...)
+			return store.NewSQLPersonRepository(dbx.Instrument(db, dbMetrics), store.ConfigOptions(cfg)...)
+		}
+		err = openErr
+	}
+
+	log.Printf("数据库不可用，用户API使用内存存储: %v", err)
+	repo := store.NewMemoryPersonRepository()
+	repo.Create(context.Background(), &store.Person{UserId: "12132", Username: "张三", Age: 18, Address: "北京市"})
+	return repo
+}
+
+// GET /persons?name_prefix=张&min_age=18&sort=age&order=desc&limit=20&cursor=xxx - 分页获取用户
+// r.Context() 在客户端断开连接时会被取消，正在执行的SQL随之中止
+func PersonIndex(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	opts, err := store.ParseListOptions(r.URL.Query())
+	if err != nil {
+		writePersonError(w, err)
+		return
+	}
+	page, err := personRepo.List(r.Context(), opts)
+	if err != nil {
+		writePersonError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /persons/:id - 获取特定用户，ETag响应头是当前版本号
+func PersonShow(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	person, err := personRepo.Get(r.Context(), ps.ByName("id"))
+	if err != nil {
+		writePersonError(w, err)
+		return
+	}
+	w.Header().Set("ETag", store.ETag(person.Version))
+	writeJSON(w, http.StatusOK, person)
+}
+
+// PUT /persons/:id - 更新用户
+// 必须携带 If-Match 请求头(值为GET返回的ETag)，版本不一致时返回412，避免覆盖他人的修改
+func PersonUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		writeJSON(w, http.StatusPreconditionRequired, map[string]string{"error": "缺少If-Match请求头"})
+		return
+	}
+	version, err := store.ParseETag(ifMatch)
+	if err != nil {
+		writePersonError(w, err)
+		return
+	}
+
+	var body struct {
+		Name    string `json:"name"`
+		Age     int    `json:"age"`
+		Address string `json:"address"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "请求体不是合法的JSON"})
+		return
+	}
+
+	person := &store.Person{
+		UserId:   ps.ByName("id"),
+		Username: body.Name,
+		Age:      body.Age,
+		Address:  secret.String(body.Address),
+		Version:  version,
+	}
+	// 请求体没有binding标签，在这里校验字段，不合法时返回400(仓储写入前同样会校验)
+	if err := person.Validate(); err != nil {
+		writePersonError(w, err)
+		return
+	}
+	// 读己之写: 更新之后的读取走主库，不会读到从库上的旧数据
+	ctx := dbx.WithReadYourWrites(r.Context())
+	if err := personRepo.Update(ctx, person); err != nil {
+		writePersonError(w, err)
+		return
+	}
+	// 重新读取完整记录(包含创建时间等由数据库维护的字段)作为响应
+	if fresh, err := personRepo.Get(ctx, person.UserId); err == nil {
+		person = fresh
+	}
+	w.Header().Set("ETag", store.ETag(person.Version))
+	writeJSON(w, http.StatusOK, person)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writePersonError 使用 store.HTTPStatus 将数据层错误转换为HTTP状态码
+func writePersonError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.Canceled) {
+		// 客户端已断开，无需再写响应
+		log.Printf("请求已取消: %v", err)
+		return
+	}
+
+	status := store.HTTPStatus(err)
+	if status >= http.StatusInternalServerError {
+		// 5xx 不把内部错误细节返回给客户端
+		log.Printf("用户接口失败: %v", err)
+		writeJSON(w, status, map[string]string{"error": http.StatusText(status)})
+		return
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
 func main() {
 	router := httprouter.New()
 
@@ -205,6 +348,17 @@ func main() {
 	// ============================= RESTful API 路由 ====================
 	router.GET("/books", BookIndex)
 	router.GET("/books/:isdn", BookShow)
+	router.GET("/persons", PersonIndex)
+	router.GET("/persons/:id", PersonShow)
+	router.PUT("/persons/:id", PersonUpdate)
+
+	// ============================= 监控 ====================
+	// 先连接数据库，健康检查在连接成功后才创建
+	personRepo = openPersonRepository()
+	router.Handler(http.MethodGet, "/metrics", dbMetrics.Handler())
+	// 存活和就绪检查: 数据库连续Ping失败时 /readyz 返回503，编排系统停止向本实例转发流量
+	router.Handler(http.MethodGet, "/healthz", dbHealth.LiveHandler())
+	router.Handler(http.MethodGet, "/readyz", dbHealth.ReadyHandler())
 
 	// ============================= 特殊处理器配置 ====================
 	// 自定义 404 处理器 [citation:3]
@@ -252,14 +406,28 @@ func main() {
 	fmt.Println("  GET  /std/:name")
 	fmt.Println("  GET  /books")
 	fmt.Println("  GET  /books/:isdn")
+	fmt.Println("  GET  /persons")
+	fmt.Println("  GET  /persons/:id")
+	fmt.Println("  PUT  /persons/:id (需要 If-Match 请求头)")
+	fmt.Println("  GET  /metrics (SQL耗时直方图、错误数、连接池状态)")
+	fmt.Println("  GET  /healthz (存活检查，附带数据库状态)")
+	fmt.Println("  GET  /readyz (就绪检查，数据库不可用时返回503)")
 	fmt.Println("  GET  /public")
 	fmt.Println("  GET  /protected (需要基本认证: admin/secret)")
 	fmt.Println("  GET  /panic (演示 Panic 处理)")
 
 	// 创建自定义服务器配置
+	// 默认不确定租户，数据库配置了multi_tenant时用户接口返回400；
+	// TENANT_TRUST_HEADER=true 时 tenant.Middleware 将请求头 X-Tenant-ID 放入context，客户端可以访问任意租户，只用于本地演示。
+	// 生产环境改用 tenant.PrincipalResolver 按认证身份确定租户
+	var handler http.Handler = router
+	if tenant.HeaderTrusted() {
+		log.Printf("%s=true: 租户取自请求头 %s，没有租户之间的访问控制", tenant.TrustHeaderEnv, tenant.Header)
+		handler = tenant.Middleware(tenant.HeaderResolver)(router)
+	}
 	server := &http.Server{
 		Addr:         ":8080",
-		Handler:      router,
+		Handler:      handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 	}
@@ -286,4 +454,5 @@ func main() {
 //    - GlobalOPTIONS：处理 CORS 预检请求
 //    - 中间件模式：通过包装函数实现认证、日志等功能
 //    - RESTful API 支持：清晰的资源路由映射
+//    - 请求上下文：r.Context() 传给数据层，客户端断开或超时后查询自动取消
 //    - 高性能：基于基数树实现，零垃圾内存分配